@@ -0,0 +1,17 @@
+// Command kubectl-serverless is sfctl packaged as a kubectl plugin, run as
+// kubectl serverless once the executable is on the PATH.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/peizhong/serverless-controller/pkg/cli"
+)
+
+func main() {
+	if err := cli.NewCommand("kubectl serverless", os.Stdout, os.Stderr).Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,18 @@
+// Command sfctl manages ServerlessFuncs: it deploys, lists, describes,
+// scales, rolls back and deletes functions, and follows or calls the
+// functions running in the cluster.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/peizhong/serverless-controller/pkg/cli"
+)
+
+func main() {
+	if err := cli.NewCommand("sfctl", os.Stdout, os.Stderr).Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+}
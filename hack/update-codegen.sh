@@ -27,7 +27,7 @@ CODEGEN_PKG=${CODEGEN_PKG:-$(cd "${SCRIPT_ROOT}"; ls -d -1 ./vendor/k8s.io/code-
 #                  instead of the $GOPATH directly. For normal projects this can be dropped.
 bash "${CODEGEN_PKG}"/generate-groups.sh "deepcopy,client,informer,lister" \
   github.com/peizhong/serverless-controller/pkg/generated github.com/peizhong/serverless-controller/pkg/apis \
-  serverlesscontroller:v1alpha1 \
+  serverlesscontroller:v1alpha1,v1beta1 \
   --output-base "$(dirname "${BASH_SOURCE[0]}")/../temp" \
   --go-header-file "${SCRIPT_ROOT}"/hack/boilerplate.go.txt
 
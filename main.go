@@ -1,21 +1,156 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/peizhong/serverless-controller/pkg/controller"
+	"github.com/peizhong/serverless-controller/pkg/logging"
+	"github.com/peizhong/serverless-controller/pkg/metrics"
 	"github.com/peizhong/serverless-controller/pkg/signals"
-	"k8s.io/klog"
+	"github.com/peizhong/serverless-controller/pkg/webhook"
+	"k8s.io/klog/v2"
 )
 
 func main() {
 	klog.InitFlags(nil)
-	klog.SetOutput(os.Stdout)
 
-	stopCh := signals.SetupSignalHandler()
-	ctrl := controller.FromLocalFile(stopCh)
-	if err := ctrl.Run(1, stopCh); err != nil {
-		log.Fatalf("Error running controller: %s", err.Error())
+	opts := controller.DefaultOptions()
+	flag.StringVar(&opts.Kubeconfig, "kubeconfig", opts.Kubeconfig, "Path to a kubeconfig. Defaults to $KUBECONFIG or ~/.kube/config, and to the in-cluster configuration when neither exists.")
+	flag.StringVar(&opts.Master, "master", opts.Master, "Address of the Kubernetes API server. Overrides any value in kubeconfig.")
+	flag.StringVar(&opts.Context, "context", opts.Context, "Kubeconfig context to use instead of the current one.")
+	flag.DurationVar(&opts.ResyncPeriod, "resync-period", opts.ResyncPeriod, "How often every watched object is reconciled again.")
+	namespaces := flag.String("namespace", "", "Comma-separated list of namespaces to watch. All namespaces when empty.")
+	flag.StringVar(&opts.NamespaceSelector, "namespace-selector", opts.NamespaceSelector, "Only reconcile functions in namespaces matching this label selector, e.g. serverless.peizhong.io/enabled=true. The informers still watch every namespace, use --namespace to limit what is watched. Cannot be combined with --namespace.")
+	flag.StringVar(&opts.ConfigFile, "config", opts.ConfigFile, "Path to a ControllerConfig file, reloaded when it changes. Built-in defaults are used when empty.")
+	flag.DurationVar(&opts.Backoff.Base, "backoff-base", opts.Backoff.Base, "Delay before syncing a function again after its first failure, doubled on every following failure.")
+	flag.DurationVar(&opts.Backoff.Max, "backoff-max", opts.Backoff.Max, "Maximum delay before syncing a failing function again.")
+	flag.Float64Var(&opts.Backoff.QPS, "backoff-qps", opts.Backoff.QPS, "Maximum rate of retries over all functions.")
+	flag.IntVar(&opts.Backoff.Burst, "backoff-burst", opts.Backoff.Burst, "Maximum burst of retries over all functions.")
+	workers := flag.Int("workers", 1, "Number of functions reconciled concurrently.")
+	logFormat := flag.String("log-format", logging.FormatText, "Format of the logs, text or json. Verbosity is set by -v.")
+	bindAddr := flag.String("bind-address", ":8080", "Address of the HTTP server for /metrics, /healthz, /readyz and /debug. Disabled when empty.")
+	webhookAddr := flag.String("webhook-bind-address", "", "Address of the HTTPS server of the admission webhooks, e.g. :9443. Disabled when empty.")
+	webhookCertDir := flag.String("webhook-cert-dir", webhook.DefaultCertDir, "Directory holding tls.crt and tls.key, the serving certificate of the admission webhooks.")
+
+	le := controller.DefaultLeaderElection()
+	flag.BoolVar(&le.Enabled, "leader-elect", le.Enabled, "Elect a leader among the replicas before running the workers, required when running more than one replica.")
+	flag.StringVar(&le.LeaseName, "leader-elect-lease-name", le.LeaseName, "Name of the Lease used for leader election.")
+	flag.StringVar(&le.LeaseNamespace, "leader-elect-lease-namespace", le.LeaseNamespace, "Namespace of the Lease used for leader election.")
+	flag.DurationVar(&le.LeaseDuration, "leader-elect-lease-duration", le.LeaseDuration, "Duration standbys wait before taking over a lease that is not renewed.")
+	flag.DurationVar(&le.RenewDeadline, "leader-elect-renew-deadline", le.RenewDeadline, "Duration the leader retries renewing the lease before giving it up.")
+	flag.DurationVar(&le.RetryPeriod, "leader-elect-retry-period", le.RetryPeriod, "Duration between attempts to acquire or renew the lease.")
+
+	planOutput := flag.String("plan-output", "text", "Output format of the plan command, text or json.")
+	planExitCode := flag.Bool("plan-detailed-exitcode", false, "Make the plan command exit with 2 when it would change any object or fail to sync any function.")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [plan]\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Runs the controller, or with plan, prints the changes it would make to the cluster and exits.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	for _, namespace := range strings.Split(*namespaces, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			opts.Namespaces = append(opts.Namespaces, namespace)
+		}
+	}
+
+	switch flag.Arg(0) {
+	case "":
+	case "plan":
+		// stdout is left to the plan
+		if err := logging.Setup(*logFormat, os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error planning: %s\n", err.Error())
+			os.Exit(1)
+		}
+		changed, err := runPlan(opts, *planOutput)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error planning: %s\n", err.Error())
+			os.Exit(1)
+		}
+		if changed && *planExitCode {
+			os.Exit(2)
+		}
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if err := logging.Setup(*logFormat, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error running controller: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	if err := run(opts, le, *workers, *bindAddr, *webhookAddr, *webhookCertDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error running controller: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(opts controller.Options, le controller.LeaderElection, workers int, bindAddr, webhookAddr, webhookCertDir string) error {
+	if workers < 1 {
+		return fmt.Errorf("--workers must be at least 1, got %d", workers)
+	}
+	ctx := signals.SetupSignalContext()
+	ctrl, err := controller.FromOptions(opts, ctx.Done())
+	if err != nil {
+		return err
+	}
+	if bindAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		ctrl.InstallHandlers(mux)
+		go serveHTTP(ctx, bindAddr, mux)
+	}
+	// every replica serves the webhooks, not only the leader
+	if webhookAddr != "" {
+		go func() {
+			if err := webhook.Serve(ctx, webhookAddr, webhookCertDir, ctrl.Config); err != nil {
+				klog.ErrorS(err, "Failed to serve webhooks", "address", webhookAddr)
+			}
+		}()
+	}
+	return ctrl.RunWithLeaderElection(ctx, le, workers)
+}
+
+// runPlan prints the changes the controller would make in output, and reports
+// whether there are any.
+func runPlan(opts controller.Options, output string) (bool, error) {
+	if output != "text" && output != "json" {
+		return false, fmt.Errorf("--plan-output must be text or json, got %q", output)
+	}
+	ctx := signals.SetupSignalContext()
+	plan, err := controller.PlanFromOptions(ctx, opts)
+	if err != nil {
+		return false, err
+	}
+	if output == "json" {
+		err = plan.WriteJSON(os.Stdout)
+	} else {
+		err = plan.WriteText(os.Stdout)
+	}
+	return plan.HasChanges(), err
+}
+
+// serveHTTP serves handler on addr until ctx is done. The controller keeps
+// running when the server fails, which the liveness probe then reports.
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) {
+	server := &http.Server{Addr: addr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	klog.InfoS("Serving HTTP", "address", addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		klog.ErrorS(err, "Failed to serve HTTP", "address", addr)
 	}
 }
@@ -0,0 +1,5 @@
+package v1alpha1
+
+// Hub marks v1alpha1 as the version the other versions convert to and from,
+// since it is the storage version the controller works with.
+func (*ServerlessFunc) Hub() {}
@@ -0,0 +1,337 @@
+package v1beta1
+
+import (
+	"github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+)
+
+// SourceAnnotation holds spec.artifact.source in a ServerlessFunc converted to
+// v1alpha1, which has no such field, so that converting back restores it.
+const SourceAnnotation = "serverless.peizhong.io/source"
+
+// ConvertTo converts src to the hub version, the TypeMeta of dst is left to
+// the caller.
+func (src *ServerlessFunc) ConvertTo(dst *v1alpha1.ServerlessFunc) {
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	if source := src.Spec.Artifact.Source; source != "" {
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		dst.Annotations[SourceAnnotation] = source
+	}
+
+	spec := src.Spec
+	dst.Spec = v1alpha1.FooSpec{
+		Image:          spec.Artifact.Image,
+		Version:        spec.Artifact.Version,
+		Protocol:       v1alpha1.Protocol(spec.Runtime.Protocol),
+		HTTP:           httpToHub(spec.Runtime.HTTP),
+		Resources:      (*v1alpha1.ResourcesSpec)(spec.Runtime.Resources.DeepCopy()),
+		Auth:           authToHub(spec.Routing.Auth),
+		Network:        networkToHub(spec.Routing.Network),
+		DeletionPolicy: v1alpha1.DeletionPolicy(spec.DeletionPolicy),
+	}
+	if spec.Scaling.Replicas != nil {
+		replicas := *spec.Scaling.Replicas
+		dst.Spec.Replicas = &replicas
+	}
+
+	status := src.Status.DeepCopy()
+	dst.Status = v1alpha1.FooStatus{
+		AvailableReplicas:           status.AvailableReplicas,
+		Ready:                       status.Ready,
+		URL:                         status.URL,
+		ObjectNames:                 (*v1alpha1.ObjectNames)(status.ObjectNames),
+		LastHandledReconcileRequest: status.LastHandledReconcileRequest,
+		Phase:                       v1alpha1.RolloutPhase(status.Phase),
+		Reason:                      status.Reason,
+		Message:                     status.Message,
+		Conditions:                  status.Conditions,
+	}
+}
+
+// ConvertFrom converts src from the hub version, the TypeMeta of dst is left
+// to the caller.
+func (dst *ServerlessFunc) ConvertFrom(src *v1alpha1.ServerlessFunc) {
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	var source string
+	// an empty annotation is kept as is, since it cannot be told apart from
+	// a missing source once converted back
+	if source = dst.Annotations[SourceAnnotation]; source != "" {
+		delete(dst.Annotations, SourceAnnotation)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+	}
+
+	spec := src.Spec
+	dst.Spec = ServerlessFuncSpec{
+		Artifact: ArtifactSpec{
+			Image:   spec.Image,
+			Version: spec.Version,
+			Source:  source,
+		},
+		Runtime: RuntimeSpec{
+			Protocol:  Protocol(spec.Protocol),
+			HTTP:      httpFromHub(spec.HTTP),
+			Resources: (*ResourcesSpec)(spec.Resources.DeepCopy()),
+		},
+		Routing: RoutingSpec{
+			Auth:    authFromHub(spec.Auth),
+			Network: networkFromHub(spec.Network),
+		},
+		DeletionPolicy: DeletionPolicy(spec.DeletionPolicy),
+	}
+	if spec.Replicas != nil {
+		replicas := *spec.Replicas
+		dst.Spec.Scaling.Replicas = &replicas
+	}
+
+	status := src.Status.DeepCopy()
+	dst.Status = ServerlessFuncStatus{
+		AvailableReplicas:           status.AvailableReplicas,
+		Ready:                       status.Ready,
+		URL:                         status.URL,
+		ObjectNames:                 (*ObjectNames)(status.ObjectNames),
+		LastHandledReconcileRequest: status.LastHandledReconcileRequest,
+		Phase:                       RolloutPhase(status.Phase),
+		Reason:                      status.Reason,
+		Message:                     status.Message,
+		Conditions:                  status.Conditions,
+	}
+}
+
+func httpToHub(in *HTTPSpec) *v1alpha1.HTTPSpec {
+	if in == nil {
+		return nil
+	}
+	in = in.DeepCopy()
+	out := &v1alpha1.HTTPSpec{
+		RequestTimeout: in.RequestTimeout,
+		IdleTimeout:    in.IdleTimeout,
+		MaxRequestSize: in.MaxRequestSize,
+	}
+	if in.CORS != nil {
+		out.CORS = &v1alpha1.CORSSpec{
+			AllowOrigins:     originsToHub(in.CORS.AllowOrigins),
+			AllowMethods:     methodsToHub(in.CORS.AllowMethods),
+			AllowHeaders:     headersToHub(in.CORS.AllowHeaders),
+			AllowCredentials: in.CORS.AllowCredentials,
+		}
+	}
+	return out
+}
+
+func httpFromHub(in *v1alpha1.HTTPSpec) *HTTPSpec {
+	if in == nil {
+		return nil
+	}
+	in = in.DeepCopy()
+	out := &HTTPSpec{
+		RequestTimeout: in.RequestTimeout,
+		IdleTimeout:    in.IdleTimeout,
+		MaxRequestSize: in.MaxRequestSize,
+	}
+	if in.CORS != nil {
+		out.CORS = &CORSSpec{
+			AllowOrigins:     originsFromHub(in.CORS.AllowOrigins),
+			AllowMethods:     methodsFromHub(in.CORS.AllowMethods),
+			AllowHeaders:     headersFromHub(in.CORS.AllowHeaders),
+			AllowCredentials: in.CORS.AllowCredentials,
+		}
+	}
+	return out
+}
+
+func authToHub(in *AuthSpec) *v1alpha1.AuthSpec {
+	if in == nil {
+		return nil
+	}
+	in = in.DeepCopy()
+	out := &v1alpha1.AuthSpec{
+		Type:       v1alpha1.AuthType(in.Type),
+		SecretName: in.SecretName,
+	}
+	if in.JWT != nil {
+		out.JWT = &v1alpha1.JWTAuth{
+			JWKSConfigMap: in.JWT.JWKSConfigMap,
+			JWKSKey:       in.JWT.JWKSKey,
+			Issuer:        in.JWT.Issuer,
+			Audiences:     in.JWT.Audiences,
+		}
+	}
+	if in.RateLimit != nil {
+		out.RateLimit = &v1alpha1.RateLimit{
+			RequestsPerSecond: in.RateLimit.RequestsPerSecond,
+			Burst:             in.RateLimit.Burst,
+		}
+	}
+	return out
+}
+
+func authFromHub(in *v1alpha1.AuthSpec) *AuthSpec {
+	if in == nil {
+		return nil
+	}
+	in = in.DeepCopy()
+	out := &AuthSpec{
+		Type:       AuthType(in.Type),
+		SecretName: in.SecretName,
+	}
+	if in.JWT != nil {
+		out.JWT = &JWTAuth{
+			JWKSConfigMap: in.JWT.JWKSConfigMap,
+			JWKSKey:       in.JWT.JWKSKey,
+			Issuer:        in.JWT.Issuer,
+			Audiences:     in.JWT.Audiences,
+		}
+	}
+	if in.RateLimit != nil {
+		out.RateLimit = &RateLimit{
+			RequestsPerSecond: in.RateLimit.RequestsPerSecond,
+			Burst:             in.RateLimit.Burst,
+		}
+	}
+	return out
+}
+
+func networkToHub(in *NetworkSpec) *v1alpha1.NetworkSpec {
+	if in == nil {
+		return nil
+	}
+	in = in.DeepCopy()
+	out := &v1alpha1.NetworkSpec{AllowFrom: functionNamesToHub(in.AllowFrom)}
+	if in.Egress != nil {
+		out.Egress = &v1alpha1.EgressSpec{
+			CIDRs:     cidrsToHub(in.Egress.CIDRs),
+			Functions: functionNamesToHub(in.Egress.Functions),
+			DNS:       in.Egress.DNS,
+		}
+	}
+	return out
+}
+
+func networkFromHub(in *v1alpha1.NetworkSpec) *NetworkSpec {
+	if in == nil {
+		return nil
+	}
+	in = in.DeepCopy()
+	out := &NetworkSpec{AllowFrom: functionNamesFromHub(in.AllowFrom)}
+	if in.Egress != nil {
+		out.Egress = &EgressSpec{
+			CIDRs:     cidrsFromHub(in.Egress.CIDRs),
+			Functions: functionNamesFromHub(in.Egress.Functions),
+			DNS:       in.Egress.DNS,
+		}
+	}
+	return out
+}
+
+func functionNamesToHub(in []FunctionName) []v1alpha1.FunctionName {
+	if in == nil {
+		return nil
+	}
+	out := make([]v1alpha1.FunctionName, len(in))
+	for i, name := range in {
+		out[i] = v1alpha1.FunctionName(name)
+	}
+	return out
+}
+
+func functionNamesFromHub(in []v1alpha1.FunctionName) []FunctionName {
+	if in == nil {
+		return nil
+	}
+	out := make([]FunctionName, len(in))
+	for i, name := range in {
+		out[i] = FunctionName(name)
+	}
+	return out
+}
+
+func cidrsToHub(in []CIDR) []v1alpha1.CIDR {
+	if in == nil {
+		return nil
+	}
+	out := make([]v1alpha1.CIDR, len(in))
+	for i, cidr := range in {
+		out[i] = v1alpha1.CIDR(cidr)
+	}
+	return out
+}
+
+func cidrsFromHub(in []v1alpha1.CIDR) []CIDR {
+	if in == nil {
+		return nil
+	}
+	out := make([]CIDR, len(in))
+	for i, cidr := range in {
+		out[i] = CIDR(cidr)
+	}
+	return out
+}
+
+func originsToHub(in []Origin) []v1alpha1.Origin {
+	if in == nil {
+		return nil
+	}
+	out := make([]v1alpha1.Origin, len(in))
+	for i, origin := range in {
+		out[i] = v1alpha1.Origin(origin)
+	}
+	return out
+}
+
+func originsFromHub(in []v1alpha1.Origin) []Origin {
+	if in == nil {
+		return nil
+	}
+	out := make([]Origin, len(in))
+	for i, origin := range in {
+		out[i] = Origin(origin)
+	}
+	return out
+}
+
+func methodsToHub(in []HTTPMethod) []v1alpha1.HTTPMethod {
+	if in == nil {
+		return nil
+	}
+	out := make([]v1alpha1.HTTPMethod, len(in))
+	for i, method := range in {
+		out[i] = v1alpha1.HTTPMethod(method)
+	}
+	return out
+}
+
+func methodsFromHub(in []v1alpha1.HTTPMethod) []HTTPMethod {
+	if in == nil {
+		return nil
+	}
+	out := make([]HTTPMethod, len(in))
+	for i, method := range in {
+		out[i] = HTTPMethod(method)
+	}
+	return out
+}
+
+func headersToHub(in []HeaderName) []v1alpha1.HeaderName {
+	if in == nil {
+		return nil
+	}
+	out := make([]v1alpha1.HeaderName, len(in))
+	for i, header := range in {
+		out[i] = v1alpha1.HeaderName(header)
+	}
+	return out
+}
+
+func headersFromHub(in []v1alpha1.HeaderName) []HeaderName {
+	if in == nil {
+		return nil
+	}
+	out := make([]HeaderName, len(in))
+	for i, header := range in {
+		out[i] = HeaderName(header)
+	}
+	return out
+}
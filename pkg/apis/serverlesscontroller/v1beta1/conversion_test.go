@@ -0,0 +1,121 @@
+package v1beta1
+
+import (
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+
+	fuzz "github.com/google/gofuzz"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+)
+
+// fuzzIterations is the number of random objects converted back and forth
+const fuzzIterations = 1000
+
+// defaultFuzzSeed keeps the runs reproducible, FUZZ_SEED tries other objects
+const defaultFuzzSeed = 1
+
+func newFuzzer(t *testing.T) *fuzz.Fuzzer {
+	seed := int64(defaultFuzzSeed)
+	if env := os.Getenv("FUZZ_SEED"); env != "" {
+		var err error
+		if seed, err = strconv.ParseInt(env, 10, 64); err != nil {
+			t.Fatalf("invalid FUZZ_SEED: %v", err)
+		}
+	}
+	t.Logf("fuzzer seed %d", seed)
+	return fuzz.New().NilChance(.3).NumElements(0, 3).RandSource(rand.NewSource(seed)).Funcs(
+		// the fields of a quantity are unexported, so it is never fuzzed
+		func(q *resource.Quantity, c fuzz.Continue) {
+			*q = *resource.NewQuantity(c.Int63n(1<<30), resource.BinarySI)
+		},
+		// some sources are set through the annotation of v1alpha1
+		func(meta *metav1.ObjectMeta, c fuzz.Continue) {
+			c.FuzzNoCustom(meta)
+			if c.RandBool() {
+				if meta.Annotations == nil {
+					meta.Annotations = map[string]string{}
+				}
+				meta.Annotations[SourceAnnotation] = c.RandString()
+			}
+		},
+	)
+}
+
+func TestRoundTripFromHub(t *testing.T) {
+	f := newFuzzer(t)
+	for i := 0; i < fuzzIterations; i++ {
+		hub := &v1alpha1.ServerlessFunc{}
+		f.Fuzz(hub)
+		hub.TypeMeta = metav1.TypeMeta{}
+
+		spoke := &ServerlessFunc{}
+		spoke.ConvertFrom(hub)
+		got := &v1alpha1.ServerlessFunc{}
+		spoke.ConvertTo(got)
+		if !equality.Semantic.DeepEqual(hub, got) {
+			t.Fatalf("v1alpha1 round trip lost data:\n%#v\nbecame\n%#v", hub, got)
+		}
+	}
+}
+
+func TestRoundTripToHub(t *testing.T) {
+	f := newFuzzer(t)
+	for i := 0; i < fuzzIterations; i++ {
+		spoke := &ServerlessFunc{}
+		f.Fuzz(spoke)
+		spoke.TypeMeta = metav1.TypeMeta{}
+		// the annotation is where v1alpha1 keeps the source
+		delete(spoke.Annotations, SourceAnnotation)
+
+		hub := &v1alpha1.ServerlessFunc{}
+		spoke.ConvertTo(hub)
+		got := &ServerlessFunc{}
+		got.ConvertFrom(hub)
+		if !equality.Semantic.DeepEqual(spoke, got) {
+			t.Fatalf("v1beta1 round trip lost data:\n%#v\nbecame\n%#v", spoke, got)
+		}
+	}
+}
+
+func TestConvertToHub(t *testing.T) {
+	replicas := int32(2)
+	spoke := &ServerlessFunc{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: metav1.NamespaceDefault},
+		Spec: ServerlessFuncSpec{
+			Artifact: ArtifactSpec{Image: "hello", Version: "v1", Source: "git@example.com:hello@abc123"},
+			Runtime:  RuntimeSpec{Protocol: ProtocolGRPC},
+			Scaling:  ScalingSpec{Replicas: &replicas},
+			Routing:  RoutingSpec{Auth: &AuthSpec{Type: AuthTypeAPIKey, SecretName: "keys"}},
+		},
+	}
+	hub := &v1alpha1.ServerlessFunc{}
+	spoke.ConvertTo(hub)
+
+	expected := &v1alpha1.ServerlessFunc{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "hello",
+			Namespace:   metav1.NamespaceDefault,
+			Annotations: map[string]string{SourceAnnotation: "git@example.com:hello@abc123"},
+		},
+		Spec: v1alpha1.FooSpec{
+			Image:    "hello",
+			Version:  "v1",
+			Replicas: &replicas,
+			Protocol: v1alpha1.ProtocolGRPC,
+			Auth:     &v1alpha1.AuthSpec{Type: v1alpha1.AuthTypeAPIKey, SecretName: "keys"},
+		},
+	}
+	if !equality.Semantic.DeepEqual(hub, expected) {
+		t.Errorf("expected %#v, got %#v", expected, hub)
+	}
+	// the spoke is left alone
+	if spoke.Annotations != nil {
+		t.Errorf("expected no annotations on the converted object, got %v", spoke.Annotations)
+	}
+}
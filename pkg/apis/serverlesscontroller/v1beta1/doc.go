@@ -0,0 +1,7 @@
+// +k8s:deepcopy-gen=package
+// +groupName=serverlesscontroller.peizhong.io
+
+// Package v1beta1 is the v1beta1 version of the API, whose spec groups the
+// fields of v1alpha1 in sections. It converts through v1alpha1, the storage
+// version.
+package v1beta1
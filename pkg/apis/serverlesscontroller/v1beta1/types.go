@@ -0,0 +1,320 @@
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=serverlessfuncs,singular=serverlessfunc,shortName=sf,categories=serverless
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.spec.artifact.image`
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.spec.artifact.version`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Available",type=integer,JSONPath=`.status.availableReplicas`
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.url`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ServerlessFunc is an executable served over http by the pilot, behind the
+// ingress controller
+type ServerlessFunc struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ServerlessFuncSpec `json:"spec"`
+	// +optional
+	Status ServerlessFuncStatus `json:"status,omitempty"`
+}
+
+// ServerlessFuncSpec is the desired state of a function
+type ServerlessFuncSpec struct {
+	// Artifact is the executable of the function
+	Artifact ArtifactSpec `json:"artifact"`
+	// Runtime is how the pods of the function serve requests
+	// +optional
+	Runtime RuntimeSpec `json:"runtime,omitempty"`
+	// Scaling is how many pods run the function
+	// +optional
+	Scaling ScalingSpec `json:"scaling,omitempty"`
+	// Routing is who may reach the function, and how it reaches others
+	// +optional
+	Routing RoutingSpec `json:"routing,omitempty"`
+	// DeletionPolicy is what happens to the objects of the function when it
+	// is deleted, Delete by default
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// ArtifactSpec is the executable of a function and its version
+type ArtifactSpec struct {
+	// Image is the name of the executable, run from /app. A new executable
+	// is a new function, so it cannot be changed.
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="field is immutable, change spec.artifact.version to roll out a new build"
+	Image string `json:"image"`
+	// Version is a version such as 1.2.3 or v1.2.3-rc.1, a new version
+	// rolls out new pods
+	// +optional
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^v?[0-9]+(\.[0-9]+){0,2}(-[0-9A-Za-z.-]+)?$`
+	Version string `json:"version,omitempty"`
+	// Source records where the executable was built from, e.g. a repository
+	// at a commit. The controller does not use it, v1alpha1 keeps it in the
+	// SourceAnnotation.
+	// +optional
+	Source string `json:"source,omitempty"`
+}
+
+// RuntimeSpec is how the pods of a function serve requests
+type RuntimeSpec struct {
+	// Protocol is the protocol the function is served with, http by default. grpc
+	// and websocket are only proxied through the ingress of the nginx profile,
+	// the shared ingress routes them as HTTP/1.1
+	// +optional
+	Protocol Protocol `json:"protocol,omitempty"`
+	// HTTP tunes how the function serves http, the pilot defaults when empty
+	// +optional
+	HTTP *HTTPSpec `json:"http,omitempty"`
+	// Resources are set by the defaulting webhook from the limits of the
+	// deployment section of the controller config when left out
+	// +optional
+	Resources *ResourcesSpec `json:"resources,omitempty"`
+}
+
+// ResourcesSpec are the resource limits of the containers of a function
+type ResourcesSpec struct {
+	// Pilot limits the container serving the requests of the function
+	// +optional
+	Pilot corev1.ResourceList `json:"pilot,omitempty"`
+	// RPCServer limits the container running the executable of the function
+	// +optional
+	RPCServer corev1.ResourceList `json:"rpcServer,omitempty"`
+}
+
+// ScalingSpec is how many pods run a function
+type ScalingSpec struct {
+	// Replicas is set by the defaulting webhook when left out
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Replicas *int32 `json:"replicas,omitempty"`
+}
+
+// RoutingSpec is the traffic allowed to and from a function
+type RoutingSpec struct {
+	// Auth authenticates calls to the function, none when empty
+	// +optional
+	Auth *AuthSpec `json:"auth,omitempty"`
+	// Network controls access between functions, only the ingress may
+	// reach the function by default
+	// +optional
+	Network *NetworkSpec `json:"network,omitempty"`
+}
+
+// Protocol is the protocol a function is served with
+// +kubebuilder:validation:Enum=http;h2c;grpc;websocket
+type Protocol string
+
+const (
+	ProtocolHTTP Protocol = "http"
+	// ProtocolH2C is HTTP/2 without TLS
+	ProtocolH2C Protocol = "h2c"
+	// ProtocolGRPC also exposes the rpcserver port of the function through its Service
+	ProtocolGRPC      Protocol = "grpc"
+	ProtocolWebSocket Protocol = "websocket"
+)
+
+// DeletionPolicy is what happens to the objects of a function when it is
+// deleted
+// +kubebuilder:validation:Enum=Delete;Orphan
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the objects along with the function
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan leaves the Deployment and the Service of the
+	// function running, without their owner references, so that a new
+	// function may adopt them
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+// AuthType is the way callers of a function authenticate
+// +kubebuilder:validation:Enum=apiKey;basic;jwt
+type AuthType string
+
+const (
+	// AuthTypeAPIKey expects one of the keys stored in the Secret in the X-API-Key header
+	AuthTypeAPIKey AuthType = "apiKey"
+	// AuthTypeBasic expects basic auth credentials matching the htpasswd file stored in the Secret
+	AuthTypeBasic AuthType = "basic"
+	// AuthTypeJWT expects a bearer token signed by a key of the JWKS file
+	AuthTypeJWT AuthType = "jwt"
+)
+
+// AuthSpec is the authentication required to invoke a function. Failed
+// attempts are counted by the pilot in its serverless_pilot_auth_failures_total
+// metric, labeled by auth type and reason. Under the nginx ingress profile,
+// basic auth is checked by ingress-nginx, which counts the failures in its
+// nginx_ingress_controller_requests metric with status 401.
+// +kubebuilder:validation:XValidation:rule="self.type == 'jwt' || has(self.secretName)",message="secretName is required by the apiKey and basic types"
+// +kubebuilder:validation:XValidation:rule="self.type != 'jwt' || has(self.jwt)",message="jwt is required by the jwt type"
+type AuthSpec struct {
+	Type AuthType `json:"type"`
+	// SecretName is the Secret holding the api keys, one per data entry, or
+	// the htpasswd file under the "auth" key for basic auth
+	SecretName string `json:"secretName,omitempty"`
+	// JWT is required for the jwt type
+	JWT *JWTAuth `json:"jwt,omitempty"`
+	// RateLimit applies to each api key, user or token subject
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+}
+
+// JWTAuth is how bearer tokens are validated
+type JWTAuth struct {
+	// JWKSConfigMap is the ConfigMap holding the JWKS file
+	// +kubebuilder:validation:MinLength=1
+	JWKSConfigMap string `json:"jwksConfigMap"`
+	// JWKSKey is the key of the JWKS file in the ConfigMap, jwks.json by
+	// default
+	JWKSKey   string   `json:"jwksKey,omitempty"`
+	Issuer    string   `json:"issuer,omitempty"`
+	Audiences []string `json:"audiences,omitempty"`
+}
+
+// NetworkSpec is the traffic allowed to and from a function besides the
+// traffic from the ingress controller
+type NetworkSpec struct {
+	// AllowFrom are the functions of the same namespace allowed to call this one
+	AllowFrom []FunctionName `json:"allowFrom,omitempty"`
+	// Egress restricts the outgoing traffic when set
+	Egress *EgressSpec `json:"egress,omitempty"`
+}
+
+// EgressSpec is the outgoing traffic allowed from a function
+type EgressSpec struct {
+	CIDRs []CIDR `json:"cidrs,omitempty"`
+	// Functions of the same namespace this function may call
+	Functions []FunctionName `json:"functions,omitempty"`
+	// DNS allows lookups against the cluster DNS
+	DNS bool `json:"dns,omitempty"`
+}
+
+// FunctionName is the name of a function of the same namespace
+// +kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+type FunctionName string
+
+// CIDR is a block of IP addresses, e.g. 10.0.0.0/8
+// +kubebuilder:validation:Pattern=`^[0-9a-fA-F.:]+/[0-9]+$`
+type CIDR string
+
+// HTTPSpec is how the requests to a function are served
+type HTTPSpec struct {
+	CORS *CORSSpec `json:"cors,omitempty"`
+	// RequestTimeout bounds the time to serve a request
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ns|us|ms|s|m|h))+$`
+	RequestTimeout *metav1.Duration `json:"requestTimeout,omitempty"`
+	// IdleTimeout closes keep-alive connections without requests
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ns|us|ms|s|m|h))+$`
+	IdleTimeout *metav1.Duration `json:"idleTimeout,omitempty"`
+	// MaxRequestSize bounds the size of the request body
+	MaxRequestSize *resource.Quantity `json:"maxRequestSize,omitempty"`
+}
+
+// CORSSpec is the cross-origin resource sharing policy of a function
+type CORSSpec struct {
+	AllowOrigins     []Origin     `json:"allowOrigins,omitempty"`
+	AllowMethods     []HTTPMethod `json:"allowMethods,omitempty"`
+	AllowHeaders     []HeaderName `json:"allowHeaders,omitempty"`
+	AllowCredentials bool         `json:"allowCredentials,omitempty"`
+}
+
+// Origin is an origin allowed by a CORS policy, e.g. https://example.com, or
+// * for any
+// +kubebuilder:validation:Pattern=`^(\*|https?://[^/\s]+)$`
+type Origin string
+
+// HTTPMethod is a method allowed by a CORS policy, or * for any
+// +kubebuilder:validation:Enum=GET;HEAD;POST;PUT;PATCH;DELETE;OPTIONS;*
+type HTTPMethod string
+
+// HeaderName is a request header allowed by a CORS policy, or * for any
+// +kubebuilder:validation:Pattern=`^(\*|[A-Za-z0-9-]+)$`
+type HeaderName string
+
+// RateLimit is a token bucket limit
+type RateLimit struct {
+	// +kubebuilder:validation:Minimum=1
+	RequestsPerSecond int32 `json:"requestsPerSecond"`
+	// +kubebuilder:validation:Minimum=0
+	Burst int32 `json:"burst,omitempty"`
+}
+
+// ServerlessFuncStatus is the observed state of a function, reported by the
+// controller
+type ServerlessFuncStatus struct {
+	AvailableReplicas int32 `json:"availableReplicas"`
+	// Ready is the number of ready replicas out of the desired ones, e.g.
+	// 1/2, as shown by kubectl get
+	Ready string `json:"ready,omitempty"`
+	// URL is the path the function is served at on the hosts of the
+	// ingress controller
+	URL string `json:"url,omitempty"`
+	// ObjectNames are the names of the objects of the function, recorded
+	// when they are first created
+	ObjectNames *ObjectNames `json:"objectNames,omitempty"`
+	// LastHandledReconcileRequest is the value of the reconcile-request
+	// annotation of the last full reconcile of the function
+	LastHandledReconcileRequest string `json:"lastHandledReconcileRequest,omitempty"`
+	// Phase is the phase of the rollout of the Deployment of the function
+	Phase RolloutPhase `json:"phase,omitempty"`
+	// Reason and Message tell why the rollout is in its phase, mostly taken
+	// from the conditions of the Deployment
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Conditions report errors retrying cannot fix, until the next
+	// successful sync
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ObjectNames are the names of the objects of a function, derived from its
+// name and truncated to the length of a DNS-1035 label
+type ObjectNames struct {
+	Deployment string `json:"deployment,omitempty"`
+	Service    string `json:"service,omitempty"`
+	// Ingress is the ingress of the function under the nginx profile
+	Ingress       string `json:"ingress,omitempty"`
+	NetworkPolicy string `json:"networkPolicy,omitempty"`
+}
+
+// RolloutPhase is the phase of the rollout of a function
+// +kubebuilder:validation:Enum=Progressing;Available;Stalled
+type RolloutPhase string
+
+const (
+	// RolloutPhaseProgressing is a rollout waiting for pods to be updated
+	// or to become available
+	RolloutPhaseProgressing RolloutPhase = "Progressing"
+	// RolloutPhaseAvailable is a complete rollout
+	RolloutPhaseAvailable RolloutPhase = "Available"
+	// RolloutPhaseStalled is a rollout that stopped making progress, e.g.
+	// pods cannot be created or exceeded the progress deadline
+	RolloutPhaseStalled RolloutPhase = "Stalled"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+
+// ServerlessFuncList is a list of ServerlessFuncs
+type ServerlessFuncList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []ServerlessFunc `json:"items"`
+}
@@ -0,0 +1,133 @@
+// Package cli implements the commands of sfctl, the command-line client of
+// ServerlessFuncs, which is also packaged as the kubectl serverless plugin.
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned"
+)
+
+// NewCommand returns the root command of the client, run as name, e.g.
+// sfctl, or kubectl serverless for the plugin. It prints to out and errOut.
+func NewCommand(name string, out, errOut io.Writer) *cobra.Command {
+	return newRootCommand(&options{name: name, out: out, errOut: errOut})
+}
+
+// options are the flags shared by every command, and the clients built from
+// them. The clients are only built when unset, so that tests set fake ones.
+type options struct {
+	// name is the command the client is run as, for its help
+	name string
+
+	kubeconfig string
+	context    string
+	namespace  string
+
+	out    io.Writer
+	errOut io.Writer
+	// now is the current time, for the ages of objects
+	now func() time.Time
+
+	restConfig *rest.Config
+	kubeclient kubernetes.Interface
+	crdclient  versioned.Interface
+}
+
+func newRootCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		// cobra names a command after the first word of its use, so the
+		// plugin is named after its executable
+		Use:   strings.ReplaceAll(o.name, " ", "-"),
+		Short: "Manage ServerlessFuncs",
+		Long: o.name + ` deploys and manages the functions run by the serverless controller.
+
+Functions are ServerlessFuncs, which kubectl also reaches as sf.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	cmd.SetOut(o.out)
+	cmd.SetErr(o.errOut)
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&o.kubeconfig, "kubeconfig", "", "Path to a kubeconfig. Defaults to $KUBECONFIG or ~/.kube/config.")
+	flags.StringVar(&o.context, "context", "", "Kubeconfig context to use instead of the current one.")
+	flags.StringVarP(&o.namespace, "namespace", "n", "", "Namespace of the functions. Defaults to the namespace of the context.")
+
+	cmd.AddCommand(
+		newDeployCommand(o),
+		newListCommand(o),
+		newDescribeCommand(o),
+		newLogsCommand(o),
+		newInvokeCommand(o),
+		newScaleCommand(o),
+		newRollbackCommand(o),
+		newDeleteCommand(o),
+		newTreeCommand(o),
+	)
+	return cmd
+}
+
+// complete builds the clients of o from the kubeconfig, and defaults the
+// namespace to the one of its context.
+func (o *options) complete() error {
+	if o.now == nil {
+		o.now = time.Now
+	}
+	if o.kubeclient != nil && o.crdclient != nil {
+		if o.namespace == "" {
+			o.namespace = "default"
+		}
+		return nil
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if o.kubeconfig != "" {
+		loadingRules.ExplicitPath = o.kubeconfig
+	}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{CurrentContext: o.context})
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return fmt.Errorf("load client configuration: %v", err)
+	}
+	if o.namespace == "" {
+		if o.namespace, _, err = clientConfig.Namespace(); err != nil {
+			return err
+		}
+	}
+	o.restConfig = restConfig
+	if o.kubeclient, err = kubernetes.NewForConfig(restConfig); err != nil {
+		return err
+	}
+	o.crdclient, err = versioned.NewForConfig(restConfig)
+	return err
+}
+
+// completeFunctions completes the first argument of a command with the names
+// of the functions of the namespace.
+func completeFunctions(o *options) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		if err := o.complete(); err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		foos, err := o.crdclient.ServerlesscontrollerV1alpha1().ServerlessFuncs(o.namespace).List(cmd.Context(), metav1.ListOptions{})
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		var names []string
+		for _, foo := range foos.Items {
+			names = append(names, foo.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -0,0 +1,478 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	crdfake "github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned/fake"
+	"github.com/peizhong/serverless-controller/pkg/tools"
+)
+
+var now = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func newFoo(name, version string, replicas int32) *serverlessv1alpha1.ServerlessFunc {
+	return &serverlessv1alpha1.ServerlessFunc{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         metav1.NamespaceDefault,
+			UID:               types.UID(name + "-uid"),
+			CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+		},
+		Spec: serverlessv1alpha1.FooSpec{
+			Image:    name,
+			Version:  version,
+			Replicas: int32Ptr(replicas),
+		},
+	}
+}
+
+// newReplicaSet returns revision number of the Deployment of foo, running
+// version.
+func newReplicaSet(foo *serverlessv1alpha1.ServerlessFunc, deployment *appsv1.Deployment, number, version string) *appsv1.ReplicaSet {
+	return &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            deployment.Name + "-" + number,
+			Namespace:       foo.Namespace,
+			UID:             types.UID(deployment.Name + "-" + number + "-uid"),
+			Labels:          map[string]string{"serverlessfunc": "func-" + foo.Name},
+			Annotations:     map[string]string{revisionAnnotation: number},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(deployment, appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+		},
+		Spec: appsv1.ReplicaSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "pilot"},
+						{Name: "rpcserver", Command: []string{"/app/" + foo.Spec.Image, "-v", version}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func newDeployment(foo *serverlessv1alpha1.ServerlessFunc) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "func-" + foo.Name + "-deployment",
+			Namespace:       foo.Namespace,
+			UID:             types.UID(foo.Name + "-deployment-uid"),
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(foo, serverlessv1alpha1.SchemeGroupVersion.WithKind("ServerlessFunc"))},
+		},
+	}
+}
+
+func newPod(foo *serverlessv1alpha1.ServerlessFunc, name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: foo.Namespace,
+			Labels:    map[string]string{"serverlessfunc": "func-" + foo.Name},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+type fixture struct {
+	t          *testing.T
+	kubeclient *k8sfake.Clientset
+	crdclient  *crdfake.Clientset
+}
+
+func newFixture(t *testing.T, kubeobjects []runtime.Object, objects ...runtime.Object) *fixture {
+	return &fixture{
+		t:          t,
+		kubeclient: k8sfake.NewSimpleClientset(kubeobjects...),
+		crdclient:  crdfake.NewSimpleClientset(objects...),
+	}
+}
+
+// run runs sfctl with args and returns what it printed.
+func (f *fixture) run(args ...string) (string, error) {
+	out := &bytes.Buffer{}
+	o := &options{
+		name:       "sfctl",
+		out:        out,
+		errOut:     ioutil.Discard,
+		now:        func() time.Time { return now },
+		kubeclient: f.kubeclient,
+		crdclient:  f.crdclient,
+	}
+	cmd := newRootCommand(o)
+	cmd.SetArgs(args)
+	err := cmd.ExecuteContext(context.Background())
+	return out.String(), err
+}
+
+func (f *fixture) getFoo(name string) *serverlessv1alpha1.ServerlessFunc {
+	foo, err := f.crdclient.ServerlesscontrollerV1alpha1().ServerlessFuncs(metav1.NamespaceDefault).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		f.t.Fatalf("get function %s: %v", name, err)
+	}
+	return foo
+}
+
+func TestList(t *testing.T) {
+	echo := newFoo("echo", "1.2.0", 2)
+	echo.Status = serverlessv1alpha1.FooStatus{
+		AvailableReplicas: 2,
+		Ready:             "2/2",
+		URL:               "/serverlessfunc/echo/",
+		Phase:             serverlessv1alpha1.RolloutPhaseAvailable,
+	}
+	hello := newFoo("hello", "", 1)
+	f := newFixture(t, nil, echo, hello)
+
+	out, err := f.run("list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `NAME    IMAGE   VERSION   READY    AVAILABLE   PHASE       URL                     AGE
+echo    echo    1.2.0     2/2      2           Available   /serverlessfunc/echo/   120m
+hello   hello   <none>    <none>   0           <none>      <none>                  120m
+`
+	if out != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", out, expected)
+	}
+}
+
+func TestDeployFromDirectory(t *testing.T) {
+	dir := t.TempDir()
+	manifest := `apiVersion: serverlesscontroller.peizhong.io/v1beta1
+kind: ServerlessFunc
+metadata:
+  name: echo
+spec:
+  artifact:
+    image: echo
+    version: 1.2.0
+  scaling:
+    replicas: 2
+`
+	if err := os.WriteFile(filepath.Join(dir, manifestFile), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f := newFixture(t, nil)
+
+	for _, step := range []struct {
+		args     []string
+		expected string
+		version  string
+	}{
+		{[]string{"deploy", dir}, "serverlessfunc/echo created\n", "1.2.0"},
+		{[]string{"deploy", dir}, "serverlessfunc/echo unchanged\n", "1.2.0"},
+		{[]string{"deploy", dir, "--version", "1.3.0"}, "serverlessfunc/echo configured\n", "1.3.0"},
+	} {
+		out, err := f.run(step.args...)
+		if err != nil {
+			t.Fatalf("%v: %v", step.args, err)
+		}
+		if out != step.expected {
+			t.Errorf("%v: expected %q, got %q", step.args, step.expected, out)
+		}
+		foo := f.getFoo("echo")
+		if foo.Spec.Image != "echo" || foo.Spec.Version != step.version || *foo.Spec.Replicas != 2 {
+			t.Errorf("%v: unexpected spec %+v", step.args, foo.Spec)
+		}
+	}
+}
+
+func TestDeployFromFlags(t *testing.T) {
+	f := newFixture(t, nil, newFoo("echo", "1.2.0", 2))
+
+	if _, err := f.run("deploy", "--name", "echo", "--version", "1.3.0"); err != nil {
+		t.Fatal(err)
+	}
+	foo := f.getFoo("echo")
+	if foo.Spec.Image != "echo" || foo.Spec.Version != "1.3.0" || *foo.Spec.Replicas != 2 {
+		t.Errorf("unexpected spec %+v", foo.Spec)
+	}
+
+	if _, err := f.run("deploy", "--name", "echo", "--image", "other"); err == nil {
+		t.Errorf("expected changing the image to be rejected")
+	}
+	if _, err := f.run("deploy", "--name", "new", "--version", "1.0.0"); err == nil {
+		t.Errorf("expected a new function without image to be rejected")
+	}
+}
+
+func TestScale(t *testing.T) {
+	f := newFixture(t, nil, newFoo("echo", "1.2.0", 2))
+
+	out, err := f.run("scale", "echo", "--replicas", "5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "serverlessfunc/echo scaled\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+	if replicas := *f.getFoo("echo").Spec.Replicas; replicas != 5 {
+		t.Errorf("expected 5 replicas, got %d", replicas)
+	}
+	if _, err := f.run("scale", "echo", "--replicas", "101"); err == nil {
+		t.Errorf("expected too many replicas to be rejected")
+	}
+}
+
+func TestRollback(t *testing.T) {
+	foo := newFoo("echo", "1.3.0", 2)
+	deployment := newDeployment(foo)
+	other := newDeployment(newFoo("other", "", 1))
+	kubeobjects := []runtime.Object{
+		deployment,
+		newReplicaSet(foo, deployment, "1", "1.1.0"),
+		newReplicaSet(foo, deployment, "2", "1.2.0"),
+		newReplicaSet(foo, deployment, "3", "1.3.0"),
+		// not a revision of echo
+		newReplicaSet(foo, other, "4", "9.9.9"),
+	}
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"rollback", "echo"}, "1.2.0"},
+		{[]string{"rollback", "echo", "--to-revision", "1"}, "1.1.0"},
+	}
+	for _, test := range tests {
+		f := newFixture(t, kubeobjects, foo)
+		if _, err := f.run(test.args...); err != nil {
+			t.Fatalf("%v: %v", test.args, err)
+		}
+		if version := f.getFoo("echo").Spec.Version; version != test.expected {
+			t.Errorf("%v: expected version %s, got %s", test.args, test.expected, version)
+		}
+	}
+
+	f := newFixture(t, kubeobjects, foo)
+	if _, err := f.run("rollback", "echo", "--to-revision", "4"); err == nil {
+		t.Errorf("expected a revision of another deployment to be rejected")
+	}
+
+	// the version of a function that is not rolled out yet is not a revision
+	pending := foo.DeepCopy()
+	pending.Spec.Version = "1.4.0"
+	f = newFixture(t, kubeobjects, pending)
+	if _, err := f.run("rollback", "echo"); err != nil {
+		t.Fatal(err)
+	}
+	if version := f.getFoo("echo").Spec.Version; version != "1.3.0" {
+		t.Errorf("expected version 1.3.0, got %s", version)
+	}
+
+	// a revision made by a change of the pod template keeps the version of
+	// the revision before it
+	reconfigured := newFoo("echo", "1.2.0", 2)
+	reconfiguredDeployment := newDeployment(reconfigured)
+	f = newFixture(t, []runtime.Object{
+		reconfiguredDeployment,
+		newReplicaSet(reconfigured, reconfiguredDeployment, "1", "1.1.0"),
+		newReplicaSet(reconfigured, reconfiguredDeployment, "2", "1.2.0"),
+		newReplicaSet(reconfigured, reconfiguredDeployment, "3", "1.2.0"),
+	}, reconfigured)
+	if _, err := f.run("rollback", "echo"); err != nil {
+		t.Fatal(err)
+	}
+	if version := f.getFoo("echo").Spec.Version; version != "1.1.0" {
+		t.Errorf("expected version 1.1.0, got %s", version)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	foo := newFoo("echo", "1.2.0", 2)
+	foo.Status.URL = "/serverlessfunc/echo/"
+	foo.Status.Conditions = []metav1.Condition{{
+		Type:               serverlessv1alpha1.ConditionReconcileError,
+		Status:             metav1.ConditionTrue,
+		Reason:             "ErrResourceExists",
+		Message:            "Resource \"func-echo-service\" already exists",
+		LastTransitionTime: metav1.NewTime(now.Add(-time.Minute)),
+	}}
+	deployment := newDeployment(foo)
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "echo.1", Namespace: foo.Namespace},
+		InvolvedObject: corev1.ObjectReference{Kind: "ServerlessFunc", Name: foo.Name, UID: foo.UID},
+		Type:           corev1.EventTypeNormal,
+		Reason:         "Synced",
+		Message:        "Foo synced successfully",
+		LastTimestamp:  metav1.NewTime(now.Add(-30 * time.Second)),
+	}
+	f := newFixture(t, []runtime.Object{deployment, newReplicaSet(foo, deployment, "1", "1.2.0"), event}, foo)
+
+	out, err := f.run("describe", "echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"Version:    1.2.0\n",
+		"URL:        /serverlessfunc/echo/\n",
+		"  ReconcileError  True    ErrResourceExists  60s  Resource \"func-echo-service\" already exists\n",
+		"  1         1.2.0    0/0    func-echo-deployment-1  <unknown>\n",
+		"  Normal  Synced  30s  Foo synced successfully\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in output:\n%s", expected, out)
+		}
+	}
+}
+
+func TestLogs(t *testing.T) {
+	foo := newFoo("echo", "1.2.0", 2)
+	f := newFixture(t, []runtime.Object{newPod(foo, "echo-a"), newPod(foo, "echo-b")}, foo)
+
+	out, err := f.run("logs", "echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	sort.Strings(lines)
+	expected := []string{"[echo-a] fake logs", "[echo-b] fake logs"}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %q, got %q", expected, lines)
+	}
+	for _, action := range f.kubeclient.Actions() {
+		if action.GetSubresource() != "log" {
+			continue
+		}
+		if container := action.(interface{ GetValue() interface{} }).GetValue().(*corev1.PodLogOptions).Container; container != "rpcserver" {
+			t.Errorf("expected the logs of rpcserver, got %s", container)
+		}
+	}
+
+	if _, err := newFixture(t, nil, foo).run("logs", "echo"); err == nil {
+		t.Errorf("expected an error for a function without pods")
+	}
+}
+
+func TestTree(t *testing.T) {
+	foo := newFoo("echo", "1.3.0", 2)
+	foo.Status.Ready = "1/2"
+	foo.Status.Phase = serverlessv1alpha1.RolloutPhaseProgressing
+	deployment := newDeployment(foo)
+	deployment.Spec.Replicas = int32Ptr(2)
+	deployment.Status = appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, ReadyReplicas: 1, AvailableReplicas: 1}
+	current := newReplicaSet(foo, deployment, "2", "1.3.0")
+	current.Spec.Replicas = int32Ptr(2)
+	current.Status.ReadyReplicas = 1
+	running := newPod(foo, "echo-running")
+	running.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(current, appsv1.SchemeGroupVersion.WithKind("ReplicaSet"))}
+	running.Spec.Containers = []corev1.Container{{Name: "pilot"}, {Name: "rpcserver"}}
+	running.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "pilot", Ready: true}, {Name: "rpcserver", Ready: true}}
+	crashing := running.DeepCopy()
+	crashing.Name = "echo-crashing"
+	crashing.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "pilot", Ready: true},
+		{Name: "rpcserver", RestartCount: 3, State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+	}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "func-echo-service",
+			Namespace:       foo.Namespace,
+			OwnerReferences: deployment.OwnerReferences,
+		},
+	}
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: service.ObjectMeta,
+		Subsets: []corev1.EndpointSubset{{
+			Addresses:         []corev1.EndpointAddress{{IP: "10.0.0.1"}},
+			NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.2"}},
+		}},
+	}
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "serverless-ingress",
+			Namespace:   foo.Namespace,
+			Annotations: map[string]string{tools.IngressRoutesAnnotation: `{"echo":"/serverlessfunc/echo(/|$)(.*)"}`},
+		},
+	}
+	// a NetworkPolicy with the name of the one of echo, not created by it
+	policy := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "func-echo-networkpolicy", Namespace: foo.Namespace}}
+	f := newFixture(t, []runtime.Object{
+		deployment, current, newReplicaSet(foo, deployment, "1", "1.2.0"),
+		running, crashing, service, endpoints, ingress, policy,
+	}, foo)
+
+	out, err := f.run("tree", "echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `NAME                                      READY   STATUS                                           AGE
+ServerlessFunc/echo                       1/2     Progressing                                      120m
+├─Deployment/func-echo-deployment         1/2     Progressing                                      <unknown>
+│ ├─ReplicaSet/func-echo-deployment-2     1/2     Revision 2 (1.3.0),Current                       <unknown>
+│ │ ├─Pod/echo-crashing                   1/2     CrashLoopBackOff (3 restarts)                    <unknown>
+│ │ └─Pod/echo-running                    2/2     Running                                          <unknown>
+│ └─ReplicaSet/func-echo-deployment-1     0/0     Revision 1 (1.2.0)                               <unknown>
+├─Service/func-echo-service               1/2     Ready                                            <unknown>
+├─Ingress/serverless-ingress              -       Routed /serverlessfunc/echo(/|$)(.*),NoAddress   <unknown>
+└─NetworkPolicy/func-echo-networkpolicy   -       NotControlled                                    -
+`
+	if out != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", out, expected)
+	}
+}
+
+func TestInvokeThroughIngress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.URL.Path != "/serverlessfunc/echo/hello" || r.Header.Get("X-API-Key") != "secret" {
+			http.Error(w, "unexpected request "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+	foo := newFoo("echo", "1.2.0", 2)
+	f := newFixture(t, nil, foo)
+
+	out, err := f.run("invoke", "echo", "--ingress", server.URL, "--path", "hello", "-d", `{"name":"world"}`, "-H", "X-API-Key: secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != `{"name":"world"}` {
+		t.Errorf("unexpected response %q", out)
+	}
+
+	if _, err := f.run("invoke", "echo", "--ingress", server.URL); err == nil {
+		t.Errorf("expected an error for a 404 response")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	f := newFixture(t, nil, newFoo("echo", "1.2.0", 2), newFoo("hello", "", 1))
+
+	out, err := f.run("delete", "echo", "hello", "--orphan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "serverlessfunc/echo deleted\nserverlessfunc/hello deleted\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+	foos, err := f.crdclient.ServerlesscontrollerV1alpha1().ServerlessFuncs(metav1.NamespaceDefault).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(foos.Items) != 0 {
+		t.Errorf("expected every function to be deleted, got %d", len(foos.Items))
+	}
+}
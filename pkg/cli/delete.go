@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newDeleteCommand(o *options) *cobra.Command {
+	var orphan bool
+	cmd := &cobra.Command{
+		Use:   "delete NAME...",
+		Short: "Delete functions",
+		Long: `Delete functions along with their objects, or with --orphan, leave their
+objects running for a new function to adopt.`,
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeFunctions(o),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.complete(); err != nil {
+				return err
+			}
+			for _, name := range args {
+				if err := o.delete(cmd.Context(), name, orphan); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&orphan, "orphan", false, "Leave the objects of the functions running, without their owner references.")
+	return cmd
+}
+
+func (o *options) delete(ctx context.Context, name string, orphan bool) error {
+	options := metav1.DeleteOptions{}
+	if orphan {
+		policy := metav1.DeletePropagationOrphan
+		options.PropagationPolicy = &policy
+	}
+	if err := o.crdclient.ServerlesscontrollerV1alpha1().ServerlessFuncs(o.namespace).Delete(ctx, name, options); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.out, "serverlessfunc/%s deleted\n", name)
+	return nil
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	serverlessv1beta1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1beta1"
+	"github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned/scheme"
+	"github.com/peizhong/serverless-controller/pkg/validation"
+)
+
+// manifestFile is the file of a function directory holding its ServerlessFunc
+const manifestFile = "serverlessfunc.yaml"
+
+type deployOptions struct {
+	*options
+	name     string
+	image    string
+	version  string
+	replicas int32
+	protocol string
+}
+
+func newDeployCommand(o *options) *cobra.Command {
+	d := &deployOptions{options: o}
+	cmd := &cobra.Command{
+		Use:   "deploy [DIR]",
+		Short: "Create or update a function",
+		Long: `Create or update a function from the ` + manifestFile + ` file of DIR, a v1alpha1
+or v1beta1 ServerlessFunc, or from the flags alone. The flags override the
+fields of the file.`,
+		Example: fmt.Sprintf(`  # deploy the function of the current directory
+  %[1]s deploy .
+
+  # roll out version 1.2.0 of the echo executable
+  %[1]s deploy --name echo --image echo --version 1.2.0`, o.name),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.complete(); err != nil {
+				return err
+			}
+			var dir string
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			return d.run(cmd, dir)
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&d.name, "name", "", "Name of the function, required without DIR.")
+	flags.StringVar(&d.image, "image", "", "Name of the executable of the function, run from /app.")
+	flags.StringVar(&d.version, "version", "", "Version of the executable, e.g. 1.2.0.")
+	flags.Int32Var(&d.replicas, "replicas", 0, "Number of pods of the function.")
+	flags.StringVar(&d.protocol, "protocol", "", "Protocol the function is served with: http, h2c, grpc or websocket.")
+	return cmd
+}
+
+func (d *deployOptions) run(cmd *cobra.Command, dir string) error {
+	ctx := cmd.Context()
+	flags := cmd.Flags()
+	foo := &serverlessv1alpha1.ServerlessFunc{}
+	if dir != "" {
+		var err error
+		if foo, err = readManifest(filepath.Join(dir, manifestFile)); err != nil {
+			return err
+		}
+	}
+	if d.name != "" {
+		foo.Name = d.name
+	}
+	if foo.Name == "" {
+		return fmt.Errorf("the function has no name, set --name or deploy a directory")
+	}
+	if foo.Namespace != "" && foo.Namespace != d.namespace && flags.Changed("namespace") {
+		return fmt.Errorf("the function is in namespace %q, not %q", foo.Namespace, d.namespace)
+	}
+	if foo.Namespace == "" {
+		foo.Namespace = d.namespace
+	}
+
+	client := d.crdclient.ServerlesscontrollerV1alpha1().ServerlessFuncs(foo.Namespace)
+	existing, err := client.Get(ctx, foo.Name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err != nil {
+		existing = nil
+	}
+	if dir == "" && existing != nil {
+		// the flags alone change the function as it is
+		foo.Spec = *existing.Spec.DeepCopy()
+	}
+	if d.image != "" {
+		foo.Spec.Image = d.image
+	}
+	if flags.Changed("version") {
+		foo.Spec.Version = d.version
+	}
+	if flags.Changed("replicas") {
+		replicas := d.replicas
+		foo.Spec.Replicas = &replicas
+	}
+	if d.protocol != "" {
+		foo.Spec.Protocol = serverlessv1alpha1.Protocol(d.protocol)
+	}
+
+	if existing == nil {
+		// the status of the manifest, if any, is the controller's to report
+		foo.Status = serverlessv1alpha1.FooStatus{}
+		foo.ResourceVersion = ""
+		if errs := validation.ValidateServerlessFunc(foo); len(errs) > 0 {
+			return fmt.Errorf("invalid function %q: %v", foo.Name, errs.ToAggregate())
+		}
+		if _, err := client.Create(ctx, foo, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+		fmt.Fprintf(d.out, "serverlessfunc/%s created\n", foo.Name)
+		return nil
+	}
+
+	update := existing.DeepCopy()
+	update.Spec = foo.Spec
+	for key, value := range foo.Labels {
+		if update.Labels == nil {
+			update.Labels = map[string]string{}
+		}
+		update.Labels[key] = value
+	}
+	for key, value := range foo.Annotations {
+		if update.Annotations == nil {
+			update.Annotations = map[string]string{}
+		}
+		update.Annotations[key] = value
+	}
+	if equality.Semantic.DeepEqual(update, existing) {
+		fmt.Fprintf(d.out, "serverlessfunc/%s unchanged\n", foo.Name)
+		return nil
+	}
+	if errs := validation.ValidateServerlessFuncUpdate(update, existing); len(errs) > 0 {
+		return fmt.Errorf("invalid function %q: %v", foo.Name, errs.ToAggregate())
+	}
+	if _, err := client.Update(ctx, update, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	fmt.Fprintf(d.out, "serverlessfunc/%s configured\n", foo.Name)
+	return nil
+}
+
+// readManifest reads the ServerlessFunc of file, converted to v1alpha1.
+func readManifest(file string) (*serverlessv1alpha1.ServerlessFunc, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	obj, gvk, err := scheme.Codecs.UniversalDeserializer().Decode(data, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %v", file, err)
+	}
+	switch obj := obj.(type) {
+	case *serverlessv1alpha1.ServerlessFunc:
+		return obj, nil
+	case *serverlessv1beta1.ServerlessFunc:
+		foo := &serverlessv1alpha1.ServerlessFunc{}
+		obj.ConvertTo(foo)
+		return foo, nil
+	default:
+		return nil, fmt.Errorf("%s holds a %s, not a ServerlessFunc", file, gvk.Kind)
+	}
+}
@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+)
+
+func newDescribeCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:               "describe NAME",
+		Short:             "Show the details of a function",
+		Long:              "Show the spec, the status, the conditions, the revisions and the events of a function.",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeFunctions(o),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.complete(); err != nil {
+				return err
+			}
+			return o.describe(cmd.Context(), args[0])
+		},
+	}
+}
+
+func (o *options) describe(ctx context.Context, name string) error {
+	foo, err := o.crdclient.ServerlesscontrollerV1alpha1().ServerlessFuncs(o.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	revisions, err := o.revisions(ctx, foo)
+	if err != nil {
+		return err
+	}
+	events, err := o.eventsOf(ctx, foo)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(o.out, 0, 8, 2, ' ', 0)
+	replicas := "<default>"
+	if foo.Spec.Replicas != nil {
+		replicas = fmt.Sprint(*foo.Spec.Replicas)
+	}
+	protocol := string(foo.Spec.Protocol)
+	if protocol == "" {
+		protocol = string(serverlessv1alpha1.ProtocolHTTP)
+	}
+	fmt.Fprintf(w, "Name:\t%s\n", foo.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", foo.Namespace)
+	fmt.Fprintf(w, "Image:\t%s\n", foo.Spec.Image)
+	fmt.Fprintf(w, "Version:\t%s\n", orNone(foo.Spec.Version))
+	fmt.Fprintf(w, "Replicas:\t%s\n", replicas)
+	fmt.Fprintf(w, "Protocol:\t%s\n", protocol)
+	if foo.Spec.Auth != nil {
+		fmt.Fprintf(w, "Auth:\t%s\n", foo.Spec.Auth.Type)
+	}
+	if foo.Annotations[serverlessv1alpha1.PausedAnnotation] == "true" {
+		fmt.Fprintf(w, "Paused:\ttrue\n")
+	}
+	fmt.Fprintf(w, "URL:\t%s\n", orNone(foo.Status.URL))
+	fmt.Fprintf(w, "Ready:\t%s\n", orNone(foo.Status.Ready))
+	fmt.Fprintf(w, "Available:\t%d\n", foo.Status.AvailableReplicas)
+	fmt.Fprintf(w, "Phase:\t%s\n", orNone(string(foo.Status.Phase)))
+	if foo.Status.Reason != "" {
+		fmt.Fprintf(w, "Reason:\t%s\n", foo.Status.Reason)
+	}
+	if foo.Status.Message != "" {
+		fmt.Fprintf(w, "Message:\t%s\n", foo.Status.Message)
+	}
+	fmt.Fprintf(w, "Age:\t%s\n", o.age(foo.CreationTimestamp))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(o.out, "Conditions:")
+	if len(foo.Status.Conditions) == 0 {
+		fmt.Fprintln(o.out, "  <none>")
+	} else {
+		table(o.out, []string{"Type", "Status", "Reason", "Age", "Message"}, func(row func(...interface{})) {
+			for _, c := range foo.Status.Conditions {
+				row(c.Type, c.Status, c.Reason, o.age(c.LastTransitionTime), c.Message)
+			}
+		})
+	}
+
+	fmt.Fprintln(o.out, "Revisions:")
+	if len(revisions) == 0 {
+		fmt.Fprintln(o.out, "  <none>")
+	} else {
+		table(o.out, []string{"Revision", "Version", "Ready", "ReplicaSet", "Age"}, func(row func(...interface{})) {
+			for _, r := range revisions {
+				row(r.number, orNone(r.version), fmt.Sprintf("%d/%d", r.ready, r.replicas), r.replicaSet, o.age(r.created))
+			}
+		})
+	}
+
+	fmt.Fprintln(o.out, "Events:")
+	if len(events) == 0 {
+		fmt.Fprintln(o.out, "  <none>")
+		return nil
+	}
+	table(o.out, []string{"Type", "Reason", "Age", "Message"}, func(row func(...interface{})) {
+		for _, e := range events {
+			row(e.Type, e.Reason, o.age(lastSeen(e)), strings.TrimSpace(e.Message))
+		}
+	})
+	return nil
+}
+
+// eventsOf returns the events of foo, the oldest first.
+func (o *options) eventsOf(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc) ([]corev1.Event, error) {
+	list, err := o.kubeclient.CoreV1().Events(foo.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// field selectors are not supported by every client, e.g. the fake
+	// ones, so the events are filtered here
+	var events []corev1.Event
+	for _, e := range list.Items {
+		if e.InvolvedObject.UID == foo.UID && e.InvolvedObject.Name == foo.Name {
+			events = append(events, e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return lastSeen(events[i]).Time.Before(lastSeen(events[j]).Time) })
+	return events, nil
+}
+
+// lastSeen returns the time e was last reported.
+func lastSeen(e corev1.Event) metav1.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp
+	}
+	if e.EventTime.Time.IsZero() {
+		return e.CreationTimestamp
+	}
+	return metav1.Time{Time: e.EventTime.Time}
+}
+
+// table writes an indented table of columns to out, with the rows written by
+// rows.
+func table(out io.Writer, columns []string, rows func(row func(...interface{}))) {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "  %s\n", strings.Join(columns, "\t"))
+	dashes := make([]string, len(columns))
+	for i, column := range columns {
+		dashes[i] = strings.Repeat("-", len(column))
+	}
+	fmt.Fprintf(w, "  %s\n", strings.Join(dashes, "\t"))
+	rows(func(values ...interface{}) {
+		cells := make([]string, len(values))
+		for i, value := range values {
+			cells[i] = fmt.Sprint(value)
+		}
+		fmt.Fprintf(w, "  %s\n", strings.Join(cells, "\t"))
+	})
+	w.Flush()
+}
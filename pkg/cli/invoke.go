@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/tools"
+)
+
+// pilotContainer serves the requests to a function
+const pilotContainer = "pilot"
+
+type invokeOptions struct {
+	*options
+	ingress string
+	method  string
+	data    string
+	headers []string
+	path    string
+	include bool
+}
+
+func newInvokeCommand(o *options) *cobra.Command {
+	i := &invokeOptions{options: o}
+	cmd := &cobra.Command{
+		Use:   "invoke NAME",
+		Short: "Call a function over HTTP",
+		Long: `Call a function over HTTP and print the response. The function is called
+through the ingress controller at --ingress, or otherwise through a
+port-forward to one of its running pods, which skips the authentication of
+the ingress.`,
+		Example: fmt.Sprintf(`  # call the echo function through a port-forward
+  %[1]s invoke echo -d '{"name":"world"}'
+
+  # call it through the ingress controller with an api key
+  %[1]s invoke echo --ingress https://functions.example.com -H 'X-API-Key: secret'`, o.name),
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeFunctions(o),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.complete(); err != nil {
+				return err
+			}
+			return i.run(cmd.Context(), args[0])
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&i.ingress, "ingress", "", "Base URL of the ingress controller, e.g. https://functions.example.com. Port-forwards to a pod when empty.")
+	flags.StringVarP(&i.method, "request", "X", "", "HTTP method, POST when --data is set and GET otherwise.")
+	flags.StringVarP(&i.data, "data", "d", "", "Body of the request, or @FILE to read it from FILE, or @- from stdin.")
+	flags.StringArrayVarP(&i.headers, "header", "H", nil, "Header of the request as 'Name: value', may be repeated.")
+	flags.StringVar(&i.path, "path", "/", "Path of the request below the URL of the function.")
+	flags.BoolVarP(&i.include, "include", "i", false, "Print the status and the headers of the response.")
+	return cmd
+}
+
+func (i *invokeOptions) run(ctx context.Context, name string) error {
+	foo, err := i.crdclient.ServerlesscontrollerV1alpha1().ServerlessFuncs(i.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	var url string
+	if i.ingress != "" {
+		functionURL := foo.Status.URL
+		if functionURL == "" {
+			functionURL = tools.GetFunctionURL(foo)
+		}
+		url = strings.TrimSuffix(i.ingress, "/") + functionURL + strings.TrimPrefix(i.path, "/")
+	} else {
+		local, stop, err := i.portForward(ctx, foo)
+		if err != nil {
+			return err
+		}
+		defer close(stop)
+		// the pilot is called directly, without the path the ingress strips
+		url = fmt.Sprintf("http://%s/%s", local, strings.TrimPrefix(i.path, "/"))
+	}
+
+	req, err := i.newRequest(ctx, url)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if i.include {
+		fmt.Fprintf(i.out, "%s %s\n", resp.Proto, resp.Status)
+		resp.Header.Write(i.out)
+		fmt.Fprintln(i.out)
+	}
+	if _, err := io.Copy(i.out, resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("function %q responded %s", name, resp.Status)
+	}
+	return nil
+}
+
+// newRequest returns the request to url set by the flags.
+func (i *invokeOptions) newRequest(ctx context.Context, url string) (*http.Request, error) {
+	var body io.Reader
+	switch {
+	case i.data == "@-":
+		body = os.Stdin
+	case strings.HasPrefix(i.data, "@"):
+		data, err := ioutil.ReadFile(i.data[1:])
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(string(data))
+	case i.data != "":
+		body = strings.NewReader(i.data)
+	}
+	method := i.method
+	if method == "" {
+		method = http.MethodGet
+		if body != nil {
+			method = http.MethodPost
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range i.headers {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("header %q is not 'Name: value'", header)
+		}
+		req.Header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+	return req, nil
+}
+
+// portForward forwards a local port to the pilot of a running pod of foo. It
+// returns the local address, and the channel to close to stop forwarding.
+func (i *invokeOptions) portForward(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc) (string, chan struct{}, error) {
+	if i.restConfig == nil {
+		return "", nil, fmt.Errorf("port-forwarding needs a kubeconfig, set --ingress instead")
+	}
+	pods, err := i.podsOf(ctx, foo.Namespace, tools.GetAppName(foo))
+	if err != nil {
+		return "", nil, err
+	}
+	var pod *corev1.Pod
+	for j := range pods {
+		if pods[j].Status.Phase == corev1.PodRunning && pods[j].DeletionTimestamp == nil {
+			pod = &pods[j]
+			break
+		}
+	}
+	if pod == nil {
+		return "", nil, fmt.Errorf("function %q has no running pods", foo.Name)
+	}
+	var port int32
+	for _, container := range pod.Spec.Containers {
+		if container.Name == pilotContainer && len(container.Ports) > 0 {
+			port = container.Ports[0].ContainerPort
+		}
+	}
+	if port == 0 {
+		return "", nil, fmt.Errorf("pod %s has no %s port", pod.Name, pilotContainer)
+	}
+
+	transport, upgrader, err := spdy.RoundTripperFor(i.restConfig)
+	if err != nil {
+		return "", nil, err
+	}
+	req := i.kubeclient.CoreV1().RESTClient().Post().
+		Resource("pods").Namespace(pod.Namespace).Name(pod.Name).SubResource("portforward")
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, req.URL())
+	stop, ready := make(chan struct{}), make(chan struct{})
+	forwarder, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{fmt.Sprintf("0:%d", port)}, stop, ready, ioutil.Discard, i.errOut)
+	if err != nil {
+		return "", nil, err
+	}
+	errs := make(chan error, 1)
+	go func() { errs <- forwarder.ForwardPorts() }()
+	select {
+	case <-ready:
+	case err := <-errs:
+		return "", nil, fmt.Errorf("port-forward to pod %s: %v", pod.Name, err)
+	case <-ctx.Done():
+		close(stop)
+		return "", nil, ctx.Err()
+	}
+	ports, err := forwarder.GetPorts()
+	if err != nil {
+		close(stop)
+		return "", nil, err
+	}
+	return fmt.Sprintf("127.0.0.1:%d", ports[0].Local), stop, nil
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+func newListCommand(o *options) *cobra.Command {
+	var allNamespaces bool
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List functions and the status of their rollouts",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.complete(); err != nil {
+				return err
+			}
+			namespace := o.namespace
+			if allNamespaces {
+				namespace = metav1.NamespaceAll
+			}
+			return o.list(cmd, namespace)
+		},
+	}
+	cmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "List the functions of every namespace.")
+	return cmd
+}
+
+func (o *options) list(cmd *cobra.Command, namespace string) error {
+	foos, err := o.crdclient.ServerlesscontrollerV1alpha1().ServerlessFuncs(namespace).List(cmd.Context(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	if len(foos.Items) == 0 {
+		if namespace == metav1.NamespaceAll {
+			fmt.Fprintln(o.errOut, "No functions found.")
+		} else {
+			fmt.Fprintf(o.errOut, "No functions found in namespace %s.\n", namespace)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(o.out, 0, 8, 3, ' ', 0)
+	if namespace == metav1.NamespaceAll {
+		fmt.Fprint(w, "NAMESPACE\t")
+	}
+	fmt.Fprintln(w, "NAME\tIMAGE\tVERSION\tREADY\tAVAILABLE\tPHASE\tURL\tAGE")
+	for _, foo := range foos.Items {
+		if namespace == metav1.NamespaceAll {
+			fmt.Fprintf(w, "%s\t", foo.Namespace)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			foo.Name,
+			foo.Spec.Image,
+			orNone(foo.Spec.Version),
+			orNone(foo.Status.Ready),
+			foo.Status.AvailableReplicas,
+			orNone(string(foo.Status.Phase)),
+			orNone(foo.Status.URL),
+			o.age(foo.CreationTimestamp))
+	}
+	return w.Flush()
+}
+
+// orNone returns value, or <none> when it is empty, as kubectl shows them.
+func orNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
+
+// age returns the time since t, as kubectl shows it.
+func (o *options) age(t metav1.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(o.now().Sub(t.Time))
+}
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/peizhong/serverless-controller/pkg/tools"
+)
+
+type logsOptions struct {
+	*options
+	follow    bool
+	tail      int64
+	container string
+}
+
+func newLogsCommand(o *options) *cobra.Command {
+	l := &logsOptions{options: o}
+	cmd := &cobra.Command{
+		Use:   "logs NAME",
+		Short: "Print the logs of a function",
+		Long: `Print the logs of the pods of a function, those of the rpcserver container
+running the executable by default. The lines of each pod are prefixed with
+its name when the function has more than one.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeFunctions(o),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.complete(); err != nil {
+				return err
+			}
+			return l.run(cmd.Context(), args[0])
+		},
+	}
+	flags := cmd.Flags()
+	flags.BoolVarP(&l.follow, "follow", "f", false, "Stream the logs until interrupted.")
+	flags.Int64Var(&l.tail, "tail", -1, "Number of recent lines of each pod to print, all of them when negative.")
+	flags.StringVarP(&l.container, "container", "c", rpcServerContainer, "Container of the pods to print the logs of, rpcserver or pilot.")
+	return cmd
+}
+
+func (l *logsOptions) run(ctx context.Context, name string) error {
+	foo, err := l.crdclient.ServerlesscontrollerV1alpha1().ServerlessFuncs(l.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	pods, err := l.podsOf(ctx, foo.Namespace, tools.GetAppName(foo))
+	if err != nil {
+		return err
+	}
+	if len(pods) == 0 {
+		return fmt.Errorf("function %q has no pods", name)
+	}
+
+	logOptions := &corev1.PodLogOptions{Container: l.container, Follow: l.follow}
+	if l.tail >= 0 {
+		tail := l.tail
+		logOptions.TailLines = &tail
+	}
+	out := &lockedWriter{w: l.out}
+	errs := make(chan error, len(pods))
+	var wg sync.WaitGroup
+	for _, pod := range pods {
+		prefix := ""
+		if len(pods) > 1 {
+			prefix = fmt.Sprintf("[%s] ", pod.Name)
+		}
+		wg.Add(1)
+		go func(pod string) {
+			defer wg.Done()
+			stream, err := l.kubeclient.CoreV1().Pods(foo.Namespace).GetLogs(pod, logOptions).Stream(ctx)
+			if err != nil {
+				errs <- fmt.Errorf("logs of pod %s: %v", pod, err)
+				return
+			}
+			defer stream.Close()
+			if err := copyLines(out, stream, prefix); err != nil && ctx.Err() == nil {
+				errs <- fmt.Errorf("logs of pod %s: %v", pod, err)
+			}
+		}(pod.Name)
+	}
+	wg.Wait()
+	close(errs)
+	// the logs of the other pods are still worth printing, only the first
+	// error is returned
+	return <-errs
+}
+
+// podsOf returns the pods of the function whose app name is given, sorted by
+// name.
+func (o *options) podsOf(ctx context.Context, namespace, app string) ([]corev1.Pod, error) {
+	selector := labels.Set{"serverlessfunc": app}.String()
+	pods, err := o.kubeclient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+	return pods.Items, nil
+}
+
+// copyLines copies the lines of r to w, each prefixed with prefix, so that
+// the lines of several pods are not interleaved.
+func copyLines(w io.Writer, r io.Reader, prefix string) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if _, err := fmt.Fprintf(w, "%s%s\n", prefix, scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// lockedWriter serializes the writes of concurrent streams.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/tools"
+)
+
+// revisionAnnotation numbers the ReplicaSets of a Deployment, the highest
+// being the current one
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// rpcServerContainer runs the executable of a function
+const rpcServerContainer = "rpcserver"
+
+// revision is a rollout of a function, one of the ReplicaSets of its
+// Deployment.
+type revision struct {
+	number     int64
+	image      string
+	version    string
+	replicas   int32
+	ready      int32
+	replicaSet string
+	created    metav1.Time
+}
+
+// revisions returns the revisions of foo, the oldest first, or none when
+// the controller did not create its Deployment yet.
+func (o *options) revisions(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc) ([]revision, error) {
+	deployment, err := o.kubeclient.AppsV1().Deployments(foo.Namespace).Get(ctx, tools.GetDeploymentName(foo), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	selector := labels.Set{"serverlessfunc": tools.GetAppName(foo)}.String()
+	replicaSets, err := o.kubeclient.AppsV1().ReplicaSets(foo.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	var revisions []revision
+	for i := range replicaSets.Items {
+		rs := &replicaSets.Items[i]
+		if !metav1.IsControlledBy(rs, deployment) {
+			continue
+		}
+		number, err := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+		if err != nil {
+			// not numbered by the deployment controller yet
+			continue
+		}
+		r := revision{
+			number:     number,
+			replicas:   rs.Status.Replicas,
+			ready:      rs.Status.ReadyReplicas,
+			replicaSet: rs.Name,
+			created:    rs.CreationTimestamp,
+		}
+		r.image, r.version = executableOf(rs)
+		revisions = append(revisions, r)
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].number < revisions[j].number })
+	return revisions, nil
+}
+
+// executableOf returns the image and the version of the function run by the
+// pods of rs, from the command of their rpcserver container.
+func executableOf(rs *appsv1.ReplicaSet) (image, version string) {
+	for _, container := range rs.Spec.Template.Spec.Containers {
+		if container.Name != rpcServerContainer || len(container.Command) == 0 {
+			continue
+		}
+		image = path.Base(container.Command[0])
+		for i, arg := range container.Command {
+			if arg == "-v" && i+1 < len(container.Command) {
+				version = container.Command[i+1]
+			}
+		}
+	}
+	return image, version
+}
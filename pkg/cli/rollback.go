@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newRollbackCommand(o *options) *cobra.Command {
+	var toRevision int64
+	cmd := &cobra.Command{
+		Use:   "rollback NAME",
+		Short: "Roll a function back to an earlier version",
+		Long: `Roll a function back to the version of an earlier revision, by default the
+newest revision running another version than the function. The revisions are
+listed by describe.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeFunctions(o),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.complete(); err != nil {
+				return err
+			}
+			return o.rollback(cmd.Context(), args[0], toRevision)
+		},
+	}
+	cmd.Flags().Int64Var(&toRevision, "to-revision", 0, "Revision to roll back to, the newest one running another version when 0.")
+	return cmd
+}
+
+func (o *options) rollback(ctx context.Context, name string, toRevision int64) error {
+	client := o.crdclient.ServerlesscontrollerV1alpha1().ServerlessFuncs(o.namespace)
+	foo, err := client.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	revisions, err := o.revisions(ctx, foo)
+	if err != nil {
+		return err
+	}
+	var target *revision
+	switch {
+	case toRevision == 0:
+		// the newest revision may not run the version of foo yet, or run it
+		// again after a rollback, so the previous revision is found by version
+		for i := len(revisions) - 1; i >= 0; i-- {
+			if revisions[i].version != foo.Spec.Version {
+				target = &revisions[i]
+				break
+			}
+		}
+		if target == nil {
+			return fmt.Errorf("function %q has no revision running another version than %s", name, orNone(foo.Spec.Version))
+		}
+	default:
+		for i := range revisions {
+			if revisions[i].number == toRevision {
+				target = &revisions[i]
+			}
+		}
+		if target == nil {
+			return fmt.Errorf("function %q has no revision %d", name, toRevision)
+		}
+	}
+	// only the version of a function may change, a revision running another
+	// executable belongs to a function that was deleted and created again
+	if target.image != foo.Spec.Image {
+		return fmt.Errorf("revision %d runs executable %q, not %q", target.number, target.image, foo.Spec.Image)
+	}
+	if target.version == foo.Spec.Version {
+		fmt.Fprintf(o.out, "serverlessfunc/%s already runs version %s of revision %d\n", name, orNone(target.version), target.number)
+		return nil
+	}
+	update := foo.DeepCopy()
+	update.Spec.Version = target.version
+	if _, err := client.Update(ctx, update, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.out, "serverlessfunc/%s rolled back to version %s of revision %d\n", name, orNone(target.version), target.number)
+	return nil
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/peizhong/serverless-controller/pkg/validation"
+)
+
+func newScaleCommand(o *options) *cobra.Command {
+	var replicas int32
+	cmd := &cobra.Command{
+		Use:               "scale NAME --replicas=COUNT",
+		Short:             "Set the number of pods of a function",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeFunctions(o),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.complete(); err != nil {
+				return err
+			}
+			return o.scale(cmd.Context(), args[0], replicas)
+		},
+	}
+	cmd.Flags().Int32Var(&replicas, "replicas", 0, fmt.Sprintf("Number of pods, from 0 to %d.", validation.MaxReplicas))
+	cmd.MarkFlagRequired("replicas")
+	return cmd
+}
+
+func (o *options) scale(ctx context.Context, name string, replicas int32) error {
+	if replicas < 0 || replicas > validation.MaxReplicas {
+		return fmt.Errorf("--replicas must be between 0 and %d, got %d", validation.MaxReplicas, replicas)
+	}
+	client := o.crdclient.ServerlesscontrollerV1alpha1().ServerlessFuncs(o.namespace)
+	foo, err := client.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if foo.Spec.Replicas != nil && *foo.Spec.Replicas == replicas {
+		fmt.Fprintf(o.out, "serverlessfunc/%s unchanged\n", name)
+		return nil
+	}
+	update := foo.DeepCopy()
+	update.Spec.Replicas = &replicas
+	if _, err := client.Update(ctx, update, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.out, "serverlessfunc/%s scaled\n", name)
+	return nil
+}
@@ -0,0 +1,359 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/tools"
+)
+
+const (
+	// statusMissing is the status of an object of a function that does not
+	// exist
+	statusMissing = "Missing"
+	// statusNotControlled is the status of an object with the name of one of
+	// the objects of a function that the function does not control
+	statusNotControlled = "NotControlled"
+)
+
+// node is an object of the tree of a function.
+type node struct {
+	kind     string
+	name     string
+	ready    string
+	status   string
+	age      string
+	children []*node
+}
+
+func newTreeCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "tree NAME",
+		Short: "Show a function with its objects and their health",
+		Long: `Show a function with the objects it owns: its Deployment with the ReplicaSets
+of its revisions and their pods, its Service with its endpoints, the ingress
+routing its path and its NetworkPolicy.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeFunctions(o),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.complete(); err != nil {
+				return err
+			}
+			return o.tree(cmd.Context(), args[0])
+		},
+	}
+}
+
+func (o *options) tree(ctx context.Context, name string) error {
+	foo, err := o.crdclient.ServerlesscontrollerV1alpha1().ServerlessFuncs(o.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	root := &node{
+		kind:   "ServerlessFunc",
+		name:   foo.Name,
+		ready:  orNone(foo.Status.Ready),
+		status: functionStatus(foo),
+		age:    o.age(foo.CreationTimestamp),
+	}
+	deployment, err := o.deploymentTree(ctx, foo)
+	if err != nil {
+		return err
+	}
+	service, err := o.serviceNode(ctx, foo)
+	if err != nil {
+		return err
+	}
+	ingress, err := o.ingressNode(ctx, foo)
+	if err != nil {
+		return err
+	}
+	networkPolicy, err := o.networkPolicyNode(ctx, foo)
+	if err != nil {
+		return err
+	}
+	root.children = []*node{deployment, service, ingress, networkPolicy}
+
+	w := tabwriter.NewWriter(o.out, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tREADY\tSTATUS\tAGE")
+	printNode(w, root, "", "")
+	return w.Flush()
+}
+
+// printNode prints n below the nodes printed before it, with prefix before
+// its name and childPrefix before the names of its children.
+func printNode(w *tabwriter.Writer, n *node, prefix, childPrefix string) {
+	fmt.Fprintf(w, "%s%s/%s\t%s\t%s\t%s\n", prefix, n.kind, n.name, n.ready, n.status, n.age)
+	for i, child := range n.children {
+		if i == len(n.children)-1 {
+			printNode(w, child, childPrefix+"└─", childPrefix+"  ")
+		} else {
+			printNode(w, child, childPrefix+"├─", childPrefix+"│ ")
+		}
+	}
+}
+
+// missing returns the node of an object of a function that does not exist
+// or that the function does not control.
+func missing(kind, name string, exists bool) *node {
+	status := statusMissing
+	if exists {
+		status = statusNotControlled
+	}
+	return &node{kind: kind, name: name, ready: "-", status: status, age: "-"}
+}
+
+// functionStatus returns the phase of the rollout of foo, along with what
+// stops the controller from syncing it.
+func functionStatus(foo *serverlessv1alpha1.ServerlessFunc) string {
+	status := []string{orNone(string(foo.Status.Phase))}
+	for _, c := range foo.Status.Conditions {
+		if c.Status == metav1.ConditionTrue {
+			status = append(status, c.Type)
+		}
+	}
+	return strings.Join(status, ",")
+}
+
+// deploymentTree returns the node of the Deployment of foo, with the
+// ReplicaSets of its revisions and their pods, the newest first.
+func (o *options) deploymentTree(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc) (*node, error) {
+	name := tools.GetDeploymentName(foo)
+	deployment, err := o.kubeclient.AppsV1().Deployments(foo.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if err != nil || !metav1.IsControlledBy(deployment, foo) {
+		return missing("Deployment", name, err == nil), nil
+	}
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	n := &node{
+		kind:   "Deployment",
+		name:   deployment.Name,
+		ready:  fmt.Sprintf("%d/%d", deployment.Status.ReadyReplicas, desired),
+		status: deploymentStatus(deployment, desired),
+		age:    o.age(deployment.CreationTimestamp),
+	}
+
+	selector := labels.Set{"serverlessfunc": tools.GetAppName(foo)}.String()
+	replicaSets, err := o.kubeclient.AppsV1().ReplicaSets(foo.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := o.kubeclient.CoreV1().Pods(foo.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	podsOf := map[types.UID][]*node{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if owner := metav1.GetControllerOf(pod); owner != nil {
+			podsOf[owner.UID] = append(podsOf[owner.UID], o.podNode(pod))
+		}
+	}
+
+	var owned []*appsv1.ReplicaSet
+	for i := range replicaSets.Items {
+		if metav1.IsControlledBy(&replicaSets.Items[i], deployment) {
+			owned = append(owned, &replicaSets.Items[i])
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool { return revisionOf(owned[i]) > revisionOf(owned[j]) })
+	for i, rs := range owned {
+		_, version := executableOf(rs)
+		status := fmt.Sprintf("Revision %d (%s)", revisionOf(rs), orNone(version))
+		if i == 0 {
+			status += ",Current"
+		}
+		replicas := int32(0)
+		if rs.Spec.Replicas != nil {
+			replicas = *rs.Spec.Replicas
+		}
+		rsNode := &node{
+			kind:     "ReplicaSet",
+			name:     rs.Name,
+			ready:    fmt.Sprintf("%d/%d", rs.Status.ReadyReplicas, replicas),
+			status:   status,
+			age:      o.age(rs.CreationTimestamp),
+			children: podsOf[rs.UID],
+		}
+		sort.Slice(rsNode.children, func(i, j int) bool { return rsNode.children[i].name < rsNode.children[j].name })
+		n.children = append(n.children, rsNode)
+	}
+	return n, nil
+}
+
+// revisionOf returns the revision number of rs, 0 when it has none.
+func revisionOf(rs *appsv1.ReplicaSet) int64 {
+	revision, _ := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+	return revision
+}
+
+// deploymentStatus returns the phase of the rollout of deployment, whose
+// desired number of replicas is given.
+func deploymentStatus(deployment *appsv1.Deployment, desired int32) string {
+	for _, c := range deployment.Status.Conditions {
+		if c.Type == appsv1.DeploymentReplicaFailure && c.Status == corev1.ConditionTrue {
+			return string(serverlessv1alpha1.RolloutPhaseStalled)
+		}
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			return string(serverlessv1alpha1.RolloutPhaseStalled)
+		}
+	}
+	status := deployment.Status
+	if deployment.Generation > status.ObservedGeneration || status.UpdatedReplicas < desired ||
+		status.Replicas > status.UpdatedReplicas || status.AvailableReplicas < status.UpdatedReplicas {
+		return string(serverlessv1alpha1.RolloutPhaseProgressing)
+	}
+	return string(serverlessv1alpha1.RolloutPhaseAvailable)
+}
+
+// podNode returns the node of pod, whose status is the reason it is not
+// running when there is one, as kubectl get shows it.
+func (o *options) podNode(pod *corev1.Pod) *node {
+	status := string(pod.Status.Phase)
+	if pod.Status.Reason != "" {
+		status = pod.Status.Reason
+	}
+	var ready, restarts int32
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Ready {
+			ready++
+		}
+		restarts += cs.RestartCount
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason != "" {
+			status = cs.State.Waiting.Reason
+		} else if cs.State.Terminated != nil && cs.State.Terminated.Reason != "" {
+			status = cs.State.Terminated.Reason
+		}
+	}
+	if pod.DeletionTimestamp != nil {
+		status = "Terminating"
+	}
+	if restarts > 0 {
+		status = fmt.Sprintf("%s (%d restarts)", status, restarts)
+	}
+	return &node{
+		kind:   "Pod",
+		name:   pod.Name,
+		ready:  fmt.Sprintf("%d/%d", ready, len(pod.Spec.Containers)),
+		status: status,
+		age:    o.age(pod.CreationTimestamp),
+	}
+}
+
+// serviceNode returns the node of the Service of foo, whose readiness is
+// the one of its endpoints.
+func (o *options) serviceNode(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc) (*node, error) {
+	name := tools.GetServiceName(foo)
+	service, err := o.kubeclient.CoreV1().Services(foo.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if err != nil || !metav1.IsControlledBy(service, foo) {
+		return missing("Service", name, err == nil), nil
+	}
+	endpoints, err := o.kubeclient.CoreV1().Endpoints(foo.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	var ready, notReady int
+	if err == nil {
+		for _, subset := range endpoints.Subsets {
+			ready += len(subset.Addresses)
+			notReady += len(subset.NotReadyAddresses)
+		}
+	}
+	status := "Ready"
+	if ready == 0 {
+		status = "NoEndpoints"
+	}
+	return &node{
+		kind:   "Service",
+		name:   service.Name,
+		ready:  fmt.Sprintf("%d/%d", ready, ready+notReady),
+		status: status,
+		age:    o.age(service.CreationTimestamp),
+	}, nil
+}
+
+// ingressNode returns the node of the ingress routing the path of foo, its
+// own one or the shared one of its namespace.
+func (o *options) ingressNode(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc) (*node, error) {
+	ingresses := o.kubeclient.NetworkingV1().Ingresses(foo.Namespace)
+	name := tools.GetFunctionIngressName(foo)
+	ingress, err := ingresses.Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil && metav1.IsControlledBy(ingress, foo) {
+		return o.newIngressNode(ingress, tools.GetIngressPath(foo)), nil
+	}
+	// the shared ingress is named by the configuration of the controller, it
+	// is found by the routes it records instead
+	list, err := ingresses.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		if path, ok := tools.GetIngressRoutes(&list.Items[i])[foo.Name]; ok {
+			return o.newIngressNode(&list.Items[i], path), nil
+		}
+	}
+	return &node{kind: "Ingress", name: "<none>", ready: "-", status: "NotRouted", age: "-"}, nil
+}
+
+func (o *options) newIngressNode(ingress *networkingv1.Ingress, path string) *node {
+	ready := "-"
+	var addresses []string
+	for _, lb := range ingress.Status.LoadBalancer.Ingress {
+		if lb.Hostname != "" {
+			addresses = append(addresses, lb.Hostname)
+		} else if lb.IP != "" {
+			addresses = append(addresses, lb.IP)
+		}
+	}
+	if len(addresses) > 0 {
+		ready = strings.Join(addresses, ",")
+	}
+	status := "Routed " + path
+	if len(addresses) == 0 {
+		status += ",NoAddress"
+	}
+	return &node{
+		kind:   "Ingress",
+		name:   ingress.Name,
+		ready:  ready,
+		status: status,
+		age:    o.age(ingress.CreationTimestamp),
+	}
+}
+
+func (o *options) networkPolicyNode(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc) (*node, error) {
+	name := tools.GetNetworkPolicyName(foo)
+	policy, err := o.kubeclient.NetworkingV1().NetworkPolicies(foo.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if err != nil || !metav1.IsControlledBy(policy, foo) {
+		return missing("NetworkPolicy", name, err == nil), nil
+	}
+	return &node{kind: "NetworkPolicy", name: policy.Name, ready: "-", status: "Present", age: o.age(policy.CreationTimestamp)}, nil
+}
@@ -0,0 +1,282 @@
+// Package config holds the ControllerConfig, the settings shared by every
+// function managed by the controller, and loads it from a YAML file.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// APIVersion is the only version of the config file understood so far
+	APIVersion = "config.serverlesscontroller.peizhong.io/v1alpha1"
+	// Kind is the kind of the config file
+	Kind = "ControllerConfig"
+)
+
+// IngressProfile decides how Foos are exposed through ingresses
+type IngressProfile string
+
+const (
+	// IngressProfileShared routes all Foos of a namespace through one shared
+	// ingress, per function settings are enforced by the pilot sidecar
+	IngressProfileShared IngressProfile = "shared"
+	// IngressProfileNginx gives each Foo its own ingress, so that per function
+	// settings are applied with ingress-nginx annotations where possible
+	IngressProfileNginx IngressProfile = "nginx"
+)
+
+// ControllerConfig is the content of the config file. It must be treated as
+// read only once loaded, as it is shared by the workers.
+type ControllerConfig struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	Deployment    DeploymentConfig    `json:"deployment"`
+	Ingress       IngressConfig       `json:"ingress"`
+	NetworkPolicy NetworkPolicyConfig `json:"networkPolicy"`
+	// FunctionDefaults are set by the defaulting webhook on the fields a
+	// ServerlessFunc leaves out
+	FunctionDefaults FunctionDefaults `json:"functionDefaults"`
+}
+
+// DeploymentConfig configures the Deployment of every function.
+type DeploymentConfig struct {
+	RevisionHistoryLimit int32 `json:"revisionHistoryLimit"`
+	RunAsUser            int64 `json:"runAsUser"`
+	RunAsGroup           int64 `json:"runAsGroup"`
+	// WorkspaceClaim is the PVC holding the function binaries
+	WorkspaceClaim string `json:"workspaceClaim"`
+
+	Pilot     ContainerConfig `json:"pilot"`
+	RPCServer ContainerConfig `json:"rpcServer"`
+}
+
+// ContainerConfig configures one container of the function pods.
+type ContainerConfig struct {
+	Image string `json:"image"`
+	Port  int32  `json:"port"`
+	// Limits are set by the defaulting webhook on the functions that leave
+	// out their resources, and apply to the functions admitted without it
+	Limits corev1.ResourceList `json:"limits,omitempty"`
+}
+
+// IngressConfig configures how functions are exposed.
+type IngressConfig struct {
+	// Name is the name of the shared ingress of each namespace
+	Name    string         `json:"name"`
+	Profile IngressProfile `json:"profile"`
+}
+
+// NetworkPolicyConfig configures the NetworkPolicy of every function.
+type NetworkPolicyConfig struct {
+	// IngressNamespaces are the namespaces of the ingress controller and
+	// activator, which may always reach functions
+	IngressNamespaces []string `json:"ingressNamespaces"`
+	// DNSNamespace is where the cluster DNS runs
+	DNSNamespace string `json:"dnsNamespace"`
+}
+
+// FunctionDefaults are the values of the optional fields of a ServerlessFunc.
+type FunctionDefaults struct {
+	Replicas int32                       `json:"replicas"`
+	Protocol serverlessv1alpha1.Protocol `json:"protocol"`
+	// HTTP is copied into functions that do not set these http settings,
+	// the pilot defaults apply when unset
+	HTTP HTTPDefaults `json:"http,omitempty"`
+}
+
+// HTTPDefaults are the defaults of the http block of a ServerlessFunc.
+type HTTPDefaults struct {
+	RequestTimeout *metav1.Duration   `json:"requestTimeout,omitempty"`
+	IdleTimeout    *metav1.Duration   `json:"idleTimeout,omitempty"`
+	MaxRequestSize *resource.Quantity `json:"maxRequestSize,omitempty"`
+}
+
+// Default returns the config used when no file is given, and the values of
+// the fields a file leaves out.
+func Default() *ControllerConfig {
+	return &ControllerConfig{
+		APIVersion: APIVersion,
+		Kind:       Kind,
+		Deployment: DeploymentConfig{
+			RevisionHistoryLimit: 2,
+			RunAsUser:            1000,
+			RunAsGroup:           3000,
+			WorkspaceClaim:       "ide-workspaces-pvc",
+			Pilot: ContainerConfig{
+				Image: "localhost:32000/serverless-pilot:v0.0.1",
+				Port:  8080,
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("10m"),
+					corev1.ResourceMemory: resource.MustParse("20Mi"),
+				},
+			},
+			RPCServer: ContainerConfig{
+				Image: "localhost:32000/alpine:v0.0.1",
+				Port:  30000,
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("20m"),
+					corev1.ResourceMemory: resource.MustParse("40Mi"),
+				},
+			},
+		},
+		Ingress: IngressConfig{
+			Name:    "serverlessfunc-ingress",
+			Profile: IngressProfileShared,
+		},
+		NetworkPolicy: NetworkPolicyConfig{
+			IngressNamespaces: []string{"ingress-nginx"},
+			DNSNamespace:      "kube-system",
+		},
+		FunctionDefaults: FunctionDefaults{
+			Replicas: 1,
+			Protocol: serverlessv1alpha1.ProtocolHTTP,
+		},
+	}
+}
+
+// Load reads and validates the config file at path.
+func Load(path string) (*ControllerConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read controller config: %v", err)
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("load controller config %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse decodes and validates a config file. Unknown fields are rejected, so
+// that a typo does not silently leave a default in place.
+func Parse(data []byte) (*ControllerConfig, error) {
+	cfg := Default()
+	// apiVersion and kind must be given by the file
+	cfg.APIVersion, cfg.Kind = "", ""
+	// lists and resources given by the file replace the defaults instead of
+	// being merged into them
+	cfg.Deployment.Pilot.Limits = nil
+	cfg.Deployment.RPCServer.Limits = nil
+	cfg.NetworkPolicy.IngressNamespaces = nil
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, err
+	}
+	defaults := Default()
+	if cfg.Deployment.Pilot.Limits == nil {
+		cfg.Deployment.Pilot.Limits = defaults.Deployment.Pilot.Limits
+	}
+	if cfg.Deployment.RPCServer.Limits == nil {
+		cfg.Deployment.RPCServer.Limits = defaults.Deployment.RPCServer.Limits
+	}
+	if cfg.NetworkPolicy.IngressNamespaces == nil {
+		cfg.NetworkPolicy.IngressNamespaces = defaults.NetworkPolicy.IngressNamespaces
+	}
+	if err := Validate(cfg).ToAggregate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate returns every invalid field of cfg.
+func Validate(cfg *ControllerConfig) field.ErrorList {
+	var errs field.ErrorList
+	if cfg.APIVersion != APIVersion {
+		errs = append(errs, field.NotSupported(field.NewPath("apiVersion"), cfg.APIVersion, []string{APIVersion}))
+	}
+	if cfg.Kind != Kind {
+		errs = append(errs, field.NotSupported(field.NewPath("kind"), cfg.Kind, []string{Kind}))
+	}
+
+	deployment := field.NewPath("deployment")
+	if cfg.Deployment.RevisionHistoryLimit < 0 {
+		errs = append(errs, field.Invalid(deployment.Child("revisionHistoryLimit"), cfg.Deployment.RevisionHistoryLimit, "must not be negative"))
+	}
+	if cfg.Deployment.RunAsUser < 0 {
+		errs = append(errs, field.Invalid(deployment.Child("runAsUser"), cfg.Deployment.RunAsUser, "must not be negative"))
+	}
+	if cfg.Deployment.RunAsGroup < 0 {
+		errs = append(errs, field.Invalid(deployment.Child("runAsGroup"), cfg.Deployment.RunAsGroup, "must not be negative"))
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(cfg.Deployment.WorkspaceClaim) {
+		errs = append(errs, field.Invalid(deployment.Child("workspaceClaim"), cfg.Deployment.WorkspaceClaim, msg))
+	}
+	errs = append(errs, validateContainer(&cfg.Deployment.Pilot, deployment.Child("pilot"))...)
+	errs = append(errs, validateContainer(&cfg.Deployment.RPCServer, deployment.Child("rpcServer"))...)
+	if cfg.Deployment.Pilot.Port == cfg.Deployment.RPCServer.Port {
+		errs = append(errs, field.Duplicate(deployment.Child("rpcServer", "port"), cfg.Deployment.RPCServer.Port))
+	}
+
+	ingress := field.NewPath("ingress")
+	for _, msg := range validation.IsDNS1123Subdomain(cfg.Ingress.Name) {
+		errs = append(errs, field.Invalid(ingress.Child("name"), cfg.Ingress.Name, msg))
+	}
+	switch cfg.Ingress.Profile {
+	case IngressProfileShared, IngressProfileNginx:
+	default:
+		errs = append(errs, field.NotSupported(ingress.Child("profile"), cfg.Ingress.Profile,
+			[]string{string(IngressProfileShared), string(IngressProfileNginx)}))
+	}
+
+	networkPolicy := field.NewPath("networkPolicy")
+	if len(cfg.NetworkPolicy.IngressNamespaces) == 0 {
+		errs = append(errs, field.Required(networkPolicy.Child("ingressNamespaces"), "the ingress controller namespace must be given"))
+	}
+	for i, namespace := range cfg.NetworkPolicy.IngressNamespaces {
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			errs = append(errs, field.Invalid(networkPolicy.Child("ingressNamespaces").Index(i), namespace, msg))
+		}
+	}
+	for _, msg := range validation.IsDNS1123Label(cfg.NetworkPolicy.DNSNamespace) {
+		errs = append(errs, field.Invalid(networkPolicy.Child("dnsNamespace"), cfg.NetworkPolicy.DNSNamespace, msg))
+	}
+
+	defaults := field.NewPath("functionDefaults")
+	if cfg.FunctionDefaults.Replicas < 0 {
+		errs = append(errs, field.Invalid(defaults.Child("replicas"), cfg.FunctionDefaults.Replicas, "must not be negative"))
+	}
+	switch cfg.FunctionDefaults.Protocol {
+	case serverlessv1alpha1.ProtocolHTTP, serverlessv1alpha1.ProtocolH2C, serverlessv1alpha1.ProtocolGRPC, serverlessv1alpha1.ProtocolWebSocket:
+	default:
+		errs = append(errs, field.NotSupported(defaults.Child("protocol"), cfg.FunctionDefaults.Protocol, []string{
+			string(serverlessv1alpha1.ProtocolHTTP), string(serverlessv1alpha1.ProtocolH2C),
+			string(serverlessv1alpha1.ProtocolGRPC), string(serverlessv1alpha1.ProtocolWebSocket),
+		}))
+	}
+	if size := cfg.FunctionDefaults.HTTP.MaxRequestSize; size != nil && size.Sign() <= 0 {
+		errs = append(errs, field.Invalid(defaults.Child("http", "maxRequestSize"), size.String(), "must be greater than zero"))
+	}
+	return errs
+}
+
+func validateContainer(container *ContainerConfig, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if container.Image == "" {
+		errs = append(errs, field.Required(path.Child("image"), ""))
+	}
+	for _, msg := range validation.IsValidPortNum(int(container.Port)) {
+		errs = append(errs, field.Invalid(path.Child("port"), container.Port, msg))
+	}
+	for name, quantity := range container.Limits {
+		switch name {
+		case corev1.ResourceCPU, corev1.ResourceMemory:
+		default:
+			errs = append(errs, field.NotSupported(path.Child("limits"), string(name),
+				[]string{string(corev1.ResourceCPU), string(corev1.ResourceMemory)}))
+			continue
+		}
+		if quantity.Sign() <= 0 {
+			errs = append(errs, field.Invalid(path.Child("limits").Key(string(name)), quantity.String(), "must be greater than zero"))
+		}
+	}
+	return errs
+}
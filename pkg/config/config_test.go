@@ -0,0 +1,105 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestParseKeepsDefaults(t *testing.T) {
+	cfg, err := Parse([]byte(`
+apiVersion: config.serverlesscontroller.peizhong.io/v1alpha1
+kind: ControllerConfig
+deployment:
+  pilot:
+    image: pilot:v2
+    limits:
+      cpu: 50m
+ingress:
+  profile: nginx
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := Default()
+	expected.Deployment.Pilot.Image = "pilot:v2"
+	expected.Deployment.Pilot.Limits = corev1.ResourceList{
+		corev1.ResourceCPU: resource.MustParse("50m"),
+	}
+	expected.Ingress.Profile = IngressProfileNginx
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("expected %+v, got %+v", expected, cfg)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	header := "apiVersion: " + APIVersion + "\nkind: " + Kind + "\n"
+	tests := []struct {
+		name     string
+		data     string
+		expected []string
+	}{
+		{
+			name:     "missing version",
+			data:     "kind: ControllerConfig\n",
+			expected: []string{"apiVersion: Unsupported value: \"\""},
+		},
+		{
+			name:     "unknown field",
+			data:     header + "deployment:\n  replicas: 2\n",
+			expected: []string{`unknown field "replicas"`},
+		},
+		{
+			name:     "empty ingress namespaces",
+			data:     header + "networkPolicy:\n  ingressNamespaces: []\n",
+			expected: []string{"networkPolicy.ingressNamespaces: Required value"},
+		},
+		{
+			name: "invalid fields",
+			data: header + `deployment:
+  runAsUser: -1
+  pilot:
+    port: 30000
+    limits:
+      gpu: 1
+  rpcServer:
+    image: ""
+ingress:
+  name: Shared_Ingress
+  profile: istio
+networkPolicy:
+  ingressNamespaces: [ingress-nginx, "Bad"]
+functionDefaults:
+  replicas: -1
+  protocol: udp
+`,
+			expected: []string{
+				"deployment.runAsUser: Invalid value: -1",
+				"deployment.pilot.limits: Unsupported value: \"gpu\"",
+				"deployment.rpcServer.image: Required value",
+				"deployment.rpcServer.port: Duplicate value: 30000",
+				"ingress.name: Invalid value: \"Shared_Ingress\"",
+				"ingress.profile: Unsupported value: \"istio\"",
+				"networkPolicy.ingressNamespaces[1]: Invalid value: \"Bad\"",
+				"functionDefaults.replicas: Invalid value: -1",
+				"functionDefaults.protocol: Unsupported value: \"udp\"",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.data))
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			for _, msg := range test.expected {
+				if !strings.Contains(err.Error(), msg) {
+					t.Errorf("expected error to contain %q, got %v", msg, err)
+				}
+			}
+		})
+	}
+}
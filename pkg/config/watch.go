@@ -0,0 +1,37 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// Watch polls the config file at path every period until stopCh is closed,
+// and calls onChange with the new config whenever the file changes. A file
+// that fails to load is logged and ignored, so the last valid config stays in
+// use. Polling, rather than inotify, also follows the symlink swap done by the
+// kubelet when a mounted ConfigMap is updated.
+func Watch(path string, period time.Duration, onChange func(*ControllerConfig), stopCh <-chan struct{}) {
+	last, _ := ioutil.ReadFile(path)
+	go wait.Until(func() {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			klog.ErrorS(err, "Failed to read controller config", "path", path)
+			return
+		}
+		if bytes.Equal(data, last) {
+			return
+		}
+		last = data
+		cfg, err := Parse(data)
+		if err != nil {
+			klog.ErrorS(err, "Ignoring invalid controller config", "path", path)
+			return
+		}
+		klog.InfoS("Reloaded controller config", "path", path)
+		onChange(cfg)
+	}, period, stopCh)
+}
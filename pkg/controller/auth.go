@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/config"
+)
+
+const (
+	authVolumeName  = "auth"
+	authMountPath   = "/etc/serverless/auth"
+	jwksVolumeName  = "jwks"
+	jwksMountPath   = "/etc/serverless/jwks"
+	pilotMetricPath = "/metrics"
+	// callerBasicUser identifies callers by the user name of basic auth
+	// credentials checked by the ingress
+	callerBasicUser = "basic-user"
+)
+
+// authByIngress reports whether the ingress checks the credentials for foo,
+// in which case the pilot only applies the rate limits.
+func authByIngress(foo *serverlessv1alpha1.ServerlessFunc, cfg *config.ControllerConfig) bool {
+	// ingress-nginx only has a native equivalent for basic auth, api keys and
+	// tokens are always checked by the pilot
+	return foo.Spec.Auth != nil && foo.Spec.Auth.Type == serverlessv1alpha1.AuthTypeBasic &&
+		cfg.Ingress.Profile == config.IngressProfileNginx
+}
+
+// applyAuth configures the pilot container of template to enforce the auth
+// of foo. The pilot exports auth failures and rate limited calls on its
+// metrics endpoint, which is advertised for scraping.
+func applyAuth(foo *serverlessv1alpha1.ServerlessFunc, cfg *config.ControllerConfig, template *corev1.PodTemplateSpec) {
+	auth := foo.Spec.Auth
+	if auth == nil {
+		return
+	}
+	pilot := &template.Spec.Containers[0]
+	if authByIngress(foo, cfg) {
+		// ingress-nginx passes the Authorization header it checked on, so
+		// the pilot takes the caller of the rate limits and metrics from its
+		// user name without checking the password again
+		pilot.Env = append(pilot.Env, corev1.EnvVar{
+			Name:  "SERVERLESS_AUTH_CALLER",
+			Value: callerBasicUser,
+		})
+	} else {
+		pilot.Env = append(pilot.Env, corev1.EnvVar{
+			Name:  "SERVERLESS_AUTH_TYPE",
+			Value: string(auth.Type),
+		})
+		if auth.SecretName != "" {
+			template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+				Name: authVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName: auth.SecretName,
+					},
+				},
+			})
+			pilot.VolumeMounts = append(pilot.VolumeMounts, corev1.VolumeMount{
+				Name:      authVolumeName,
+				MountPath: authMountPath,
+				ReadOnly:  true,
+			})
+			pilot.Env = append(pilot.Env, corev1.EnvVar{
+				Name:  "SERVERLESS_AUTH_DIR",
+				Value: authMountPath,
+			})
+		}
+		if jwt := auth.JWT; jwt != nil {
+			key := jwt.JWKSKey
+			if key == "" {
+				key = serverlessv1alpha1.DefaultJWKSKey
+			}
+			template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+				Name: jwksVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: jwt.JWKSConfigMap,
+						},
+					},
+				},
+			})
+			pilot.VolumeMounts = append(pilot.VolumeMounts, corev1.VolumeMount{
+				Name:      jwksVolumeName,
+				MountPath: jwksMountPath,
+				ReadOnly:  true,
+			})
+			pilot.Env = append(pilot.Env,
+				corev1.EnvVar{Name: "SERVERLESS_AUTH_JWKS_FILE", Value: jwksMountPath + "/" + key},
+				corev1.EnvVar{Name: "SERVERLESS_AUTH_JWT_ISSUER", Value: jwt.Issuer},
+				corev1.EnvVar{Name: "SERVERLESS_AUTH_JWT_AUDIENCES", Value: strings.Join(jwt.Audiences, ",")},
+			)
+		}
+	}
+	// The limits are per caller, so they stay with the pilot even when the
+	// ingress checks the credentials.
+	if limit := auth.RateLimit; limit != nil {
+		pilot.Env = append(pilot.Env,
+			corev1.EnvVar{Name: "SERVERLESS_AUTH_RATE_LIMIT_RPS", Value: fmt.Sprint(limit.RequestsPerSecond)},
+			corev1.EnvVar{Name: "SERVERLESS_AUTH_RATE_LIMIT_BURST", Value: fmt.Sprint(limit.Burst)},
+		)
+	}
+
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations["prometheus.io/scrape"] = "true"
+	template.Annotations["prometheus.io/port"] = fmt.Sprint(pilot.Ports[0].ContainerPort)
+	template.Annotations["prometheus.io/path"] = pilotMetricPath
+}
+
+// authIngressAnnotations returns the ingress-nginx annotations enforcing the
+// auth of foo.
+func authIngressAnnotations(foo *serverlessv1alpha1.ServerlessFunc, cfg *config.ControllerConfig) map[string]string {
+	if !authByIngress(foo, cfg) {
+		return nil
+	}
+	return map[string]string{
+		"nginx.ingress.kubernetes.io/auth-type":   "basic",
+		"nginx.ingress.kubernetes.io/auth-secret": foo.Spec.Auth.SecretName,
+		"nginx.ingress.kubernetes.io/auth-realm":  fmt.Sprintf("serverlessfunc %s", foo.Name),
+	}
+}
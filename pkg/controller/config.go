@@ -1,49 +1,170 @@
 package controller
 
 import (
-	"path/filepath"
+	"fmt"
 	"time"
 
+	"github.com/peizhong/serverless-controller/pkg/config"
 	"github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned"
 	informers "github.com/peizhong/serverless-controller/pkg/generated/informers/externalversions"
+	crdinformers "github.com/peizhong/serverless-controller/pkg/generated/informers/externalversions/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/metrics"
+	"github.com/peizhong/serverless-controller/pkg/tools"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	kubeinformers "k8s.io/client-go/informers"
+	appsinformers "k8s.io/client-go/informers/apps/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
+	"k8s.io/client-go/transport"
 )
 
-func RestConfigFromLocal() *rest.Config {
-	homeDir := homedir.HomeDir()
-	if len(homeDir) == 0 {
-		homeDir = "~"
+// configPollPeriod is how often the config file is checked for changes.
+const configPollPeriod = 10 * time.Second
+
+// Options configures how the controller connects to the cluster and what it
+// watches.
+type Options struct {
+	// Kubeconfig is the path of a kubeconfig file, $KUBECONFIG and
+	// ~/.kube/config are used when empty
+	Kubeconfig string
+	// Master overrides the address of the API server
+	Master string
+	// Context overrides the current context of the kubeconfig
+	Context string
+	// ResyncPeriod is how often the informers replay every object
+	ResyncPeriod time.Duration
+	// Namespaces limits the controller to these namespaces, all when empty
+	Namespaces []string
+	// NamespaceSelector limits the reconciles to the namespaces matching this
+	// label selector. Unlike Namespaces it does not filter the informers,
+	// which still watch every namespace. It cannot be combined with Namespaces
+	NamespaceSelector string
+	// Backoff is the backoff of ServerlessFuncs failing to sync
+	Backoff Backoff
+	// ConfigFile is the path of a ControllerConfig file, which is reloaded
+	// when it changes. The defaults of package config are used when empty
+	ConfigFile string
+}
+
+// DefaultOptions returns the options used when none are given.
+func DefaultOptions() Options {
+	return Options{
+		ResyncPeriod: time.Minute,
+		Backoff:      DefaultBackoff(),
+	}
+}
+
+// RestConfig loads the client configuration from kubeconfig, master and
+// context, falling back to the in-cluster configuration when no kubeconfig is
+// found, so that the controller runs the same locally and as a Pod.
+func RestConfig(kubeconfig, master, context string) (*rest.Config, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		loadingRules.ExplicitPath = kubeconfig
 	}
-	kubeConfigPath := filepath.Join(homeDir, ".kube", "config")
-	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+	overrides.ClusterInfo.Server = master
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("load client configuration: %v", err)
 	}
-	return restConfig
+	return restConfig, nil
+}
+
+// RestConfigFromLocal loads the client configuration from $KUBECONFIG or
+// ~/.kube/config, or the in-cluster configuration.
+func RestConfigFromLocal() (*rest.Config, error) {
+	return RestConfig("", "", "")
 }
 
 // FromLocalFile kubectl proxy --address=0.0.0.0 --port=8700 --disable-filter=true
-func FromLocalFile(stopCh <-chan struct{}) *Controller {
-	restConfig := RestConfigFromLocal()
+func FromLocalFile(stopCh <-chan struct{}) (*Controller, error) {
+	return FromOptions(DefaultOptions(), stopCh)
+}
+
+// FromOptions creates a controller for the cluster described by opts and
+// starts its informers.
+func FromOptions(opts Options, stopCh <-chan struct{}) (*Controller, error) {
+	if len(opts.Namespaces) > 0 && opts.NamespaceSelector != "" {
+		return nil, fmt.Errorf("namespaces and a namespace selector cannot be combined")
+	}
+	if _, err := labels.Parse(opts.NamespaceSelector); err != nil {
+		return nil, fmt.Errorf("parse namespace selector: %v", err)
+	}
+	cfg, err := loadConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	kubeclient, crdClientSet, err := newClients(opts)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := opts.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	// only the deployments created by the controller are cached, the other
+	// owned objects are read from the API server
+	managed := func(options *metav1.ListOptions) {
+		options.LabelSelector = tools.ManagedSelector()
+	}
+	var factories []interface{ Start(<-chan struct{}) }
+	var deploymentInformers []appsinformers.DeploymentInformer
+	var crdInformers []crdinformers.ServerlessFuncInformer
+	for _, namespace := range namespaces {
+		kubeInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeclient, opts.ResyncPeriod,
+			kubeinformers.WithNamespace(namespace), kubeinformers.WithTweakListOptions(managed))
+		crdInformerFactory := informers.NewSharedInformerFactoryWithOptions(crdClientSet, opts.ResyncPeriod,
+			informers.WithNamespace(namespace))
+		deploymentInformers = append(deploymentInformers, kubeInformerFactory.Apps().V1().Deployments())
+		crdInformers = append(crdInformers, crdInformerFactory.Serverlesscontroller().V1alpha1().ServerlessFuncs())
+		factories = append(factories, kubeInformerFactory, crdInformerFactory)
+	}
+	ctrl := NewMultiNamespaceController(kubeclient, crdClientSet, deploymentInformers, crdInformers)
+	if opts.NamespaceSelector != "" {
+		namespaceInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeclient, opts.ResyncPeriod,
+			kubeinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.LabelSelector = opts.NamespaceSelector
+			}))
+		ctrl.SelectNamespaces(namespaceInformerFactory.Core().V1().Namespaces())
+		factories = append(factories, namespaceInformerFactory)
+	}
+	ctrl.SetBackoff(opts.Backoff)
+	ctrl.SetConfig(cfg)
+	if opts.ConfigFile != "" {
+		config.Watch(opts.ConfigFile, configPollPeriod, ctrl.SetConfig, stopCh)
+	}
+
+	for _, factory := range factories {
+		factory.Start(stopCh)
+	}
+	return ctrl, nil
+}
+
+// loadConfig loads the ControllerConfig file of opts, or the defaults.
+func loadConfig(opts Options) (*config.ControllerConfig, error) {
+	if opts.ConfigFile == "" {
+		return config.Default(), nil
+	}
+	return config.Load(opts.ConfigFile)
+}
+
+// newClients returns the clients of the cluster of opts.
+func newClients(opts Options) (*kubernetes.Clientset, *versioned.Clientset, error) {
+	restConfig, err := RestConfig(opts.Kubeconfig, opts.Master, opts.Context)
+	if err != nil {
+		return nil, nil, err
+	}
+	restConfig.WrapTransport = transport.Wrappers(restConfig.WrapTransport, metrics.InstrumentTransport)
 	kubeclient, err := kubernetes.NewForConfig(restConfig)
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
 	crdClientSet, err := versioned.NewForConfig(restConfig)
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
-	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeclient, time.Minute)
-	crdInformerFactory := informers.NewSharedInformerFactory(crdClientSet, time.Minute)
-	ctrl := NewController(kubeclient, crdClientSet,
-		kubeInformerFactory.Apps().V1().Deployments(),
-		crdInformerFactory.Serverlesscontroller().V1alpha1().ServerlessFuncs())
-
-	kubeInformerFactory.Start(stopCh)
-	crdInformerFactory.Start(stopCh)
-	return ctrl
+	return kubeclient, crdClientSet, nil
 }
@@ -2,15 +2,23 @@ package controller
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -19,36 +27,24 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
-	"k8s.io/client-go/util/workqueue"
-	"k8s.io/klog"
+	"k8s.io/klog/v2"
 
 	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/config"
 	clientset "github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned"
 	samplescheme "github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned/scheme"
 	informers "github.com/peizhong/serverless-controller/pkg/generated/informers/externalversions/serverlesscontroller/v1alpha1"
 	listers "github.com/peizhong/serverless-controller/pkg/generated/listers/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/logging"
+	"github.com/peizhong/serverless-controller/pkg/metrics"
 	"github.com/peizhong/serverless-controller/pkg/tools"
 )
 
 const controllerAgentName = "serverless-controller"
 
-const (
-	// SuccessSynced is used as part of the Event 'reason' when a Foo is synced
-	SuccessSynced = "Synced"
-	// ErrResourceExists is used as part of the Event 'reason' when a Foo fails
-	// to sync due to a Deployment of the same name already existing.
-	ErrResourceExists = "ErrResourceExists"
-
-	// MessageResourceExists is the message used for Events when a resource
-	// fails to sync due to a Deployment already existing
-	MessageResourceExists = "Resource %q already exists and is not managed by Foo"
-	// MessageResourceSynced is the message used for an Event fired when a Foo
-	// is synced successfully
-	MessageResourceSynced = "Foo synced successfully"
-)
-
 // Controller is the controller implementation for CRD resources
 type Controller struct {
 	// kubeclientset is a standard kubernetes clientset
@@ -56,74 +52,142 @@ type Controller struct {
 	// crdclientset is a clientset for our own API group
 	crdClientSet clientset.Interface
 
-	deploymentsLister appslisters.DeploymentLister
-	deploymentsSynced cache.InformerSynced
+	deploymentsLister  appslisters.DeploymentLister
+	deploymentsSynced  cache.InformerSynced
+	deploymentsVersion func() string
 
-	crdLister listers.ServerlessFuncLister
-	crdSynced cache.InformerSynced
+	crdLister  listers.ServerlessFuncLister
+	crdSynced  cache.InformerSynced
+	crdVersion func() string
+
+	// namespaceLister holds the namespaces selected by a label selector, all
+	// namespaces are managed when nil
+	namespaceLister   corelisters.NamespaceLister
+	namespacesSynced  cache.InformerSynced
+	namespacesVersion func() string
 
 	// workqueue is a rate limited work queue. This is used to queue work to be
 	// processed instead of performing it as soon as a change happens. This
 	// means we can ensure we only process a fixed amount of resources at a
 	// time, and makes it easy to ensure we are never processing the same item
 	// simultaneously in two different workers.
-	workqueue workqueue.RateLimitingInterface
+	workqueue *trackingQueue
+	// backoff is the backoff of the rate limiter of workqueue
+	backoff Backoff
 	// recorder is an event recorder for recording Event resources to the
 	// Kubernetes API.
 	recorder record.EventRecorder
+	// clock is replaced by tests
+	clock clock.Clock
+
+	// configLock guards config, which is replaced when the config file
+	// changes
+	configLock sync.RWMutex
+	config     *config.ControllerConfig
+
+	// leading is set atomically, and reported by the health endpoints along
+	// with the last result of every ServerlessFunc
+	leading     int32
+	resultsLock sync.Mutex
+	results     map[string]reconcileResult
 }
 
+// NewController returns a controller for the ServerlessFuncs and Deployments
+// of a single pair of informers.
 func NewController(
 	kubeclientset kubernetes.Interface,
 	crdclientset clientset.Interface,
 	deploymentInformer appsinformers.DeploymentInformer,
 	crdInformer informers.ServerlessFuncInformer) *Controller {
+	return NewMultiNamespaceController(kubeclientset, crdclientset,
+		[]appsinformers.DeploymentInformer{deploymentInformer},
+		[]informers.ServerlessFuncInformer{crdInformer})
+}
+
+// NewMultiNamespaceController returns a controller for the ServerlessFuncs and
+// Deployments of several informers, typically one per watched namespace.
+func NewMultiNamespaceController(
+	kubeclientset kubernetes.Interface,
+	crdclientset clientset.Interface,
+	deploymentInformers []appsinformers.DeploymentInformer,
+	crdInformers []informers.ServerlessFuncInformer) *Controller {
 
 	// Create event broadcaster
 	// Add sample-controller types to the default Kubernetes Scheme so Events can be
 	// logged for sample-controller types.
 	utilruntime.Must(samplescheme.AddToScheme(scheme.Scheme))
-	klog.V(4).Info("Creating event broadcaster")
-	eventBroadcaster := record.NewBroadcaster()
-	eventBroadcaster.StartLogging(klog.Infof)
+	klog.V(4).InfoS("Creating event broadcaster")
+	eventBroadcaster := record.NewBroadcasterWithCorrelatorOptions(eventCorrelatorOptions)
+	eventBroadcaster.StartStructuredLogging(2)
 	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeclientset.CoreV1().Events("")})
 	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: controllerAgentName})
 
+	var deploymentsLister multiDeploymentLister
+	var deploymentsShared []cache.SharedIndexInformer
+	for _, informer := range deploymentInformers {
+		deploymentsLister = append(deploymentsLister, informer.Lister())
+		deploymentsShared = append(deploymentsShared, informer.Informer())
+	}
+	var crdLister multiServerlessFuncLister
+	var crdShared []cache.SharedIndexInformer
+	for _, informer := range crdInformers {
+		crdLister = append(crdLister, informer.Lister())
+		crdShared = append(crdShared, informer.Informer())
+	}
+
 	controller := &Controller{
-		kubeclientset:     kubeclientset,
-		crdClientSet:      crdclientset,
-		deploymentsLister: deploymentInformer.Lister(),
-		deploymentsSynced: deploymentInformer.Informer().HasSynced,
-		crdLister:         crdInformer.Lister(),
-		crdSynced:         crdInformer.Informer().HasSynced,
-		workqueue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Foos"),
-		recorder:          recorder,
-	}
-
-	klog.Info("Setting up event handlers")
-	// Set up an event handler for when Foo resources change
-	crdInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		kubeclientset:      kubeclientset,
+		crdClientSet:       crdclientset,
+		deploymentsLister:  deploymentsLister,
+		deploymentsSynced:  allSynced(deploymentsShared...),
+		deploymentsVersion: lastSyncResourceVersion(deploymentsShared...),
+		crdLister:          crdLister,
+		crdSynced:          allSynced(crdShared...),
+		crdVersion:         lastSyncResourceVersion(crdShared...),
+		workqueue:          newTrackingQueue(DefaultBackoff().rateLimiter(), "Foos"),
+		backoff:            DefaultBackoff(),
+		recorder:           recorder,
+		clock:              clock.RealClock{},
+		config:             config.Default(),
+		results:            map[string]reconcileResult{},
+	}
+
+	klog.V(4).InfoS("Setting up event handlers")
+	// Set up an event handler for when ServerlessFunc resources change
+	crdHandler := cache.ResourceEventHandlerFuncs{
 		AddFunc: controller.enqueueCrd,
 		UpdateFunc: func(old, new interface{}) {
-			klog.Info("update crd")
+			klog.V(4).InfoS("ServerlessFunc updated", "foo", klog.KObj(new.(metav1.Object)))
+			// a requested reconcile does not wait for the backoff of the
+			// failed syncs of the ServerlessFunc
+			if reconcileRequestChanged(old, new) {
+				if key, err := cache.MetaNamespaceKeyFunc(new); err == nil {
+					controller.workqueue.Forget(key)
+				}
+			}
 			controller.enqueueCrd(new)
 		},
 		DeleteFunc: func(obj interface{}) {
-			klog.Info("delete crd")
+			// The deleted ServerlessFunc is still enqueued, so that its path
+			// is removed from the shared ingress.
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err != nil {
+				utilruntime.HandleError(err)
+				return
+			}
+			klog.V(4).InfoS("ServerlessFunc deleted", "key", key)
+			controller.workqueue.Add(key)
 		},
-	})
+	}
+	for _, informer := range crdShared {
+		informer.AddEventHandler(crdHandler)
+	}
 	// Set up an event handler for when Deployment resources change. This
 	// handler will lookup the owner of the given Deployment, and if it is
-	// owned by a Foo resource will enqueue that Foo resource for
-	// processing. This way, we don't need to implement custom logic for
-	// handling Deployment resources. More info on this pattern:
-	// https://github.com/kubernetes/community/blob/8cafef897a22026d42f5e5bb3f104febe7e29830/contributors/devel/controllers.md
-	deploymentInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			klog.Info("i don't care deployments added")
-			return
-			controller.handleObject(obj)
-		},
+	// owned by a ServerlessFunc resource will enqueue that ServerlessFunc
+	// resource for processing, so that its status follows the rollout.
+	deploymentHandler := cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.handleObject,
 		UpdateFunc: func(old, new interface{}) {
 			newDepl := new.(*appsv1.Deployment)
 			oldDepl := old.(*appsv1.Deployment)
@@ -132,20 +196,24 @@ func NewController(
 				// Two different versions of the same Deployment will always have different RVs.
 				return
 			}
-			klog.Info("i don't care deployments updated")
-			return
 			controller.handleObject(new)
 		},
-		DeleteFunc: func(obj interface{}) {
-			klog.Info("i don't care deployments deleted")
-			return
-			controller.handleObject(obj)
-		},
-	})
+		DeleteFunc: controller.handleObject,
+	}
+	for _, informer := range deploymentsShared {
+		informer.AddEventHandler(deploymentHandler)
+	}
 
 	return controller
 }
 
+// SetBackoff replaces the backoff of failed ServerlessFuncs. It must be
+// called before Run.
+func (c *Controller) SetBackoff(backoff Backoff) {
+	c.backoff = backoff
+	c.workqueue.rateLimiter = backoff.rateLimiter()
+}
+
 // Run will set up the event handlers for types we are interested in, as well
 // as syncing informer caches and starting workers. It will block until stopCh
 // is closed, at which point it will shutdown the workqueue and wait for
@@ -155,23 +223,35 @@ func (c *Controller) Run(threadiness int, stopCh <-chan struct{}) error {
 	defer c.workqueue.ShutDown()
 
 	// Start the informer factories to begin populating the informer caches
-	klog.Info("Starting Foo controller")
+	klog.InfoS("Starting ServerlessFunc controller")
 
 	// Wait for the caches to be synced before starting workers
-	klog.Info("Waiting for informer caches to sync")
-	if ok := cache.WaitForCacheSync(stopCh, c.deploymentsSynced, c.crdSynced); !ok {
+	klog.InfoS("Waiting for informer caches to sync")
+	synced := []cache.InformerSynced{c.deploymentsSynced, c.crdSynced}
+	if c.namespacesSynced != nil {
+		synced = append(synced, c.namespacesSynced)
+	}
+	if ok := cache.WaitForCacheSync(stopCh, synced...); !ok {
 		return fmt.Errorf("failed to wait for caches to sync")
 	}
 
-	klog.Info("Starting workers")
-	// Launch two workers to process Foo resources
+	klog.InfoS("Starting workers", "count", threadiness)
+	// Launch threadiness workers to process ServerlessFunc resources
+	var workers sync.WaitGroup
 	for i := 0; i < threadiness; i++ {
-		go wait.Until(c.runWorker, time.Second, stopCh)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			wait.Until(c.runWorker, time.Second, stopCh)
+		}()
 	}
 
-	klog.Info("Started workers")
 	<-stopCh
-	klog.Info("Shutting down workers")
+	klog.InfoS("Shutting down workers")
+	// the workers finish the item they are syncing and return once the
+	// queue is shut down
+	c.workqueue.ShutDown()
+	workers.Wait()
 
 	return nil
 }
@@ -218,17 +298,11 @@ func (c *Controller) processNextWorkItem() bool {
 			return nil
 		}
 		// Run the syncHandler, passing it the namespace/name string of the
-		// Foo resource to be synced.
-		if err := c.syncHandler(key); err != nil {
-			// Put the item back on the workqueue to handle any transient errors.
-			c.workqueue.AddRateLimited(key)
-			err = fmt.Errorf("error syncing '%s': %s, requeuing", key, err.Error())
-			klog.Info(err.Error())
-		}
-		// Finally, if no error occurs we Forget this item so it does not
-		// get queued again until another change happens.
-		c.workqueue.Forget(obj)
-		klog.Infof("Successfully synced '%s'", key)
+		// ServerlessFunc resource to be synced.
+		start := time.Now()
+		result, err := c.syncHandler(key)
+		c.recordResult(key, start, err)
+		c.handleResult(key, result, err)
 		return nil
 	}(obj)
 
@@ -240,147 +314,529 @@ func (c *Controller) processNextWorkItem() bool {
 	return true
 }
 
+// handleResult requeues key according to the outcome of its sync. Forget
+// resets the backoff of key, so it is only called once key is not retried
+// with backoff anymore.
+func (c *Controller) handleResult(key string, result Result, err error) {
+	class, reason := classify(err)
+	switch class {
+	case errorNone:
+		c.workqueue.Forget(key)
+		if result.RequeueAfter > 0 {
+			c.workqueue.AddAfter(key, result.RequeueAfter)
+		}
+		klog.V(4).InfoS("Successfully synced", "key", key, "requeueAfter", result.RequeueAfter)
+	case errorConflict:
+		c.workqueue.Forget(key)
+		c.workqueue.AddAfter(key, c.backoff.Base)
+		klog.V(2).InfoS("Conflict syncing foo, requeuing", "key", key, "err", err)
+	case errorPermanent:
+		c.workqueue.Forget(key)
+		klog.ErrorS(err, "Error syncing foo, not retrying", "key", key, "reason", reason)
+	default:
+		// Put the item back on the workqueue to handle any transient errors.
+		c.workqueue.AddRateLimited(key)
+		klog.ErrorS(err, "Error syncing foo, requeuing", "key", key, "requeues", c.workqueue.NumRequeues(key))
+	}
+}
+
 // syncHandler compares the actual state with the desired, and attempts to
-// converge the two. It then updates the Status block of the Foo resource
-// with the current status of the resource.
-func (c *Controller) syncHandler(key string) error {
+// converge the two. It then updates the Status block of the ServerlessFunc
+// resource with the current status of the resource.
+func (c *Controller) syncHandler(key string) (Result, error) {
 	// Convert the namespace/name string into a distinct namespace and name
 	namespace, name, err := cache.SplitMetaNamespaceKey(key)
 	if err != nil {
 		utilruntime.HandleError(fmt.Errorf("invalid resource key: %s", key))
-		return nil
+		return Result{}, nil
+	}
+
+	logger := logging.Logger().WithValues("reconcileID", newReconcileID(), "namespace", namespace, "name", name)
+	ctx := logging.NewContext(context.Background(), logger)
+	if !c.namespaceSelected(namespace) {
+		logger.V(4).Info("Skipping foo, its namespace is not selected")
+		return Result{}, nil
 	}
 
-	// Get the Foo resource with this namespace/name
+	// Get the ServerlessFunc resource with this namespace/name
 	foo, err := c.crdLister.ServerlessFuncs(namespace).Get(name)
 	if err != nil {
-		// The Foo resource may no longer exist, in which case we stop
-		// processing.
+		// The ServerlessFunc resource may no longer exist, in which case we
+		// only remove its path from the shared ingress and stop processing.
 		if errors.IsNotFound(err) {
-			utilruntime.HandleError(fmt.Errorf("foo '%s' in work queue no longer exists", key))
-			return nil
+			logger.V(2).Info("ServerlessFunc no longer exists")
+			metrics.DeleteFunction(namespace, name)
+			c.forgetResult(key)
+			return Result{}, c.cleanupIngress(ctx, namespace, c.Config())
 		}
 
-		return err
+		return Result{}, err
+	}
+	logger = logger.WithValues("generation", foo.Generation)
+	ctx = logging.NewContext(ctx, logger)
+	logger.V(2).Info("Reconciling foo")
+	start := time.Now()
+
+	result, err := c.reconcile(ctx, foo)
+	if class, reason := classify(err); class == errorPermanent {
+		c.recorder.Event(foo, corev1.EventTypeWarning, reason, err.Error())
+		// the error is still returned so that it is logged and recorded,
+		// unless reporting it failed, in which case the sync is retried
+		if statusErr := c.reportPermanentError(ctx, foo, reason, err); statusErr != nil {
+			return Result{}, statusErr
+		}
+		return Result{}, err
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	logger.V(2).Info("Reconciled foo", "duration", time.Since(start), "requeueAfter", result.RequeueAfter)
+	return result, nil
+}
+
+// reconcile syncs the objects and the status of foo.
+func (c *Controller) reconcile(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc) (Result, error) {
+	// the config is read once, so that a reload does not mix old and new
+	// settings within a sync
+	cfg := c.Config()
+	ctx, changes := withChanges(ctx)
+
+	names, err := c.objectNames(ctx, foo)
+	if err != nil {
+		return Result{}, err
+	}
+	// the objects are synced under the names recorded along with the status
+	foo = foo.DeepCopy()
+	foo.Status.ObjectNames = names
+
+	if foo.DeletionTimestamp != nil {
+		return Result{}, c.finalize(ctx, foo)
+	}
+	if foo, err = c.syncFinalizer(ctx, foo); err != nil {
+		return Result{}, err
+	}
+	if paused(foo) {
+		return c.reconcilePaused(ctx, foo)
+	}
+
+	deployment, err := c.syncDeployment(ctx, foo, cfg)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if err = c.syncService(ctx, foo, cfg); err != nil {
+		return Result{}, err
+	}
+
+	if err = c.syncNetworkPolicy(ctx, foo, cfg); err != nil {
+		return Result{}, err
+	}
+
+	if err = c.syncIngress(ctx, foo, cfg); err != nil {
+		return Result{}, err
+	}
+
+	// Finally, we update the status block of the ServerlessFunc resource to
+	// reflect the current state of the world
+	progress := rolloutStatus(deployment)
+	if err = c.updateCrdStatus(ctx, foo, deployment, progress); err != nil {
+		return Result{}, err
 	}
 
+	if *changes > 0 {
+		c.recorder.Event(foo, corev1.EventTypeNormal, SuccessSynced, MessageResourceSynced)
+	}
+	// the ServerlessFunc is polled until its rollout is stable, so that its
+	// status does not depend on the events of the Deployment alone
+	return Result{RequeueAfter: progress.requeueAfter()}, nil
+}
+
+// newReconcileID returns a random ID telling apart the log lines of
+// different reconciles of a ServerlessFunc.
+func newReconcileID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// syncDeployment creates the Deployment of foo, and updates it when it
+// differs from the desired one.
+func (c *Controller) syncDeployment(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc, cfg *config.ControllerConfig) (deployment *appsv1.Deployment, err error) {
+	defer metrics.ObserveStep(metrics.StepDeployment, time.Now(), &err)
+	logger := logging.FromContext(ctx)
+
 	deploymentName := tools.GetDeploymentName(foo)
-	// Get the deployment with the name specified in Foo.spec
-	deployment, err := c.deploymentsLister.Deployments(foo.Namespace).Get(deploymentName)
+	// Get the deployment with the name specified in ServerlessFunc.spec
+	deployment, err = c.deploymentsLister.Deployments(foo.Namespace).Get(deploymentName)
 	// If the resource doesn't exist, we'll create it
 	if errors.IsNotFound(err) {
-		deployment, err = c.kubeclientset.AppsV1().Deployments(foo.Namespace).Create(context.TODO(), newDeployment(foo), metav1.CreateOptions{})
+		logger.Info("Creating deployment", "deployment", deploymentName)
+		deployment, err = c.kubeclientset.AppsV1().Deployments(foo.Namespace).Create(ctx, newDeployment(foo, cfg), metav1.CreateOptions{})
+		if err == nil {
+			c.recordChange(ctx, foo, ReasonDeploymentCreated, "Created deployment %q", deploymentName)
+		}
+		// The informer only holds labelled deployments, so one created
+		// before the label was introduced is read from the API server and
+		// labelled by the update below.
+		if errors.IsAlreadyExists(err) {
+			logger.V(2).Info("Deployment exists without the managed-by label", "deployment", deploymentName)
+			deployment, err = c.kubeclientset.AppsV1().Deployments(foo.Namespace).Get(ctx, deploymentName, metav1.GetOptions{})
+		}
 	}
 
 	// If an error occurs during Get/Create, we'll requeue the item so we can
 	// attempt processing again later. This could have been caused by a
 	// temporary network failure, or any other transient reason.
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// If the Deployment is not controlled by this Foo resource, we should log
+	// If the Deployment is not controlled by this ServerlessFunc resource, it
+	// is adopted when the ServerlessFunc asks for it, otherwise we should log
 	// a warning to the event recorder and return error msg.
 	if !metav1.IsControlledBy(deployment, foo) {
-		msg := fmt.Sprintf(MessageResourceExists, deployment.Name)
-		c.recorder.Event(foo, corev1.EventTypeWarning, ErrResourceExists, msg)
-		return fmt.Errorf(msg)
+		if deployment, err = c.adoptDeployment(ctx, foo, deployment); err != nil {
+			return nil, err
+		}
 	}
 
-	// If this number of the replicas on the Foo resource is specified, and the
-	// number does not equal the current desired replicas on the Deployment, we
-	// should update the Deployment resource.
-	klog.Infof("DiffServerlessFuncAndDeployment")
+	// If this number of the replicas on the ServerlessFunc resource is
+	// specified, and the number does not equal the current desired replicas
+	// on the Deployment, we should update the Deployment resource.
 	diff := tools.DiffServerlessFuncAndDeployment(foo, deployment)
+	diff = append(diff, tools.DiffDeploymentTemplate(newDeployment(foo, cfg), deployment)...)
+	// changes made by hand that leave the template hash alone are only
+	// reverted on request
+	if len(diff) == 0 && reconcileRequested(foo) {
+		diff = append(diff, tools.DiffResult{
+			Field: "Metadata.Annotations[" + serverlessv1alpha1.ReconcileRequestAnnotation + "]",
+			Left:  foo.Annotations[serverlessv1alpha1.ReconcileRequestAnnotation],
+			Right: foo.Status.LastHandledReconcileRequest,
+		})
+	}
 	if len(diff) > 0 {
-		for _, item := range diff {
-			klog.Infof("Foo: [%s].[%s] expect: %v, deployment: %v", foo.Name, item.Field, item.Left, item.Right)
+		logDiff(logger, "Deployment", diff)
+		logger.Info("Updating deployment", "deployment", deploymentName)
+		scaledToZero := foo.Spec.Replicas != nil && *foo.Spec.Replicas == 0 &&
+			(deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != 0)
+		deployment, err = c.kubeclientset.AppsV1().Deployments(foo.Namespace).Update(ctx, newDeployment(foo, cfg), metav1.UpdateOptions{})
+		if err == nil {
+			c.recordChange(ctx, foo, ReasonDeploymentUpdated, "Updated deployment %q: %s", deploymentName, summarizeDiff(diff))
+			if scaledToZero {
+				c.recordChange(ctx, foo, ReasonScaledToZero, "Scaled deployment %q to zero replicas", deploymentName)
+			}
 		}
-		deployment, err = c.kubeclientset.AppsV1().Deployments(foo.Namespace).Update(context.TODO(), newDeployment(foo), metav1.UpdateOptions{})
 	}
 
 	// If an error occurs during Update, we'll requeue the item so we can
 	// attempt processing again later. This could have been caused by a
 	// temporary network failure, or any other transient reason.
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return deployment, nil
+}
 
-	{
-		// service
-		service, err := c.kubeclientset.CoreV1().Services(foo.Namespace).Get(context.TODO(), tools.GetServiceName(foo), metav1.GetOptions{})
-		if errors.IsNotFound(err) {
-			klog.Info("create service ", service.Name)
-			service, err = c.kubeclientset.CoreV1().Services(foo.Namespace).Create(context.TODO(), newService(foo), metav1.CreateOptions{})
+// syncService creates the Service of foo, and reverts changes to its ports
+// and selector.
+func (c *Controller) syncService(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc, cfg *config.ControllerConfig) (err error) {
+	defer metrics.ObserveStep(metrics.StepService, time.Now(), &err)
+	logger := logging.FromContext(ctx)
+
+	desired := newService(foo, cfg)
+	service, err := c.kubeclientset.CoreV1().Services(foo.Namespace).Get(ctx, desired.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		logger.Info("Creating service", "service", desired.Name)
+		if _, err = c.kubeclientset.CoreV1().Services(foo.Namespace).Create(ctx, desired, metav1.CreateOptions{}); err != nil {
+			return err
 		}
-		if err != nil {
+		c.recordChange(ctx, foo, ReasonServiceCreated, "Created service %q", desired.Name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(service, foo) {
+		if service, err = c.adoptService(ctx, foo, service); err != nil {
 			return err
 		}
 	}
+	diff := tools.DiffService(desired, service)
+	if len(diff) == 0 {
+		return nil
+	}
+	logDiff(logger, "Service", diff)
+	logger.Info("Updating service", "service", desired.Name)
+	// the cluster IP and other allocated fields are kept
+	update := service.DeepCopy()
+	update.Spec.Ports = desired.Spec.Ports
+	update.Spec.Selector = desired.Spec.Selector
+	if _, err = c.kubeclientset.CoreV1().Services(foo.Namespace).Update(ctx, update, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	c.recordChange(ctx, foo, ReasonServiceUpdated, "Updated service %q: %s", desired.Name, summarizeDiff(diff))
+	return nil
+}
+
+// syncIngress exposes foo according to the ingress profile.
+func (c *Controller) syncIngress(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc, cfg *config.ControllerConfig) (err error) {
+	defer metrics.ObserveStep(metrics.StepIngress, time.Now(), &err)
+
+	if cfg.Ingress.Profile == config.IngressProfileNginx {
+		// the path is no longer in the shared ingress
+		clearWarning(foo, serverlessv1alpha1.ConditionIngressPathConflict)
+		clearWarning(foo, serverlessv1alpha1.ConditionProtocolUnsupported)
+		return c.syncFunctionIngress(ctx, foo, cfg)
+	}
+	if len(protocolIngressAnnotations(foo)) > 0 {
+		// The shared ingress has one set of annotations for every path, so
+		// the function is still routed, but as plain HTTP/1.1.
+		c.recordWarning(foo, serverlessv1alpha1.ConditionProtocolUnsupported, ErrProtocolUnsupported,
+			fmt.Sprintf(MessageProtocolUnsupported, protocolOf(foo), config.IngressProfileNginx))
+	} else {
+		clearWarning(foo, serverlessv1alpha1.ConditionProtocolUnsupported)
+	}
+	// ServerlessFuncs that used to have their own ingress move back to the
+	// shared one.
+	if err := c.deleteFunctionIngress(ctx, foo); err != nil {
+		return err
+	}
+	return c.syncSharedIngress(ctx, foo, cfg)
+}
 
-	{
-		// ingress
-		ingress, err := c.kubeclientset.NetworkingV1().Ingresses(foo.Namespace).Get(context.TODO(), tools.GetIngressName(), metav1.GetOptions{})
+// syncSharedIngress routes the path of foo to its service in the shared
+// ingress of its namespace, and removes the paths of ServerlessFuncs that no
+// longer exist.
+func (c *Controller) syncSharedIngress(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc, cfg *config.ControllerConfig) error {
+	logger := logging.FromContext(ctx)
+	ingress, err := c.kubeclientset.NetworkingV1().Ingresses(foo.Namespace).Get(ctx, cfg.Ingress.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		// An ingress without paths is rejected, so it is created along with
+		// the path of the first ServerlessFunc.
+		ingress, err = updateIngress(newIngress(foo.Namespace, cfg), foo)
 		if err != nil {
-			klog.Infof("Get Ingresses err: %v", err.Error())
-		}
-		if errors.IsNotFound(err) {
-			// 创建已有ingress
-			ingress, err = c.kubeclientset.NetworkingV1().Ingresses(foo.Namespace).Create(context.TODO(), newIngress(foo.Namespace), metav1.CreateOptions{})
+			return err
 		}
-		if err != nil {
-			klog.Infof("Create Ingresses err: %v", err.Error())
+		logger.Info("Creating shared ingress", "ingress", ingress.Name)
+		if _, err = c.kubeclientset.NetworkingV1().Ingresses(foo.Namespace).Create(ctx, ingress, metav1.CreateOptions{}); err != nil {
 			return err
 		}
-		// 比较ingress是否不一致
-		klog.Infof("DiffServerlessFuncAndIngress")
-		diff = tools.DiffServerlessFuncAndIngress(foo, ingress)
-		if len(diff) > 0 {
-			for _, item := range diff {
-				klog.Infof("Foo: [%s].[%s] expect: %v, ingress: %v", foo.Name, item.Field, item.Left, item.Right)
-			}
-			// 本次foo，更新到ingress
-			_, err = c.kubeclientset.NetworkingV1().Ingresses(foo.Namespace).Update(context.TODO(), updateIngress(ingress, foo), metav1.UpdateOptions{})
-			if err != nil {
-				return err
-			}
+		c.recordChange(ctx, foo, ReasonRouteAdded, "Routed path %q of ingress %q to service %q", tools.GetIngressPath(foo), ingress.Name, tools.GetServiceName(foo))
+		clearWarning(foo, serverlessv1alpha1.ConditionIngressPathConflict)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	desired := ingress
+	var changed bool
+	diff := tools.DiffServerlessFuncAndIngress(foo, ingress)
+	if len(diff) > 0 {
+		logDiff(logger, "Ingress", diff)
+		if updated, err := updateIngress(ingress, foo); err != nil {
+			// Retrying does not resolve a conflict, so it is only reported
+			// and the other paths are still reconciled.
+			c.recordWarning(foo, serverlessv1alpha1.ConditionIngressPathConflict, ErrIngressPathConflict, err.Error())
+		} else {
+			desired, changed = updated, true
 		}
 	}
+	if len(diff) == 0 || changed {
+		clearWarning(foo, serverlessv1alpha1.ConditionIngressPathConflict)
+	}
+	desired, removed := pruneIngress(desired, c.crdExists(foo.Namespace))
+	if !changed && len(removed) == 0 {
+		return nil
+	}
+	if err := c.saveIngress(ctx, desired, removed); err != nil {
+		return err
+	}
+	if changed {
+		c.recordChange(ctx, foo, ReasonRouteAdded, "Routed path %q of ingress %q to service %q", tools.GetIngressPath(foo), ingress.Name, tools.GetServiceName(foo))
+	}
+	return nil
+}
 
-	// Finally, we update the status block of the Foo resource to reflect the
-	// current state of the world
-	err = c.updateCrdStatus(foo, deployment)
+// cleanupIngress removes the paths of ServerlessFuncs that no longer exist
+// from the shared ingress of namespace.
+func (c *Controller) cleanupIngress(ctx context.Context, namespace string, cfg *config.ControllerConfig) error {
+	ingress, err := c.kubeclientset.NetworkingV1().Ingresses(namespace).Get(ctx, cfg.Ingress.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
 	if err != nil {
-		err = fmt.Errorf("updateCrdStatus err: %v", err.Error())
 		return err
 	}
+	exists := c.crdExists(namespace)
+	if cfg.Ingress.Profile != config.IngressProfileShared {
+		// every path moves to the ingress of its ServerlessFunc
+		exists = func(string) bool { return false }
+	}
+	desired, removed := pruneIngress(ingress, exists)
+	if len(removed) == 0 {
+		return nil
+	}
+	return c.saveIngress(ctx, desired, removed)
+}
 
-	c.recorder.Event(foo, corev1.EventTypeNormal, SuccessSynced, MessageResourceSynced)
-
-	klog.Infof("syncHandler: %s complete", key)
+// saveIngress updates the shared ingress, or deletes it once the last path
+// is gone, since an ingress without paths is invalid.
+func (c *Controller) saveIngress(ctx context.Context, ingress *networkingv1.Ingress, removed []string) error {
+	logger := logging.FromContext(ctx)
+	for _, path := range removed {
+		logger.Info("Removing orphaned path from shared ingress", "ingress", klog.KObj(ingress), "path", path)
+	}
+	var err error
+	if !ingressHasPaths(ingress) {
+		logger.Info("Deleting shared ingress without paths", "ingress", klog.KObj(ingress))
+		err = c.kubeclientset.NetworkingV1().Ingresses(ingress.Namespace).Delete(ctx, ingress.Name, metav1.DeleteOptions{})
+	} else {
+		_, err = c.kubeclientset.NetworkingV1().Ingresses(ingress.Namespace).Update(ctx, ingress, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+	// the ServerlessFuncs of the paths are gone, so the events are recorded
+	// on the ingress
+	for _, path := range removed {
+		c.recorder.Eventf(ingress, corev1.EventTypeNormal, ReasonRouteRemoved, "Removed path %q of a deleted ServerlessFunc", path)
+	}
 	return nil
 }
 
-func (c *Controller) updateCrdStatus(foo *serverlessv1alpha1.ServerlessFunc, deployment *appsv1.Deployment) error {
+// logDiff logs the fields of an object of kind that differ from the desired
+// ones.
+func logDiff(logger logr.Logger, kind string, diff []tools.DiffResult) {
+	for _, item := range diff {
+		logger.V(2).Info("Field differs from desired", "kind", kind, "field", item.Field, "desired", item.Left, "current", item.Right)
+	}
+}
+
+// crdExists returns a func reporting whether a ServerlessFunc exists in
+// namespace.
+func (c *Controller) crdExists(namespace string) func(name string) bool {
+	return func(name string) bool {
+		_, err := c.crdLister.ServerlessFuncs(namespace).Get(name)
+		return !errors.IsNotFound(err)
+	}
+}
+
+// Config returns the config currently in use.
+func (c *Controller) Config() *config.ControllerConfig {
+	c.configLock.RLock()
+	defer c.configLock.RUnlock()
+	return c.config
+}
+
+// SetConfig replaces the config, and enqueues every ServerlessFunc when it
+// changed so that the new defaults are applied without waiting for the next
+// resync. Renaming the shared ingress leaves the ingress with the old name
+// behind.
+func (c *Controller) SetConfig(cfg *config.ControllerConfig) {
+	c.configLock.Lock()
+	changed := !equality.Semantic.DeepEqual(c.config, cfg)
+	c.config = cfg
+	c.configLock.Unlock()
+	if !changed {
+		return
+	}
+	foos, err := c.crdLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	klog.InfoS("Config changed, resyncing every foo", "count", len(foos))
+	for _, foo := range foos {
+		c.enqueueCrd(foo)
+	}
+}
+
+func (c *Controller) updateCrdStatus(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc, deployment *appsv1.Deployment, progress rollout) (err error) {
+	defer metrics.ObserveStep(metrics.StepStatus, time.Now(), &err)
+
+	desired := int32(1)
+	if foo.Spec.Replicas != nil {
+		desired = *foo.Spec.Replicas
+	}
+	metrics.SetReplicas(foo.Namespace, foo.Name, desired, deployment.Status.AvailableReplicas)
+
 	// NEVER modify objects from the store. It's a read-only, local cache.
 	// You can use DeepCopy() to make a deep copy of original object and modify this copy
 	// Or create a copy manually for better performance
 	fooCopy := foo.DeepCopy()
 	fooCopy.Status.AvailableReplicas = deployment.Status.AvailableReplicas
-	// If the CustomResourceSubresources feature gate is not enabled,
-	// we must use Update instead of UpdateStatus to update the Status block of the Foo resource.
+	fooCopy.Status.Ready = fmt.Sprintf("%d/%d", deployment.Status.ReadyReplicas, desired)
+	fooCopy.Status.URL = tools.GetFunctionURL(foo)
+	fooCopy.Status.Phase = progress.Phase
+	fooCopy.Status.Reason = progress.Reason
+	fooCopy.Status.Message = progress.Message
+	if paused(foo) {
+		meta.SetStatusCondition(&fooCopy.Status.Conditions, metav1.Condition{
+			Type:               serverlessv1alpha1.ConditionPaused,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: foo.Generation,
+			LastTransitionTime: metav1.NewTime(c.clock.Now()),
+			Reason:             reasonPausedByAnnotation,
+			Message:            messagePaused,
+		})
+	} else {
+		fooCopy.Status.LastHandledReconcileRequest = foo.Annotations[serverlessv1alpha1.ReconcileRequestAnnotation]
+		// the error of a previous sync is fixed. RemoveStatusCondition panics
+		// when the condition is missing from an empty list
+		for _, conditionType := range []string{serverlessv1alpha1.ConditionReconcileError, serverlessv1alpha1.ConditionPaused} {
+			if meta.FindStatusCondition(fooCopy.Status.Conditions, conditionType) != nil {
+				meta.RemoveStatusCondition(&fooCopy.Status.Conditions, conditionType)
+			}
+		}
+	}
 	// UpdateStatus will not allow changes to the Spec of the resource,
 	// which is ideal for ensuring nothing other than resource status has been updated.
-	_, err := c.crdClientSet.ServerlesscontrollerV1alpha1().ServerlessFuncs(foo.Namespace).Update(context.TODO(), fooCopy, metav1.UpdateOptions{})
+	// The admission webhooks do not match the status subresource either.
+	_, err = c.crdClientSet.ServerlesscontrollerV1alpha1().ServerlessFuncs(foo.Namespace).UpdateStatus(ctx, fooCopy, metav1.UpdateOptions{})
 	if err != nil {
-		err = fmt.Errorf("update foo(%s/%s) err :%v", foo.Namespace, fooCopy.Name, err.Error())
+		return fmt.Errorf("update foo(%s/%s) err: %w", foo.Namespace, fooCopy.Name, err)
 	}
+	c.recordRollout(foo, progress)
+	return nil
+}
+
+// recordRollout records the end of the rollout of foo, once when its phase
+// changes.
+func (c *Controller) recordRollout(foo *serverlessv1alpha1.ServerlessFunc, progress rollout) {
+	if foo.Status.Phase == progress.Phase {
+		return
+	}
+	switch progress.Phase {
+	case serverlessv1alpha1.RolloutPhaseAvailable:
+		c.recorder.Eventf(foo, corev1.EventTypeNormal, ReasonRolloutCompleted, "Rollout completed: %s", progress.Message)
+	case serverlessv1alpha1.RolloutPhaseStalled:
+		c.recorder.Eventf(foo, corev1.EventTypeWarning, ReasonRolloutFailed, "Rollout failed: %s: %s", progress.Reason, progress.Message)
+	}
+}
+
+// reportPermanentError sets the ReconcileError condition of foo.
+func (c *Controller) reportPermanentError(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc, reason string, err error) error {
+	fooCopy := foo.DeepCopy()
+	condition := metav1.Condition{
+		Type:               serverlessv1alpha1.ConditionReconcileError,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: foo.Generation,
+		LastTransitionTime: metav1.NewTime(c.clock.Now()),
+		Reason:             reason,
+		Message:            err.Error(),
+	}
+	meta.SetStatusCondition(&fooCopy.Status.Conditions, condition)
+	if equality.Semantic.DeepEqual(foo.Status, fooCopy.Status) {
+		return nil
+	}
+	_, err = c.crdClientSet.ServerlesscontrollerV1alpha1().ServerlessFuncs(foo.Namespace).UpdateStatus(ctx, fooCopy, metav1.UpdateOptions{})
 	return err
 }
 
-// enqueueCrd takes a Foo resource and converts it into a namespace/name
-// string which is then put onto the work queue. This method should *not* be
-// passed resources of any type other than Foo.
+// enqueueCrd takes a ServerlessFunc resource and converts it into a
+// namespace/name string which is then put onto the work queue. This method
+// should *not* be passed resources of any type other than ServerlessFunc.
 func (c *Controller) enqueueCrd(obj interface{}) {
 	var key string
 	var err error
@@ -392,10 +848,11 @@ func (c *Controller) enqueueCrd(obj interface{}) {
 }
 
 // handleObject will take any resource implementing metav1.Object and attempt
-// to find the Foo resource that 'owns' it. It does this by looking at the
-// objects metadata.ownerReferences field for an appropriate OwnerReference.
-// It then enqueues that Foo resource to be processed. If the object does not
-// have an appropriate OwnerReference, it will simply be skipped.
+// to find the ServerlessFunc resource that 'owns' it. It does this by looking
+// at the objects metadata.ownerReferences field for an appropriate
+// OwnerReference. It then enqueues that ServerlessFunc resource to be
+// processed. If the object does not have an appropriate OwnerReference, it
+// will simply be skipped.
 func (c *Controller) handleObject(obj interface{}) {
 	var object metav1.Object
 	var ok bool
@@ -410,19 +867,19 @@ func (c *Controller) handleObject(obj interface{}) {
 			utilruntime.HandleError(fmt.Errorf("error decoding object tombstone, invalid type"))
 			return
 		}
-		klog.V(4).Infof("Recovered deleted object '%s' from tombstone", object.GetName())
+		klog.V(4).InfoS("Recovered deleted object from tombstone", "object", klog.KObj(object))
 	}
-	klog.V(4).Infof("Processing object: %s", object.GetName())
+	klog.V(4).InfoS("Processing object", "object", klog.KObj(object))
 	if ownerRef := metav1.GetControllerOf(object); ownerRef != nil {
-		// If this object is not owned by a Foo, we should not do anything more
-		// with it.
+		// If this object is not owned by a ServerlessFunc, we should not do
+		// anything more with it.
 		if ownerRef.Kind != "ServerlessFunc" {
 			return
 		}
 
 		foo, err := c.crdLister.ServerlessFuncs(object.GetNamespace()).Get(ownerRef.Name)
 		if err != nil {
-			klog.V(4).Infof("ignoring orphaned object '%s' of foo '%s'", object.GetSelfLink(), ownerRef.Name)
+			klog.V(4).InfoS("Ignoring orphaned object", "object", klog.KObj(object), "foo", ownerRef.Name)
 			return
 		}
 
@@ -431,38 +888,35 @@ func (c *Controller) handleObject(obj interface{}) {
 	}
 }
 
-var (
-	DefaultRevisionHistoryLimit int32 = 2
-	DefaultRunAsUser            int64 = 1000
-	DefaultRunAsGroup           int64 = 3000
-)
-
-// newDeployment creates a new Deployment for a Foo resource. It also sets
-// the appropriate OwnerReferences on the resource so handleObject can discover
-// the Foo resource that 'owns' it.
-func newDeployment(foo *serverlessv1alpha1.ServerlessFunc) *appsv1.Deployment {
+// newDeployment creates a new Deployment for a ServerlessFunc resource. It
+// also sets the appropriate OwnerReferences on the resource so handleObject
+// can discover the ServerlessFunc resource that 'owns' it.
+func newDeployment(foo *serverlessv1alpha1.ServerlessFunc, cfg *config.ControllerConfig) *appsv1.Deployment {
 	labels := map[string]string{
 		"serverlessfunc": tools.GetAppName(foo),
 	}
-	resourceLimit := func(cpu, memory int) corev1.ResourceList {
-		resp := corev1.ResourceList{}
-		if cpu > 0 {
-			if limit, err := resource.ParseQuantity(fmt.Sprintf("%dm", cpu)); err == nil {
-				resp[corev1.ResourceCPU] = limit
-			}
+	// the values are copied, so that the deployment does not share memory
+	// with the config
+	revisionHistoryLimit := cfg.Deployment.RevisionHistoryLimit
+	runAsUser := cfg.Deployment.RunAsUser
+	runAsGroup := cfg.Deployment.RunAsGroup
+	pilot, rpcServer := cfg.Deployment.Pilot, cfg.Deployment.RPCServer
+	// functions admitted without the defaulting webhook have no resources,
+	// and get the limits of the config
+	if resources := foo.Spec.Resources; resources != nil {
+		if resources.Pilot != nil {
+			pilot.Limits = resources.Pilot
 		}
-		if memory > 0 {
-			if limit, err := resource.ParseQuantity(fmt.Sprintf("%dMi", memory)); err == nil {
-				resp[corev1.ResourceMemory] = limit
-			}
+		if resources.RPCServer != nil {
+			rpcServer.Limits = resources.RPCServer
 		}
-		return resp
 	}
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      tools.GetDeploymentName(foo),
 			Namespace: foo.Namespace,
-			// 根对象
+			// the ServerlessFunc owns the deployment, which is garbage
+			// collected with it
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(foo, serverlessv1alpha1.SchemeGroupVersion.WithKind("ServerlessFunc")),
 			},
@@ -470,11 +924,12 @@ func newDeployment(foo *serverlessv1alpha1.ServerlessFunc) *appsv1.Deployment {
 				"serverlessfunc":         tools.GetAppName(foo),
 				"serverlessfunc-images":  foo.Spec.Image,
 				"serverlessfunc-version": foo.Spec.Version,
+				tools.ManagedByLabel:     tools.ManagedBy,
 			},
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas:             foo.Spec.Replicas,
-			RevisionHistoryLimit: &DefaultRevisionHistoryLimit,
+			RevisionHistoryLimit: &revisionHistoryLimit,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: labels,
 			},
@@ -484,15 +939,15 @@ func newDeployment(foo *serverlessv1alpha1.ServerlessFunc) *appsv1.Deployment {
 				},
 				Spec: corev1.PodSpec{
 					SecurityContext: &corev1.PodSecurityContext{
-						RunAsUser:  &DefaultRunAsUser,
-						RunAsGroup: &DefaultRunAsGroup,
+						RunAsUser:  &runAsUser,
+						RunAsGroup: &runAsGroup,
 					},
 					Volumes: []corev1.Volume{
 						{
 							Name: "ide-workspaces",
 							VolumeSource: corev1.VolumeSource{
 								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: "ide-workspaces-pvc",
+									ClaimName: cfg.Deployment.WorkspaceClaim,
 								},
 							},
 						},
@@ -500,7 +955,7 @@ func newDeployment(foo *serverlessv1alpha1.ServerlessFunc) *appsv1.Deployment {
 					Containers: []corev1.Container{
 						{
 							Name:  "pilot",
-							Image: "localhost:32000/serverless-pilot:v0.0.1",
+							Image: pilot.Image,
 							Env: []corev1.EnvVar{
 								{
 									Name:  "SERVERLESS_FUNC",
@@ -510,25 +965,25 @@ func newDeployment(foo *serverlessv1alpha1.ServerlessFunc) *appsv1.Deployment {
 							Ports: []corev1.ContainerPort{
 								{
 									Name:          "http",
-									ContainerPort: 8080,
+									ContainerPort: pilot.Port,
 								},
 							},
 							LivenessProbe: &corev1.Probe{
 								Handler: corev1.Handler{
 									HTTPGet: &corev1.HTTPGetAction{
 										Path: "/ping",
-										Port: intstr.Parse("8080"),
+										Port: intstr.FromInt(int(pilot.Port)),
 									},
 								},
 								InitialDelaySeconds: 5,
 								PeriodSeconds:       30,
 							},
 							Resources: corev1.ResourceRequirements{
-								Limits: resourceLimit(10, 20),
+								Limits: pilot.Limits.DeepCopy(),
 							},
 						}, {
 							Name:  "rpcserver",
-							Image: "localhost:32000/alpine:v0.0.1",
+							Image: rpcServer.Image,
 							Env: []corev1.EnvVar{
 								{
 									Name:  "SERVERLESS_FUNC",
@@ -543,7 +998,7 @@ func newDeployment(foo *serverlessv1alpha1.ServerlessFunc) *appsv1.Deployment {
 							Ports: []corev1.ContainerPort{
 								{
 									Name:          "rpc",
-									ContainerPort: 30000,
+									ContainerPort: rpcServer.Port,
 								},
 							},
 							VolumeMounts: []corev1.VolumeMount{
@@ -554,7 +1009,7 @@ func newDeployment(foo *serverlessv1alpha1.ServerlessFunc) *appsv1.Deployment {
 								},
 							},
 							Resources: corev1.ResourceRequirements{
-								Limits: resourceLimit(20, 40),
+								Limits: rpcServer.Limits.DeepCopy(),
 							},
 						},
 					},
@@ -562,13 +1017,39 @@ func newDeployment(foo *serverlessv1alpha1.ServerlessFunc) *appsv1.Deployment {
 			},
 		},
 	}
+	applyAuth(foo, cfg, &deployment.Spec.Template)
+	applyHTTP(foo, cfg, &deployment.Spec.Template)
+	applyProtocol(foo, &deployment.Spec.Template)
+	deployment.Annotations = map[string]string{
+		tools.TemplateHashAnnotation: tools.ComputeHash(&deployment.Spec.Template),
+	}
 	return deployment
 }
 
-func newService(foo *serverlessv1alpha1.ServerlessFunc) *corev1.Service {
+func newService(foo *serverlessv1alpha1.ServerlessFunc, cfg *config.ControllerConfig) *corev1.Service {
 	labels := map[string]string{
 		"serverlessfunc": tools.GetAppName(foo),
 	}
+	appProtocol := appProtocols[protocolOf(foo)]
+	ports := []corev1.ServicePort{
+		{
+			Name:        "pilot",
+			Protocol:    corev1.ProtocolTCP,
+			Port:        80,
+			TargetPort:  intstr.FromInt(int(cfg.Deployment.Pilot.Port)),
+			AppProtocol: &appProtocol,
+		},
+	}
+	if protocolOf(foo) == serverlessv1alpha1.ProtocolGRPC {
+		// gRPC clients inside the cluster may call the rpcserver directly
+		ports = append(ports, corev1.ServicePort{
+			Name:        "rpc",
+			Protocol:    corev1.ProtocolTCP,
+			Port:        cfg.Deployment.RPCServer.Port,
+			TargetPort:  intstr.FromInt(int(cfg.Deployment.RPCServer.Port)),
+			AppProtocol: &appProtocol,
+		})
+	}
 	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      tools.GetServiceName(foo),
@@ -576,28 +1057,24 @@ func newService(foo *serverlessv1alpha1.ServerlessFunc) *corev1.Service {
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(foo, serverlessv1alpha1.SchemeGroupVersion.WithKind("ServerlessFunc")),
 			},
-			Labels: labels,
+			Labels: map[string]string{
+				"serverlessfunc":     tools.GetAppName(foo),
+				tools.ManagedByLabel: tools.ManagedBy,
+			},
 		},
 		Spec: corev1.ServiceSpec{
 			Selector: labels,
-			Ports: []corev1.ServicePort{
-				{
-					Name:       "pilot",
-					Protocol:   corev1.ProtocolTCP,
-					Port:       80,
-					TargetPort: intstr.Parse("8080"),
-				},
-			},
+			Ports:    ports,
 		},
 	}
 }
 
 // newIngress should be one ingress
-func newIngress(namespace string) *networkingv1.Ingress {
+func newIngress(namespace string, cfg *config.ControllerConfig) *networkingv1.Ingress {
 	labels := map[string]string{}
 	return &networkingv1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:            tools.GetIngressName(),
+			Name:            cfg.Ingress.Name,
 			Namespace:       namespace,
 			OwnerReferences: []metav1.OwnerReference{
 				// *metav1.NewControllerRef(foo, serverlessv1alpha1.SchemeGroupVersion.WithKind("ServerlessFunc")),
@@ -606,11 +1083,12 @@ func newIngress(namespace string) *networkingv1.Ingress {
 		},
 		Spec: networkingv1.IngressSpec{
 			Rules: []networkingv1.IngressRule{
-				// 只用1个
+				// a single rule holds the paths of every ServerlessFunc
 				{
 					IngressRuleValue: networkingv1.IngressRuleValue{
 						HTTP: &networkingv1.HTTPIngressRuleValue{
-							// todo: 必须有值 spec.rules[0].http.paths: Required value
+							// spec.rules[0].http.paths must not be empty, so the
+							// ingress is only created through updateIngress
 							Paths: make([]networkingv1.HTTPIngressPath, 0),
 						},
 					},
@@ -620,53 +1098,116 @@ func newIngress(namespace string) *networkingv1.Ingress {
 	}
 }
 
-func updateIngress(current *networkingv1.Ingress, foo *serverlessv1alpha1.ServerlessFunc) *networkingv1.Ingress {
+// updateIngress returns a copy of current routing the path of foo to its
+// service. Only paths recorded as owned by foo are changed: a path that is
+// managed for another ServerlessFunc, or was added by hand with another
+// backend, is a conflict and is left untouched.
+func updateIngress(current *networkingv1.Ingress, foo *serverlessv1alpha1.ServerlessFunc) (*networkingv1.Ingress, error) {
 	result := current.DeepCopy()
-	if len(result.Spec.Rules) != 1 {
-		// 修复数据
-		return result
+	if len(result.Spec.Rules) == 0 {
+		result.Spec.Rules = append(result.Spec.Rules, networkingv1.IngressRule{})
 	}
-	currentRule := current.Spec.Rules[0]
-	if currentRule.IngressRuleValue.HTTP == nil {
-		return result
+	if len(result.Spec.Rules) != 1 {
+		return nil, fmt.Errorf("ingress %q has %d rules, expected 1", result.Name, len(result.Spec.Rules))
 	}
-	newRule := currentRule.DeepCopy()
-	if len(newRule.HTTP.Paths) > 0 {
-		newRule.HTTP.Paths = newRule.HTTP.Paths[:0]
+	rule := &result.Spec.Rules[0]
+	if rule.HTTP == nil {
+		rule.HTTP = &networkingv1.HTTPIngressRuleValue{}
 	}
-	var updateExitRule bool
+
+	routes := tools.GetIngressRoutes(result)
 	ingressPath := tools.GetIngressPath(foo)
-	for _, currentPath := range currentRule.IngressRuleValue.HTTP.Paths {
-		if currentPath.Path == ingressPath {
-			updateExitRule = true
-			updatePath := currentPath.DeepCopy()
-			updatePath.Backend.Service = &networkingv1.IngressServiceBackend{
-				Name: tools.GetServiceName(foo),
-				Port: networkingv1.ServiceBackendPort{
-					Number: 80,
-				},
+	backend := networkingv1.IngressBackend{
+		Service: &networkingv1.IngressServiceBackend{
+			Name: tools.GetServiceName(foo),
+			Port: networkingv1.ServiceBackendPort{
+				Number: 80,
+			},
+		},
+	}
+	if owner := tools.GetRouteOwner(routes, ingressPath); owner != "" && owner != foo.Name {
+		return nil, fmt.Errorf(MessageIngressPathConflict, ingressPath, result.Name, fmt.Sprintf("ServerlessFunc %q", owner))
+	} else if owner == "" {
+		// A path nobody claims is only taken over if it already routes to
+		// the service of foo, which is what paths created before ownership
+		// was recorded look like.
+		for _, path := range rule.HTTP.Paths {
+			if path.Path == ingressPath && !equality.Semantic.DeepEqual(path.Backend, backend) {
+				return nil, fmt.Errorf(MessageIngressPathConflict, ingressPath, result.Name, "a path not managed by the controller")
 			}
-			newRule.HTTP.Paths = append(newRule.HTTP.Paths, *updatePath)
-		} else {
-			newRule.HTTP.Paths = append(newRule.HTTP.Paths, currentPath)
 		}
 	}
-	pathTypePrefix := networkingv1.PathTypePrefix
-	if !updateExitRule {
-		newRule.HTTP.Paths = append(newRule.HTTP.Paths, networkingv1.HTTPIngressPath{
+
+	var found bool
+	paths := make([]networkingv1.HTTPIngressPath, 0, len(rule.HTTP.Paths)+1)
+	for _, path := range rule.HTTP.Paths {
+		switch path.Path {
+		case ingressPath:
+			found = true
+			path.Backend = backend
+		case routes[foo.Name]:
+			// stale path of foo, e.g. from an older path scheme
+			continue
+		}
+		paths = append(paths, path)
+	}
+	if !found {
+		pathTypePrefix := networkingv1.PathTypePrefix
+		paths = append(paths, networkingv1.HTTPIngressPath{
 			Path:     ingressPath,
 			PathType: &pathTypePrefix,
-			Backend: networkingv1.IngressBackend{
-				Service: &networkingv1.IngressServiceBackend{
-					Name: tools.GetServiceName(foo),
-					Port: networkingv1.ServiceBackendPort{
-						Number: 80,
-					},
-				},
-			},
+			Backend:  backend,
 		})
 	}
-	result.Spec.Rules = result.Spec.Rules[:0]
-	result.Spec.Rules = append(result.Spec.Rules, *newRule)
-	return result
+	rule.HTTP.Paths = paths
+	routes[foo.Name] = ingressPath
+	tools.SetIngressRoutes(result, routes)
+	return result, nil
+}
+
+// pruneIngress returns a copy of current without the owned paths of
+// ServerlessFuncs for which exists returns false, along with the removed
+// paths.
+func pruneIngress(current *networkingv1.Ingress, exists func(name string) bool) (*networkingv1.Ingress, []string) {
+	result := current.DeepCopy()
+	routes := tools.GetIngressRoutes(result)
+	orphaned := map[string]bool{}
+	var removed []string
+	for name, path := range routes {
+		if exists(name) {
+			continue
+		}
+		delete(routes, name)
+		orphaned[path] = true
+		removed = append(removed, path)
+	}
+	if len(removed) == 0 {
+		return result, nil
+	}
+	sort.Strings(removed)
+	for i := range result.Spec.Rules {
+		rule := &result.Spec.Rules[i]
+		if rule.HTTP == nil {
+			continue
+		}
+		paths := make([]networkingv1.HTTPIngressPath, 0, len(rule.HTTP.Paths))
+		for _, path := range rule.HTTP.Paths {
+			if !orphaned[path.Path] {
+				paths = append(paths, path)
+			}
+		}
+		rule.HTTP.Paths = paths
+	}
+	tools.SetIngressRoutes(result, routes)
+	return result, removed
+}
+
+// ingressHasPaths reports whether any rule of the ingress still has a path.
+func ingressHasPaths(ingress *networkingv1.Ingress) bool {
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP != nil && len(rule.HTTP.Paths) > 0 {
+			return true
+		}
+	}
+	return ingress.Spec.DefaultBackend != nil
 }
@@ -21,7 +21,10 @@ func TestTypes(t *testing.T) {
 
 func TestRun(t *testing.T) {
 	stopCh := make(chan struct{})
-	ctrl := FromLocalFile(stopCh)
+	ctrl, err := FromLocalFile(stopCh)
+	if err != nil {
+		t.Skipf("no cluster available: %v", err)
+	}
 	go func() {
 		<-time.After(time.Second * 10)
 		close(stopCh)
@@ -32,7 +35,10 @@ func TestRun(t *testing.T) {
 }
 
 func TestListCrd(t *testing.T) {
-	restConfig := RestConfigFromLocal()
+	restConfig, err := RestConfigFromLocal()
+	if err != nil {
+		t.Skipf("no cluster available: %v", err)
+	}
 	crdClientSet, err := versioned.NewForConfig(restConfig)
 	if err != nil {
 		panic(err)
@@ -50,7 +56,10 @@ func TestListCrd(t *testing.T) {
 }
 
 func TestAddCrd(t *testing.T) {
-	restConfig := RestConfigFromLocal()
+	restConfig, err := RestConfigFromLocal()
+	if err != nil {
+		t.Skipf("no cluster available: %v", err)
+	}
 	crdClientSet, err := versioned.NewForConfig(restConfig)
 	if err != nil {
 		panic(err)
@@ -68,7 +77,10 @@ func TestAddCrd(t *testing.T) {
 }
 
 func TestGetCrd(t *testing.T) {
-	restConfig := RestConfigFromLocal()
+	restConfig, err := RestConfigFromLocal()
+	if err != nil {
+		t.Skipf("no cluster available: %v", err)
+	}
 	crdClientSet, err := versioned.NewForConfig(restConfig)
 	if err != nil {
 		panic(err)
@@ -81,7 +93,10 @@ func TestGetCrd(t *testing.T) {
 }
 
 func TestDeleteCrd(t *testing.T) {
-	restConfig := RestConfigFromLocal()
+	restConfig, err := RestConfigFromLocal()
+	if err != nil {
+		t.Skipf("no cluster available: %v", err)
+	}
 	crdClientSet, err := versioned.NewForConfig(restConfig)
 	if err != nil {
 		panic(err)
@@ -17,20 +17,39 @@ limitations under the License.
 package controller
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/config"
 	crdfake "github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned/fake"
 	crdinformers "github.com/peizhong/serverless-controller/pkg/generated/informers/externalversions"
+	serverlessinformers "github.com/peizhong/serverless-controller/pkg/generated/informers/externalversions/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/metrics"
 	"github.com/peizhong/serverless-controller/pkg/tools"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/apimachinery/pkg/util/diff"
 	kubeinformers "k8s.io/client-go/informers"
+	appsinformers "k8s.io/client-go/informers/apps/v1"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 	core "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
@@ -40,6 +59,8 @@ import (
 var (
 	alwaysReady        = func() bool { return true }
 	noResyncPeriodFunc = func() time.Duration { return 0 }
+	// now is the time of the fake clock of the controller
+	now = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
 )
 
 type fixture struct {
@@ -50,12 +71,18 @@ type fixture struct {
 	// Objects to put in the store.
 	crdLister        []*serverlessv1alpha1.ServerlessFunc
 	deploymentLister []*apps.Deployment
+	// Namespaces selected by a label selector, all when nil.
+	namespaceLister []*corev1.Namespace
 	// Actions expected to happen on the client.
 	kubeactions []core.Action
 	actions     []core.Action
 	// Objects from here preloaded into NewSimpleFake.
 	kubeobjects []runtime.Object
 	objects     []runtime.Object
+	// Events recorded by the controller.
+	recorder *record.FakeRecorder
+	// Config of the controller.
+	config *config.ControllerConfig
 }
 
 func newFixture(t *testing.T) *fixture {
@@ -63,6 +90,7 @@ func newFixture(t *testing.T) *fixture {
 	f.t = t
 	f.objects = []runtime.Object{}
 	f.kubeobjects = []runtime.Object{}
+	f.config = config.Default()
 	return f
 }
 
@@ -97,6 +125,7 @@ func newFoo(name string, replicas *int32) *serverlessv1alpha1.ServerlessFunc {
 func (f *fixture) newController() (*Controller, crdinformers.SharedInformerFactory, kubeinformers.SharedInformerFactory) {
 	f.crdclient = crdfake.NewSimpleClientset(f.objects...)
 	f.kubeclient = k8sfake.NewSimpleClientset(f.kubeobjects...)
+	f.crdclient.PrependReactor("update", "serverlessfuncs", keepStatus(f.crdclient.Tracker()))
 
 	i := crdinformers.NewSharedInformerFactory(f.crdclient, noResyncPeriodFunc())
 	k8sI := kubeinformers.NewSharedInformerFactory(f.kubeclient, noResyncPeriodFunc())
@@ -104,9 +133,12 @@ func (f *fixture) newController() (*Controller, crdinformers.SharedInformerFacto
 	c := NewController(f.kubeclient, f.crdclient,
 		k8sI.Apps().V1().Deployments(), i.Serverlesscontroller().V1alpha1().ServerlessFuncs())
 
+	c.SetConfig(f.config)
+	c.clock = clock.NewFakeClock(now)
 	c.crdSynced = alwaysReady
 	c.deploymentsSynced = alwaysReady
-	c.recorder = &record.FakeRecorder{}
+	f.recorder = record.NewFakeRecorder(100)
+	c.recorder = f.recorder
 
 	for _, f := range f.crdLister {
 		i.Serverlesscontroller().V1alpha1().ServerlessFuncs().Informer().GetIndexer().Add(f)
@@ -116,9 +148,35 @@ func (f *fixture) newController() (*Controller, crdinformers.SharedInformerFacto
 		k8sI.Apps().V1().Deployments().Informer().GetIndexer().Add(d)
 	}
 
+	if f.namespaceLister != nil {
+		c.SelectNamespaces(k8sI.Core().V1().Namespaces())
+		c.namespacesSynced = alwaysReady
+		for _, n := range f.namespaceLister {
+			k8sI.Core().V1().Namespaces().Informer().GetIndexer().Add(n)
+		}
+	}
+
 	return c, i, k8sI
 }
 
+// keepStatus makes the plain updates of tracker behave like those of the API
+// server with the status subresource enabled, which ignore the status sent.
+func keepStatus(tracker core.ObjectTracker) core.ReactionFunc {
+	return func(action core.Action) (bool, runtime.Object, error) {
+		update, ok := action.(core.UpdateAction)
+		if !ok || update.GetSubresource() != "" {
+			return false, nil, nil
+		}
+		foo := update.GetObject().(*serverlessv1alpha1.ServerlessFunc)
+		stored, err := tracker.Get(action.GetResource(), foo.Namespace, foo.Name)
+		if err != nil {
+			return false, nil, nil
+		}
+		foo.Status = *stored.(*serverlessv1alpha1.ServerlessFunc).Status.DeepCopy()
+		return false, nil, nil
+	}
+}
+
 func (f *fixture) run(fooName string) {
 	f.runController(fooName, true, false)
 }
@@ -136,8 +194,8 @@ func (f *fixture) runController(fooName string, startInformers bool, expectError
 		k8sI.Start(stopCh)
 	}
 
-	// 一个crd资源
-	err := c.syncHandler(fooName)
+	// sync a single ServerlessFunc
+	_, err := c.syncHandler(fooName)
 	if !expectError && err != nil {
 		f.t.Errorf("error syncing foo: %v", err)
 	} else if expectError && err == nil {
@@ -189,6 +247,18 @@ func checkAction(expected, actual core.Action, t *testing.T) {
 	}
 
 	switch a := actual.(type) {
+	case core.GetActionImpl:
+		e, _ := expected.(core.GetActionImpl)
+		if e.GetName() != a.GetName() {
+			t.Errorf("Action %s %s has wrong name, expected %s got %s",
+				a.GetVerb(), a.GetResource().Resource, e.GetName(), a.GetName())
+		}
+	case core.DeleteActionImpl:
+		e, _ := expected.(core.DeleteActionImpl)
+		if e.GetName() != a.GetName() {
+			t.Errorf("Action %s %s has wrong name, expected %s got %s",
+				a.GetVerb(), a.GetResource().Resource, e.GetName(), a.GetName())
+		}
 	case core.CreateActionImpl:
 		e, _ := expected.(core.CreateActionImpl)
 		expObject := e.GetObject()
@@ -233,7 +303,9 @@ func filterInformerActions(actions []core.Action) []core.Action {
 				action.Matches("watch", "serverlessfuncs") ||
 				action.Matches("create", "serverlessfuncs") ||
 				action.Matches("list", "deployments") ||
-				action.Matches("watch", "deployments")) {
+				action.Matches("watch", "deployments") ||
+				action.Matches("list", "namespaces") ||
+				action.Matches("watch", "namespaces")) {
 			continue
 		}
 		ret = append(ret, action)
@@ -246,19 +318,136 @@ func (f *fixture) expectCreateDeploymentAction(d *apps.Deployment) {
 	f.kubeactions = append(f.kubeactions, core.NewCreateAction(schema.GroupVersionResource{Resource: "deployments"}, d.Namespace, d))
 }
 
+func (f *fixture) expectGetDeploymentAction(d *apps.Deployment) {
+	f.kubeactions = append(f.kubeactions, core.NewGetAction(schema.GroupVersionResource{Resource: "deployments"}, d.Namespace, d.Name))
+}
+
 func (f *fixture) expectUpdateDeploymentAction(d *apps.Deployment) {
 	f.kubeactions = append(f.kubeactions, core.NewUpdateAction(schema.GroupVersionResource{Resource: "deployments"}, d.Namespace, d))
 }
 
+func (f *fixture) expectGetServiceAction(s *corev1.Service) {
+	f.kubeactions = append(f.kubeactions, core.NewGetAction(schema.GroupVersionResource{Resource: "services"}, s.Namespace, s.Name))
+}
+
+func (f *fixture) expectCreateServiceAction(s *corev1.Service) {
+	f.kubeactions = append(f.kubeactions, core.NewCreateAction(schema.GroupVersionResource{Resource: "services"}, s.Namespace, s))
+}
+
+func (f *fixture) expectUpdateServiceAction(s *corev1.Service) {
+	f.kubeactions = append(f.kubeactions, core.NewUpdateAction(schema.GroupVersionResource{Resource: "services"}, s.Namespace, s))
+}
+
+func (f *fixture) expectGetNetworkPolicyAction(p *networkingv1.NetworkPolicy) {
+	f.kubeactions = append(f.kubeactions, core.NewGetAction(schema.GroupVersionResource{Resource: "networkpolicies"}, p.Namespace, p.Name))
+}
+
+func (f *fixture) expectCreateNetworkPolicyAction(p *networkingv1.NetworkPolicy) {
+	f.kubeactions = append(f.kubeactions, core.NewCreateAction(schema.GroupVersionResource{Resource: "networkpolicies"}, p.Namespace, p))
+}
+
+func (f *fixture) expectUpdateNetworkPolicyAction(p *networkingv1.NetworkPolicy) {
+	f.kubeactions = append(f.kubeactions, core.NewUpdateAction(schema.GroupVersionResource{Resource: "networkpolicies"}, p.Namespace, p))
+}
+
+func (f *fixture) expectGetFunctionIngressAction(foo *serverlessv1alpha1.ServerlessFunc) {
+	f.kubeactions = append(f.kubeactions, core.NewGetAction(schema.GroupVersionResource{Resource: "ingresses"}, foo.Namespace, tools.GetFunctionIngressName(foo)))
+}
+
+func (f *fixture) expectGetIngressAction(namespace string) {
+	f.kubeactions = append(f.kubeactions, core.NewGetAction(schema.GroupVersionResource{Resource: "ingresses"}, namespace, f.config.Ingress.Name))
+}
+
+func (f *fixture) expectCreateIngressAction(i *networkingv1.Ingress) {
+	f.kubeactions = append(f.kubeactions, core.NewCreateAction(schema.GroupVersionResource{Resource: "ingresses"}, i.Namespace, i))
+}
+
+func (f *fixture) expectUpdateIngressAction(i *networkingv1.Ingress) {
+	f.kubeactions = append(f.kubeactions, core.NewUpdateAction(schema.GroupVersionResource{Resource: "ingresses"}, i.Namespace, i))
+}
+
+func (f *fixture) expectDeleteIngressAction(namespace string) {
+	f.kubeactions = append(f.kubeactions, core.NewDeleteAction(schema.GroupVersionResource{Resource: "ingresses"}, namespace, f.config.Ingress.Name))
+}
+
+// expectSyncedResources expects the service, network policy and ingress of
+// foo to already be up to date.
+func (f *fixture) expectSyncedResources(foo *serverlessv1alpha1.ServerlessFunc) {
+	f.expectGetServiceAction(newService(foo, f.config))
+	f.expectGetNetworkPolicyAction(newNetworkPolicy(foo, f.config))
+	f.expectGetFunctionIngressAction(foo)
+	f.expectGetIngressAction(foo.Namespace)
+}
+
+func (f *fixture) expectUpdateFooAction(foo *serverlessv1alpha1.ServerlessFunc) {
+	action := core.NewUpdateAction(schema.GroupVersionResource{
+		Group:    foo.GroupVersionKind().Group,
+		Version:  foo.GroupVersionKind().Version,
+		Resource: "serverlessfuncs",
+	}, foo.Namespace, foo)
+	f.actions = append(f.actions, action)
+}
+
 func (f *fixture) expectUpdateFooStatusAction(foo *serverlessv1alpha1.ServerlessFunc) {
 	action := core.NewUpdateSubresourceAction(schema.GroupVersionResource{
 		Group:    foo.GroupVersionKind().Group,
 		Version:  foo.GroupVersionKind().Version,
-		Resource: "serverlessfunc",
-	}, "", foo.Namespace, foo)
+		Resource: "serverlessfuncs",
+	}, "status", foo.Namespace, foo)
 	f.actions = append(f.actions, action)
 }
 
+// expectEvents checks that the events recorded so far are of the types and
+// reasons of expected, in order, given as "Normal Reason".
+func (f *fixture) expectEvents(expected ...string) {
+	var got []string
+	for len(f.recorder.Events) > 0 {
+		fields := strings.SplitN(<-f.recorder.Events, " ", 3)
+		got = append(got, fields[0]+" "+fields[1])
+	}
+	if !reflect.DeepEqual(got, expected) {
+		f.t.Errorf("expected events %q, got %q", expected, got)
+	}
+}
+
+// progressing returns foo with the status of a sync of a deployment whose
+// pods are not created yet, as with the fake clientset.
+func progressing(foo *serverlessv1alpha1.ServerlessFunc) *serverlessv1alpha1.ServerlessFunc {
+	foo = foo.DeepCopy()
+	foo.Status.Phase = serverlessv1alpha1.RolloutPhaseProgressing
+	foo.Status.Reason = reasonRolloutInProgress
+	foo.Status.Message = fmt.Sprintf("0 out of %d new replicas have been updated", *foo.Spec.Replicas)
+	foo.Status.Ready = fmt.Sprintf("0/%d", *foo.Spec.Replicas)
+	foo.Status.URL = "/serverlessfunc/" + foo.Name + "/"
+	foo.Status.ObjectNames = tools.GetObjectNames(foo)
+	return foo
+}
+
+// newFooIngress returns the shared ingress with the paths of foos.
+func (f *fixture) newFooIngress(foos ...*serverlessv1alpha1.ServerlessFunc) *networkingv1.Ingress {
+	ingress := newIngress(metav1.NamespaceDefault, f.config)
+	for _, foo := range foos {
+		ingress, _ = updateIngress(ingress, foo)
+	}
+	return ingress
+}
+
+func newIngressPath(path, serviceName string) networkingv1.HTTPIngressPath {
+	pathTypePrefix := networkingv1.PathTypePrefix
+	return networkingv1.HTTPIngressPath{
+		Path:     path,
+		PathType: &pathTypePrefix,
+		Backend: networkingv1.IngressBackend{
+			Service: &networkingv1.IngressServiceBackend{
+				Name: serviceName,
+				Port: networkingv1.ServiceBackendPort{
+					Number: 80,
+				},
+			},
+		},
+	}
+}
+
 func getKey(foo *serverlessv1alpha1.ServerlessFunc, t *testing.T) string {
 	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(foo)
 	if err != nil {
@@ -275,53 +464,155 @@ func TestCreatesDeployment(t *testing.T) {
 	f.crdLister = append(f.crdLister, foo)
 	f.objects = append(f.objects, foo)
 
-	expDeployment := newDeployment(foo)
+	expDeployment := newDeployment(foo, f.config)
 
-	// 创建了deployment后，预期的动作
+	// actions expected once the deployment is created
 	f.expectCreateDeploymentAction(expDeployment)
-	// ...后面有一堆，先不看了
-	// f.expectUpdateFooStatusAction(foo)
+	f.expectGetServiceAction(newService(foo, f.config))
+	f.expectCreateServiceAction(newService(foo, f.config))
+	f.expectGetNetworkPolicyAction(newNetworkPolicy(foo, f.config))
+	f.expectCreateNetworkPolicyAction(newNetworkPolicy(foo, f.config))
+	f.expectGetFunctionIngressAction(foo)
+	f.expectGetIngressAction(foo.Namespace)
+	f.expectCreateIngressAction(f.newFooIngress(foo))
+	f.expectUpdateFooStatusAction(progressing(foo))
 
 	f.run(getKey(foo, t))
+	f.expectEvents(
+		"Normal "+ReasonDeploymentCreated,
+		"Normal "+ReasonServiceCreated,
+		"Normal "+ReasonNetworkPolicyCreated,
+		"Normal "+ReasonRouteAdded,
+		"Normal "+SuccessSynced,
+	)
 }
 
 func TestDoNothing(t *testing.T) {
 	f := newFixture(t)
 	foo := newFoo("test", int32Ptr(1))
-	d := newDeployment(foo)
+	d := newDeployment(foo, f.config)
 
 	f.crdLister = append(f.crdLister, foo)
 	f.objects = append(f.objects, foo)
 	f.deploymentLister = append(f.deploymentLister, d)
-	f.kubeobjects = append(f.kubeobjects, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
 
-	f.expectUpdateFooStatusAction(foo)
+	f.expectSyncedResources(foo)
+	f.expectUpdateFooStatusAction(progressing(foo))
 	f.run(getKey(foo, t))
+	// a resync records nothing
+	f.expectEvents()
 }
 
 func TestUpdateDeployment(t *testing.T) {
 	f := newFixture(t)
 	foo := newFoo("test", int32Ptr(1))
-	d := newDeployment(foo)
+	d := newDeployment(foo, f.config)
 
 	// Update replicas
 	foo.Spec.Replicas = int32Ptr(2)
-	expDeployment := newDeployment(foo)
+	expDeployment := newDeployment(foo, f.config)
 
 	f.crdLister = append(f.crdLister, foo)
 	f.objects = append(f.objects, foo)
 	f.deploymentLister = append(f.deploymentLister, d)
-	f.kubeobjects = append(f.kubeobjects, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
 
-	f.expectUpdateFooStatusAction(foo)
+	f.expectUpdateFooStatusAction(progressing(foo))
 	f.expectUpdateDeploymentAction(expDeployment)
+	f.expectSyncedResources(foo)
 	f.run(getKey(foo, t))
+	f.expectEvents("Normal "+ReasonDeploymentUpdated, "Normal "+SuccessSynced)
+}
+
+func TestScaleToZero(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	d := newDeployment(foo, f.config)
+	foo.Spec.Replicas = int32Ptr(0)
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+	c, _, _ := f.newController()
+
+	if _, err := c.syncHandler(getKey(foo, t)); err != nil {
+		t.Fatal(err)
+	}
+	// without replicas to wait for, the rollout completes at once
+	f.expectEvents(
+		"Normal "+ReasonDeploymentUpdated,
+		"Normal "+ReasonScaledToZero,
+		"Normal "+ReasonRolloutCompleted,
+		"Normal "+SuccessSynced,
+	)
+}
+
+func TestSummarizeDiff(t *testing.T) {
+	diff := []tools.DiffResult{
+		{Field: "Replicas", Left: int32(2), Right: int32(1)},
+		{Field: "Template", Left: strings.Repeat("x", maxDiffValueLength+1), Right: ""},
+	}
+	expected := "Replicas (1 -> 2), Template"
+	if got := summarizeDiff(diff); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestRolloutEvents(t *testing.T) {
+	tests := []struct {
+		name     string
+		phase    serverlessv1alpha1.RolloutPhase
+		status   apps.DeploymentStatus
+		expected []string
+	}{
+		{
+			name:     "completed",
+			phase:    serverlessv1alpha1.RolloutPhaseProgressing,
+			status:   apps.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+			expected: []string{"Normal " + ReasonRolloutCompleted},
+		},
+		{
+			name:   "already completed",
+			phase:  serverlessv1alpha1.RolloutPhaseAvailable,
+			status: apps.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+		},
+		{
+			name:  "stalled",
+			phase: serverlessv1alpha1.RolloutPhaseProgressing,
+			status: apps.DeploymentStatus{Conditions: []apps.DeploymentCondition{
+				{Type: apps.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: reasonProgressDeadlineExceeded},
+			}},
+			expected: []string{"Warning " + ReasonRolloutFailed},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFixture(t)
+			foo := newFoo("test", int32Ptr(1))
+			foo.Status.Phase = test.phase
+			d := newDeployment(foo, f.config)
+			d.Status = test.status
+
+			f.crdLister = append(f.crdLister, foo)
+			f.objects = append(f.objects, foo)
+			f.deploymentLister = append(f.deploymentLister, d)
+			f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+			c, _, _ := f.newController()
+
+			if _, err := c.syncHandler(getKey(foo, t)); err != nil {
+				t.Fatal(err)
+			}
+			f.expectEvents(test.expected...)
+		})
+	}
 }
 
 func TestNotControlledByUs(t *testing.T) {
 	f := newFixture(t)
 	foo := newFoo("test", int32Ptr(1))
-	d := newDeployment(foo)
+	d := newDeployment(foo, f.config)
 
 	d.ObjectMeta.OwnerReferences = []metav1.OwnerReference{}
 
@@ -330,7 +621,1137 @@ func TestNotControlledByUs(t *testing.T) {
 	f.deploymentLister = append(f.deploymentLister, d)
 	f.kubeobjects = append(f.kubeobjects, d)
 
+	failed := foo.DeepCopy()
+	failed.Status.Conditions = []metav1.Condition{{
+		Type:               serverlessv1alpha1.ConditionReconcileError,
+		Status:             metav1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             ErrResourceExists,
+		Message:            fmt.Sprintf(MessageResourceExists, d.Name),
+	}}
+	f.expectUpdateFooStatusAction(failed)
+	f.runExpectError(getKey(foo, t))
+
+	select {
+	case event := <-f.recorder.Events:
+		if !strings.HasPrefix(event, corev1.EventTypeWarning+" "+ErrResourceExists) {
+			t.Errorf("expected %s event, got %q", ErrResourceExists, event)
+		}
+	default:
+		t.Errorf("expected %s event, got none", ErrResourceExists)
+	}
+}
+
+func TestSyncClearsReconcileError(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	foo.Status.Conditions = []metav1.Condition{{
+		Type:               serverlessv1alpha1.ConditionReconcileError,
+		Status:             metav1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             ErrResourceExists,
+	}}
+	d := newDeployment(foo, f.config)
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+
+	fixed := foo.DeepCopy()
+	fixed.Status.Conditions = []metav1.Condition{}
+	f.expectSyncedResources(foo)
+	f.expectUpdateFooStatusAction(progressing(fixed))
+	f.run(getKey(foo, t))
+}
+
+func TestProcessNextWorkItem(t *testing.T) {
+	tests := []struct {
+		name string
+		// createErr is returned when creating the deployment
+		createErr error
+		// ownedByOther makes the deployment belong to another object
+		ownedByOther bool
+		expected     []queueItem
+		// expectCondition expects the ReconcileError condition of the
+		// ServerlessFunc
+		expectCondition bool
+	}{
+		{
+			// the new deployment is polled until its rollout is stable
+			name:     "success",
+			expected: []queueItem{{Key: "default/test", State: queueStateWaiting}},
+		},
+		{
+			name:      "transient error",
+			createErr: apierrors.NewServiceUnavailable("try again"),
+			expected:  []queueItem{{Key: "default/test", State: queueStateWaiting, Requeues: 1}},
+		},
+		{
+			name:      "conflict",
+			createErr: apierrors.NewConflict(apps.Resource("deployments"), "func-test-deployment", fmt.Errorf("stale")),
+			expected:  []queueItem{{Key: "default/test", State: queueStateWaiting}},
+		},
+		{
+			name:            "permanent error",
+			ownedByOther:    true,
+			expectCondition: true,
+		},
+		{
+			name:            "invalid object",
+			createErr:       apierrors.NewInvalid(schema.GroupKind{Group: apps.GroupName, Kind: "Deployment"}, "func-test-deployment", nil),
+			expectCondition: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFixture(t)
+			foo := newFoo("test", int32Ptr(1))
+			f.crdLister = append(f.crdLister, foo)
+			f.objects = append(f.objects, foo)
+			if test.ownedByOther {
+				d := newDeployment(foo, f.config)
+				d.OwnerReferences = nil
+				f.deploymentLister = append(f.deploymentLister, d)
+				f.kubeobjects = append(f.kubeobjects, d)
+			}
+			c, _, _ := f.newController()
+			// retries stay in the queue until checked
+			c.SetBackoff(Backoff{Base: time.Hour, Max: time.Hour, QPS: 10, Burst: 100})
+			if test.createErr != nil {
+				f.kubeclient.PrependReactor("create", "deployments", func(action core.Action) (bool, runtime.Object, error) {
+					return true, nil, test.createErr
+				})
+			}
+
+			c.workqueue.Add(getKey(foo, t))
+			if !c.processNextWorkItem() {
+				t.Fatal("expected the queue to be running")
+			}
+
+			var items []queueItem
+			for _, item := range c.workqueue.Items() {
+				items = append(items, queueItem{Key: item.Key, State: item.State, Requeues: item.Requeues})
+			}
+			if !reflect.DeepEqual(items, test.expected) {
+				t.Errorf("expected queue %+v, got %+v", test.expected, items)
+			}
+			updated, err := f.crdclient.ServerlesscontrollerV1alpha1().ServerlessFuncs(foo.Namespace).Get(context.TODO(), foo.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			condition := meta.FindStatusCondition(updated.Status.Conditions, serverlessv1alpha1.ConditionReconcileError)
+			if test.expectCondition != (condition != nil) {
+				t.Errorf("expected condition %v, got %+v", test.expectCondition, condition)
+			}
+		})
+	}
+}
+
+func TestRequeueAfter(t *testing.T) {
+	f := newFixture(t)
+	c, _, _ := f.newController()
+
+	retries := metrics.WorkqueueRetries("Foos")
+	before := testutil.ToFloat64(retries)
+	c.handleResult("default/test", Result{RequeueAfter: time.Minute}, nil)
+	items := c.workqueue.Items()
+	if len(items) != 1 || items[0].State != queueStateWaiting || items[0].Requeues != 0 {
+		t.Errorf("expected default/test to wait without backoff, got %+v", items)
+	}
+	if n := testutil.ToFloat64(retries) - before; n != 0 {
+		t.Errorf("expected polling not to count as a retry, got %v retries", n)
+	}
+	c.workqueue.AddRateLimited("default/test")
+	if n := testutil.ToFloat64(retries) - before; n != 1 {
+		t.Errorf("expected 1 retry, got %v", n)
+	}
+}
+
+func TestRolloutStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutate   func(d *apps.Deployment)
+		expected rollout
+	}{
+		{
+			name: "spec not observed",
+			mutate: func(d *apps.Deployment) {
+				d.Generation = 2
+				d.Status.ObservedGeneration = 1
+			},
+			expected: rollout{serverlessv1alpha1.RolloutPhaseProgressing, reasonObservedGenerationPending, "Waiting for the deployment spec update to be observed"},
+		},
+		{
+			name: "replicas not updated",
+			mutate: func(d *apps.Deployment) {
+				d.Status = apps.DeploymentStatus{Replicas: 2, UpdatedReplicas: 1, Conditions: []apps.DeploymentCondition{
+					{Type: apps.DeploymentProgressing, Status: corev1.ConditionTrue, Reason: "ReplicaSetUpdated"},
+				}}
+			},
+			expected: rollout{serverlessv1alpha1.RolloutPhaseProgressing, "ReplicaSetUpdated", "1 out of 2 new replicas have been updated"},
+		},
+		{
+			name: "old replicas terminating",
+			mutate: func(d *apps.Deployment) {
+				d.Status = apps.DeploymentStatus{Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 2}
+			},
+			expected: rollout{serverlessv1alpha1.RolloutPhaseProgressing, reasonRolloutInProgress, "1 old replicas are pending termination"},
+		},
+		{
+			name: "replicas not available",
+			mutate: func(d *apps.Deployment) {
+				d.Status = apps.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1}
+			},
+			expected: rollout{serverlessv1alpha1.RolloutPhaseProgressing, reasonRolloutInProgress, "1 of 2 updated replicas are available"},
+		},
+		{
+			name: "available",
+			mutate: func(d *apps.Deployment) {
+				d.Status = apps.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2, Conditions: []apps.DeploymentCondition{
+					{Type: apps.DeploymentAvailable, Status: corev1.ConditionTrue, Reason: "MinimumReplicasAvailable"},
+					{Type: apps.DeploymentProgressing, Status: corev1.ConditionTrue, Reason: "NewReplicaSetAvailable"},
+				}}
+			},
+			expected: rollout{serverlessv1alpha1.RolloutPhaseAvailable, "MinimumReplicasAvailable", "2 of 2 replicas are available"},
+		},
+		{
+			name: "progress deadline exceeded",
+			mutate: func(d *apps.Deployment) {
+				d.Status = apps.DeploymentStatus{Replicas: 2, UpdatedReplicas: 1, Conditions: []apps.DeploymentCondition{
+					{Type: apps.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: reasonProgressDeadlineExceeded, Message: "ReplicaSet has timed out progressing."},
+				}}
+			},
+			expected: rollout{serverlessv1alpha1.RolloutPhaseStalled, reasonProgressDeadlineExceeded, "ReplicaSet has timed out progressing."},
+		},
+		{
+			name: "replica failure",
+			mutate: func(d *apps.Deployment) {
+				d.Status = apps.DeploymentStatus{Conditions: []apps.DeploymentCondition{
+					{Type: apps.DeploymentReplicaFailure, Status: corev1.ConditionTrue, Reason: "FailedCreate", Message: "exceeded quota"},
+				}}
+			},
+			expected: rollout{serverlessv1alpha1.RolloutPhaseStalled, "FailedCreate", "exceeded quota"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := newDeployment(newFoo("test", int32Ptr(2)), config.Default())
+			test.mutate(d)
+			if got := rolloutStatus(d); got != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestSyncStopsPollingOnceAvailable(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	d := newDeployment(foo, f.config)
+	d.Status = apps.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, ReadyReplicas: 1, AvailableReplicas: 1}
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+	c, _, _ := f.newController()
+
+	result, err := c.syncHandler(getKey(foo, t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter != 0 {
+		t.Errorf("expected no requeue once available, got %v", result.RequeueAfter)
+	}
+	updated, err := f.crdclient.ServerlesscontrollerV1alpha1().ServerlessFuncs(foo.Namespace).Get(context.TODO(), foo.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.Phase != serverlessv1alpha1.RolloutPhaseAvailable || updated.Status.AvailableReplicas != 1 {
+		t.Errorf("expected 1 available replica, got %+v", updated.Status)
+	}
+	if updated.Status.Ready != "1/1" || updated.Status.URL != "/serverlessfunc/test/" {
+		t.Errorf("expected 1/1 ready replicas served at /serverlessfunc/test/, got %+v", updated.Status)
+	}
+}
+
+func TestSyncPollsWhileProgressing(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	d := newDeployment(foo, f.config)
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+	c, _, _ := f.newController()
+
+	result, err := c.syncHandler(getKey(foo, t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter != rolloutPollPeriod {
+		t.Errorf("expected requeue after %v, got %v", rolloutPollPeriod, result.RequeueAfter)
+	}
+}
+
+func TestDeploymentEventEnqueuesFoo(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	f.crdLister = append(f.crdLister, foo)
+	c, _, _ := f.newController()
+
+	c.handleObject(newDeployment(foo, f.config))
+	items := c.workqueue.Items()
+	if len(items) != 1 || items[0].Key != getKey(foo, t) {
+		t.Errorf("expected %s to be queued, got %+v", getKey(foo, t), items)
+	}
+}
+
+func TestIngressKeepsForeignPaths(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	d := newDeployment(foo, f.config)
+	ingress := newIngress(metav1.NamespaceDefault, f.config)
+	ingress.Spec.Rules[0].HTTP.Paths = append(ingress.Spec.Rules[0].HTTP.Paths, newIngressPath("/manual", "manual-service"))
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), ingress)
+
+	expIngress := ingress.DeepCopy()
+	expIngress.Spec.Rules[0].HTTP.Paths = append(expIngress.Spec.Rules[0].HTTP.Paths, newIngressPath(tools.GetIngressPath(foo), tools.GetServiceName(foo)))
+	tools.SetIngressRoutes(expIngress, map[string]string{foo.Name: tools.GetIngressPath(foo)})
+
+	f.expectSyncedResources(foo)
+	f.expectUpdateIngressAction(expIngress)
+	f.expectUpdateFooStatusAction(progressing(foo))
+	f.run(getKey(foo, t))
+}
+
+func TestIngressAdoptsUnrecordedPath(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	d := newDeployment(foo, f.config)
+	// paths written before ownership was recorded carry no annotation
+	ingress := newIngress(metav1.NamespaceDefault, f.config)
+	ingress.Spec.Rules[0].HTTP.Paths = append(ingress.Spec.Rules[0].HTTP.Paths, newIngressPath(tools.GetIngressPath(foo), tools.GetServiceName(foo)))
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), ingress)
+
+	f.expectSyncedResources(foo)
+	f.expectUpdateIngressAction(f.newFooIngress(foo))
+	f.expectUpdateFooStatusAction(progressing(foo))
+	f.run(getKey(foo, t))
+}
+
+func TestIngressPathConflict(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	d := newDeployment(foo, f.config)
+	ingress := newIngress(metav1.NamespaceDefault, f.config)
+	ingress.Spec.Rules[0].HTTP.Paths = append(ingress.Spec.Rules[0].HTTP.Paths, newIngressPath(tools.GetIngressPath(foo), "manual-service"))
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), ingress)
+
+	// the foreign path is left alone
+	expFoo := progressing(foo)
+	meta.SetStatusCondition(&expFoo.Status.Conditions, metav1.Condition{
+		Type:               serverlessv1alpha1.ConditionIngressPathConflict,
+		Status:             metav1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             ErrIngressPathConflict,
+		Message:            fmt.Sprintf(MessageIngressPathConflict, tools.GetIngressPath(foo), ingress.Name, "a path not managed by the controller"),
+	})
+	f.expectSyncedResources(foo)
+	f.expectUpdateFooStatusAction(expFoo)
+	f.run(getKey(foo, t))
+	f.expectEvents("Warning " + ErrIngressPathConflict)
+
+	// a resync keeps the condition without repeating the event
+	f = newFixture(t)
+	f.crdLister = append(f.crdLister, expFoo)
+	f.objects = append(f.objects, expFoo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), ingress)
+	f.expectSyncedResources(foo)
+	f.expectUpdateFooStatusAction(expFoo)
+	f.run(getKey(foo, t))
+	f.expectEvents()
+}
+
+func TestIngressPrunesOrphanedPaths(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	gone := newFoo("gone", int32Ptr(1))
+	d := newDeployment(foo, f.config)
+	ingress := f.newFooIngress(foo, gone)
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), ingress)
+
+	f.expectSyncedResources(foo)
+	f.expectUpdateIngressAction(f.newFooIngress(foo))
+	f.expectUpdateFooStatusAction(progressing(foo))
+	f.run(getKey(foo, t))
+	f.expectEvents("Normal " + ReasonRouteRemoved)
+}
+
+func TestDeletedFooRemovesIngress(t *testing.T) {
+	f := newFixture(t)
+	gone := newFoo("gone", int32Ptr(1))
+
+	f.kubeobjects = append(f.kubeobjects, f.newFooIngress(gone))
+
+	// the last path is gone, so is the ingress
+	f.expectGetIngressAction(gone.Namespace)
+	f.expectDeleteIngressAction(gone.Namespace)
+	f.run(getKey(gone, t))
+}
+
+func TestAuthByPilot(t *testing.T) {
+	cfg := config.Default()
+	foo := newFoo("test", int32Ptr(1))
+	foo.Spec.Auth = &serverlessv1alpha1.AuthSpec{
+		Type: serverlessv1alpha1.AuthTypeJWT,
+		JWT: &serverlessv1alpha1.JWTAuth{
+			JWKSConfigMap: "keys",
+			Issuer:        "https://issuer",
+		},
+		RateLimit: &serverlessv1alpha1.RateLimit{RequestsPerSecond: 5},
+	}
+	d := newDeployment(foo, cfg)
+
+	env := map[string]string{}
+	for _, e := range d.Spec.Template.Spec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	expected := map[string]string{
+		"SERVERLESS_AUTH_TYPE":           "jwt",
+		"SERVERLESS_AUTH_JWKS_FILE":      "/etc/serverless/jwks/jwks.json",
+		"SERVERLESS_AUTH_JWT_ISSUER":     "https://issuer",
+		"SERVERLESS_AUTH_RATE_LIMIT_RPS": "5",
+	}
+	for name, value := range expected {
+		if env[name] != value {
+			t.Errorf("expected env %s=%q, got %q", name, value, env[name])
+		}
+	}
+	if d.Spec.Template.Annotations["prometheus.io/scrape"] != "true" {
+		t.Errorf("expected pilot metrics to be scraped")
+	}
+	if d.Annotations[tools.TemplateHashAnnotation] == newDeployment(newFoo("test", int32Ptr(1)), cfg).Annotations[tools.TemplateHashAnnotation] {
+		t.Errorf("expected auth to change the template hash")
+	}
+}
+
+func TestAuthByNginxIngress(t *testing.T) {
+	f := newFixture(t)
+	f.config.Ingress.Profile = config.IngressProfileNginx
+	foo := newFoo("test", int32Ptr(1))
+	foo.Spec.Auth = &serverlessv1alpha1.AuthSpec{
+		Type:       serverlessv1alpha1.AuthTypeBasic,
+		SecretName: "htpasswd",
+		RateLimit:  &serverlessv1alpha1.RateLimit{RequestsPerSecond: 5},
+	}
+	d := newDeployment(foo, f.config)
+	env := map[string]string{}
+	for _, e := range d.Spec.Template.Spec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	if value, ok := env["SERVERLESS_AUTH_TYPE"]; ok {
+		t.Errorf("expected the ingress to check credentials, got pilot SERVERLESS_AUTH_TYPE=%s", value)
+	}
+	// the limits are per user, taken from the credentials the ingress checked
+	if env["SERVERLESS_AUTH_CALLER"] != "basic-user" || env["SERVERLESS_AUTH_RATE_LIMIT_RPS"] != "5" {
+		t.Errorf("expected the pilot to limit each basic auth user, got env %v", env)
+	}
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	// the path moves from the shared ingress to the ingress of foo
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+
+	expIngress := newFunctionIngress(foo, f.config)
+	if expIngress.Annotations["nginx.ingress.kubernetes.io/auth-secret"] != "htpasswd" {
+		t.Errorf("expected auth annotations on ingress, got %v", expIngress.Annotations)
+	}
+	f.expectGetServiceAction(newService(foo, f.config))
+	f.expectGetNetworkPolicyAction(newNetworkPolicy(foo, f.config))
+	f.expectGetFunctionIngressAction(foo)
+	f.expectCreateIngressAction(expIngress)
+	f.expectGetIngressAction(foo.Namespace)
+	f.expectDeleteIngressAction(foo.Namespace)
+	f.expectUpdateFooStatusAction(progressing(foo))
+	f.run(getKey(foo, t))
+}
+
+func TestNetworkPolicyDrift(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	d := newDeployment(foo, f.config)
+	// someone opened the function to everybody
+	policy := newNetworkPolicy(foo, f.config)
+	policy.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{{}}
+
+	foo.Spec.Network = &serverlessv1alpha1.NetworkSpec{
+		AllowFrom: []serverlessv1alpha1.FunctionName{"caller"},
+		Egress: &serverlessv1alpha1.EgressSpec{
+			CIDRs: []serverlessv1alpha1.CIDR{"10.0.0.0/8"},
+			DNS:   true,
+		},
+	}
+	expPolicy := newNetworkPolicy(foo, f.config)
+	if n := len(expPolicy.Spec.Ingress[0].From); n != 2 {
+		t.Errorf("expected ingress from the ingress controller and caller, got %d peers", n)
+	}
+	if n := len(expPolicy.Spec.Egress); n != 2 {
+		t.Errorf("expected egress to the cidr and dns, got %d rules", n)
+	}
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), policy, f.newFooIngress(foo))
+
+	f.expectGetServiceAction(newService(foo, f.config))
+	f.expectGetNetworkPolicyAction(expPolicy)
+	f.expectUpdateNetworkPolicyAction(expPolicy)
+	f.expectGetFunctionIngressAction(foo)
+	f.expectGetIngressAction(foo.Namespace)
+	f.expectUpdateFooStatusAction(progressing(foo))
+	f.run(getKey(foo, t))
+}
+
+func TestHTTPSettings(t *testing.T) {
+	cfg := config.Default()
+	foo := newFoo("test", int32Ptr(1))
+	maxRequestSize := resource.MustParse("1Mi")
+	foo.Spec.HTTP = &serverlessv1alpha1.HTTPSpec{
+		CORS: &serverlessv1alpha1.CORSSpec{
+			AllowOrigins: []serverlessv1alpha1.Origin{"https://example.com"},
+		},
+		RequestTimeout: &metav1.Duration{Duration: 1500 * time.Millisecond},
+		MaxRequestSize: &maxRequestSize,
+	}
+
+	env := map[string]string{}
+	for _, e := range newDeployment(foo, cfg).Spec.Template.Spec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	expected := map[string]string{
+		"SERVERLESS_HTTP_CORS_ALLOW_ORIGINS": "https://example.com",
+		"SERVERLESS_HTTP_REQUEST_TIMEOUT":    "1.5s",
+		"SERVERLESS_HTTP_MAX_REQUEST_SIZE":   "1048576",
+	}
+	for name, value := range expected {
+		if env[name] != value {
+			t.Errorf("expected env %s=%q, got %q", name, value, env[name])
+		}
+	}
+
+	annotations := newFunctionIngress(foo, cfg).Annotations
+	expected = map[string]string{
+		"nginx.ingress.kubernetes.io/enable-cors":        "true",
+		"nginx.ingress.kubernetes.io/cors-allow-origin":  "https://example.com",
+		"nginx.ingress.kubernetes.io/proxy-read-timeout": "2",
+		"nginx.ingress.kubernetes.io/proxy-body-size":    "1048576",
+	}
+	for name, value := range expected {
+		if annotations[name] != value {
+			t.Errorf("expected annotation %s=%q, got %q", name, value, annotations[name])
+		}
+	}
+
+	// nginx takes whole seconds, never in exponent form
+	foo.Spec.HTTP.RequestTimeout = &metav1.Duration{Duration: 1500000 * time.Second}
+	if timeout := newFunctionIngress(foo, cfg).Annotations["nginx.ingress.kubernetes.io/proxy-read-timeout"]; timeout != "1500000" {
+		t.Errorf("expected proxy-read-timeout 1500000, got %q", timeout)
+	}
+}
+
+func TestFunctionResources(t *testing.T) {
+	cfg := config.Default()
+	foo := newFoo("test", int32Ptr(1))
+	limits := corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}
+	foo.Spec.Resources = &serverlessv1alpha1.ResourcesSpec{RPCServer: limits}
+
+	containers := newDeployment(foo, cfg).Spec.Template.Spec.Containers
+	// the limits left out by the function are those of the config
+	if pilot := containers[0].Resources.Limits; !apiequality.Semantic.DeepEqual(pilot, cfg.Deployment.Pilot.Limits) {
+		t.Errorf("expected the pilot limits of the config, got %v", pilot)
+	}
+	if rpcServer := containers[1].Resources.Limits; !apiequality.Semantic.DeepEqual(rpcServer, limits) {
+		t.Errorf("expected the rpcserver limits %v, got %v", limits, rpcServer)
+	}
+}
+
+func TestGRPCProtocol(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	// the function was created before it switched to grpc
+	d := newDeployment(foo, f.config)
+	service := newService(foo, f.config)
+	service.Spec.ClusterIP = "10.0.0.1"
+
+	foo.Spec.Protocol = serverlessv1alpha1.ProtocolGRPC
+	expService := service.DeepCopy()
+	expService.Spec.Ports = newService(foo, f.config).Spec.Ports
+	if n := len(expService.Spec.Ports); n != 2 || *expService.Spec.Ports[1].AppProtocol != "grpc" {
+		t.Errorf("expected the rpc port to be exposed as grpc, got %v", expService.Spec.Ports)
+	}
+	if p := newFunctionIngress(foo, f.config).Annotations["nginx.ingress.kubernetes.io/backend-protocol"]; p != "GRPC" {
+		t.Errorf("expected GRPC backend protocol, got %q", p)
+	}
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, service, newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+
+	f.expectUpdateDeploymentAction(newDeployment(foo, f.config))
+	f.expectGetServiceAction(service)
+	f.expectUpdateServiceAction(expService)
+	f.expectGetNetworkPolicyAction(newNetworkPolicy(foo, f.config))
+	f.expectGetFunctionIngressAction(foo)
+	f.expectGetIngressAction(foo.Namespace)
+	// the shared ingress cannot set the backend protocol of one path
+	expFoo := progressing(foo)
+	meta.SetStatusCondition(&expFoo.Status.Conditions, metav1.Condition{
+		Type:               serverlessv1alpha1.ConditionProtocolUnsupported,
+		Status:             metav1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             ErrProtocolUnsupported,
+		Message:            fmt.Sprintf(MessageProtocolUnsupported, serverlessv1alpha1.ProtocolGRPC, config.IngressProfileNginx),
+	})
+	f.expectUpdateFooStatusAction(expFoo)
+	f.run(getKey(foo, t))
+	f.expectEvents(
+		"Normal "+ReasonDeploymentUpdated,
+		"Normal "+ReasonServiceUpdated,
+		"Warning "+ErrProtocolUnsupported,
+		"Normal "+SuccessSynced,
+	)
+
+	// resyncs and rollout polls keep the condition without repeating the event
+	f = newFixture(t)
+	f.crdLister = append(f.crdLister, expFoo)
+	f.objects = append(f.objects, expFoo)
+	d = newDeployment(foo, f.config)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, expService, newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+	f.expectSyncedResources(foo)
+	f.expectUpdateFooStatusAction(expFoo)
+	f.run(getKey(foo, t))
+	f.expectEvents()
+}
+
+func TestConfigChangeUpdatesDeployment(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	d := newDeployment(foo, f.config)
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+
+	c, _, _ := f.newController()
+	c.SetConfig(config.Default())
+	if n := c.workqueue.Len(); n != 0 {
+		t.Errorf("expected an unchanged config not to resync, got %d queued", n)
+	}
+	cfg := config.Default()
+	cfg.Deployment.Pilot.Image = "pilot:v2"
+	c.SetConfig(cfg)
+	if n := c.workqueue.Len(); n != 1 {
+		t.Errorf("expected a config change to resync every foo, got %d queued", n)
+	}
+
+	// the new image is rolled out by the resync
+	f.config = cfg
+	expDeployment := newDeployment(foo, f.config)
+	if image := expDeployment.Spec.Template.Spec.Containers[0].Image; image != "pilot:v2" {
+		t.Errorf("expected pilot image from config, got %q", image)
+	}
+	f.expectUpdateFooStatusAction(progressing(foo))
+	f.expectUpdateDeploymentAction(expDeployment)
+	f.expectSyncedResources(foo)
+	f.run(getKey(foo, t))
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	f := newFixture(t)
+	c, _, _ := f.newController()
+	mux := http.NewServeMux()
+	c.InstallHandlers(mux)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get("/healthz"); rec.Code != http.StatusOK {
+		t.Errorf("expected healthy, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// a worker stuck on a foo makes the controller unhealthy
+	c.workqueue.Add("default/stuck")
+	item, _ := c.workqueue.Get()
+	c.workqueue.processing[item] = time.Now().Add(-2 * stuckThreshold)
+	if rec := get("/healthz"); rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "default/stuck") {
+		t.Errorf("expected a stuck worker to be reported, got %d: %s", rec.Code, rec.Body)
+	}
+	c.workqueue.Done(item)
+
+	if rec := get("/readyz"); rec.Code != http.StatusOK || rec.Body.String() != "ok: standby\n" {
+		t.Errorf("expected a ready standby, got %d: %s", rec.Code, rec.Body)
+	}
+	c.setLeading(true)
+	if rec := get("/readyz"); rec.Body.String() != "ok: leader\n" {
+		t.Errorf("expected a ready leader, got %s", rec.Body)
+	}
+	c.crdSynced = func() bool { return false }
+	if rec := get("/readyz"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected not ready before the caches sync, got %d", rec.Code)
+	}
+}
+
+func TestDebugEndpoint(t *testing.T) {
+	f := newFixture(t)
+	c, _, _ := f.newController()
+	mux := http.NewServeMux()
+	c.InstallHandlers(mux)
+
+	c.workqueue.Add("default/a")
+	c.workqueue.AddAfter("default/b", time.Hour)
+	c.recordResult("default/c", time.Now(), fmt.Errorf("boom"))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug", nil))
+	var state debugState
+	if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+		t.Fatalf("decode debug state: %v", err)
+	}
+	if n := len(state.Queue); n != 2 {
+		t.Fatalf("expected 2 queued items, got %d: %+v", n, state.Queue)
+	}
+	if item := state.Queue[0]; item.Key != "default/a" || item.State != queueStateQueued {
+		t.Errorf("expected default/a to be queued, got %+v", item)
+	}
+	if item := state.Queue[1]; item.Key != "default/b" || item.State != queueStateWaiting || item.ReadyAt == nil {
+		t.Errorf("expected default/b to wait, got %+v", item)
+	}
+	if result := state.Results["default/c"]; result.Error != "boom" {
+		t.Errorf("expected the last error of default/c, got %+v", result)
+	}
+	if !state.Informers["serverlessfuncs"].Synced {
+		t.Errorf("expected synced informers, got %+v", state.Informers)
+	}
+}
+
+func TestAdoptsUnlabelledDeployment(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	d := newDeployment(foo, f.config)
+	// created before the managed-by label, so missing from the informer
+	delete(d.Labels, tools.ManagedByLabel)
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+
+	expDeployment := newDeployment(foo, f.config)
+	f.expectCreateDeploymentAction(expDeployment)
+	f.expectGetDeploymentAction(expDeployment)
+	f.expectUpdateDeploymentAction(expDeployment)
+	f.expectSyncedResources(foo)
+	f.expectUpdateFooStatusAction(progressing(foo))
+	f.run(getKey(foo, t))
+
+	updated, err := f.kubeclient.AppsV1().Deployments(foo.Namespace).Get(context.TODO(), d.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Labels[tools.ManagedByLabel] != tools.ManagedBy {
+		t.Errorf("expected the deployment to be labelled %s=%s, got %v", tools.ManagedByLabel, tools.ManagedBy, updated.Labels)
+	}
+}
+
+func TestSkipsUnselectedNamespace(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.namespaceLister = []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "selected"}},
+	}
+
+	f.run(getKey(foo, t))
+}
+
+func TestSyncsSelectedNamespace(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	d := newDeployment(foo, f.config)
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+	f.namespaceLister = []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: foo.Namespace}},
+	}
+
+	f.expectSyncedResources(foo)
+	f.expectUpdateFooStatusAction(progressing(foo))
+	f.run(getKey(foo, t))
+}
+
+func TestMultiNamespaceListers(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+	crdclient := crdfake.NewSimpleClientset()
+	var deploymentInformers []appsinformers.DeploymentInformer
+	var crdInformers []serverlessinformers.ServerlessFuncInformer
+	for _, namespace := range []string{"a", "b"} {
+		k8sI := kubeinformers.NewSharedInformerFactoryWithOptions(client, noResyncPeriodFunc(), kubeinformers.WithNamespace(namespace))
+		i := crdinformers.NewSharedInformerFactoryWithOptions(crdclient, noResyncPeriodFunc(), crdinformers.WithNamespace(namespace))
+		foo := newFoo("test", nil)
+		foo.Namespace = namespace
+		k8sI.Apps().V1().Deployments().Informer().GetIndexer().Add(newDeployment(foo, config.Default()))
+		i.Serverlesscontroller().V1alpha1().ServerlessFuncs().Informer().GetIndexer().Add(foo)
+		deploymentInformers = append(deploymentInformers, k8sI.Apps().V1().Deployments())
+		crdInformers = append(crdInformers, i.Serverlesscontroller().V1alpha1().ServerlessFuncs())
+	}
+	c := NewMultiNamespaceController(client, crdclient, deploymentInformers, crdInformers)
+
+	deployments, err := c.deploymentsLister.List(labels.Everything())
+	if err != nil || len(deployments) != 2 {
+		t.Errorf("expected the deployments of both namespaces, got %d, %v", len(deployments), err)
+	}
+	if _, err := c.deploymentsLister.Deployments("b").Get("func-test-deployment"); err != nil {
+		t.Errorf("expected the deployment of namespace b, got %v", err)
+	}
+	if _, err := c.crdLister.ServerlessFuncs("b").Get("test"); err != nil {
+		t.Errorf("expected the foo of namespace b, got %v", err)
+	}
+	if _, err := c.crdLister.ServerlessFuncs("c").Get("test"); !apierrors.IsNotFound(err) {
+		t.Errorf("expected not found in namespace c, got %v", err)
+	}
+}
+
+func TestKeepsLegacyDeploymentName(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test-"+strings.Repeat("a", 50), int32Ptr(1))
+	legacy := tools.GetLegacyNames(foo)
+	d := newDeployment(foo, f.config)
+	// created before long names were truncated
+	d.Name = legacy.Deployment
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+
+	f.kubeactions = append(f.kubeactions,
+		core.NewGetAction(schema.GroupVersionResource{Resource: "ingresses"}, foo.Namespace, legacy.Ingress),
+		core.NewGetAction(schema.GroupVersionResource{Resource: "networkpolicies"}, foo.Namespace, legacy.NetworkPolicy))
+	f.expectSyncedResources(foo)
+	expFoo := progressing(foo)
+	expFoo.Status.ObjectNames.Deployment = legacy.Deployment
+	f.expectUpdateFooStatusAction(expFoo)
+	f.run(getKey(foo, t))
+
+	if names := expFoo.Status.ObjectNames; len(names.Service) > 63 || len(names.NetworkPolicy) > 63 {
+		t.Errorf("expected new objects to have names of at most 63 characters, got %+v", names)
+	}
+}
+
+func TestKeepsUnlabelledLegacyDeploymentName(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test-"+strings.Repeat("a", 50), int32Ptr(1))
+	legacy := tools.GetLegacyNames(foo)
+	d := newDeployment(foo, f.config)
+	// created before long names were truncated and before the managed-by
+	// label, so missing from the informer
+	d.Name = legacy.Deployment
+	delete(d.Labels, tools.ManagedByLabel)
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+
+	expDeployment := newDeployment(foo, f.config)
+	expDeployment.Name = legacy.Deployment
+	f.expectGetDeploymentAction(expDeployment)
+	f.kubeactions = append(f.kubeactions,
+		core.NewGetAction(schema.GroupVersionResource{Resource: "ingresses"}, foo.Namespace, legacy.Ingress),
+		core.NewGetAction(schema.GroupVersionResource{Resource: "networkpolicies"}, foo.Namespace, legacy.NetworkPolicy))
+	f.expectCreateDeploymentAction(expDeployment)
+	f.expectGetDeploymentAction(expDeployment)
+	f.expectUpdateDeploymentAction(expDeployment)
+	f.expectSyncedResources(foo)
+	expFoo := progressing(foo)
+	expFoo.Status.ObjectNames.Deployment = legacy.Deployment
+	f.expectUpdateFooStatusAction(expFoo)
+	f.run(getKey(foo, t))
+}
+
+func TestAdoptsOrphanedObjects(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	foo.Annotations = map[string]string{serverlessv1alpha1.AdoptAnnotation: "true"}
+	d := newDeployment(foo, f.config)
+	d.OwnerReferences = nil
+	s := newService(foo, f.config)
+	s.OwnerReferences = nil
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, s, newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+
+	f.expectUpdateDeploymentAction(newDeployment(foo, f.config))
+	f.expectGetServiceAction(s)
+	f.expectUpdateServiceAction(newService(foo, f.config))
+	f.expectGetNetworkPolicyAction(newNetworkPolicy(foo, f.config))
+	f.expectGetFunctionIngressAction(foo)
+	f.expectGetIngressAction(foo.Namespace)
+	f.expectUpdateFooStatusAction(progressing(foo))
+	f.run(getKey(foo, t))
+	f.expectEvents("Normal "+ReasonAdopted, "Normal "+ReasonAdopted, "Normal "+SuccessSynced)
+}
+
+func TestRejectsAdoptionOfOtherPods(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	foo.Annotations = map[string]string{serverlessv1alpha1.AdoptAnnotation: "true"}
+	d := newDeployment(foo, f.config)
+	d.OwnerReferences = nil
+	s := newService(foo, f.config)
+	s.OwnerReferences = nil
+	s.Spec.Selector = map[string]string{"app": "other"}
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, s)
+
+	f.expectUpdateDeploymentAction(newDeployment(foo, f.config))
+	f.expectGetServiceAction(s)
+	failed := foo.DeepCopy()
+	failed.Status.Conditions = []metav1.Condition{{
+		Type:               serverlessv1alpha1.ConditionReconcileError,
+		Status:             metav1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             ErrResourceExists,
+		Message:            fmt.Sprintf(MessageAdoptionRejected, s.Name, "app=other", "serverlessfunc=func-test"),
+	}}
+	f.expectUpdateFooStatusAction(failed)
 	f.runExpectError(getKey(foo, t))
+	f.expectEvents("Normal "+ReasonAdopted, "Warning "+ErrResourceExists)
+}
+
+func TestAddsOrphanFinalizer(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	foo.Spec.DeletionPolicy = serverlessv1alpha1.DeletionPolicyOrphan
+	d := newDeployment(foo, f.config)
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+
+	finalized := foo.DeepCopy()
+	finalized.Finalizers = []string{orphanFinalizer}
+	finalized.Status.ObjectNames = tools.GetObjectNames(foo)
+	f.expectUpdateFooAction(finalized)
+	f.expectSyncedResources(foo)
+	f.expectUpdateFooStatusAction(progressing(finalized))
+	f.run(getKey(foo, t))
+}
+
+func TestReleasesObjectsOnDeletion(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	foo.Spec.DeletionPolicy = serverlessv1alpha1.DeletionPolicyOrphan
+	foo.Finalizers = []string{orphanFinalizer}
+	deleted := metav1.NewTime(now)
+	foo.DeletionTimestamp = &deleted
+	foo.Status.ObjectNames = tools.GetObjectNames(foo)
+	d := newDeployment(foo, f.config)
+	s := newService(foo, f.config)
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, s, newNetworkPolicy(foo, f.config))
+
+	released := d.DeepCopy()
+	released.OwnerReferences = nil
+	f.expectGetDeploymentAction(d)
+	f.expectUpdateDeploymentAction(released)
+	releasedService := s.DeepCopy()
+	releasedService.OwnerReferences = nil
+	f.expectGetServiceAction(s)
+	f.expectUpdateServiceAction(releasedService)
+	finalized := foo.DeepCopy()
+	finalized.Finalizers = nil
+	f.expectUpdateFooAction(finalized)
+	f.run(getKey(foo, t))
+	f.expectEvents("Normal "+ReasonReleased, "Normal "+ReasonReleased)
+}
+
+func TestPausedLeavesObjects(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	foo.Annotations = map[string]string{
+		serverlessv1alpha1.PausedAnnotation:           "true",
+		serverlessv1alpha1.ReconcileRequestAnnotation: "1",
+	}
+	d := newDeployment(foo, f.config)
+	// edited by hand during an incident
+	d.Spec.Replicas = int32Ptr(3)
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d)
+
+	expFoo := foo.DeepCopy()
+	expFoo.Status.ObjectNames = tools.GetObjectNames(foo)
+	expFoo.Status.Ready = "0/1"
+	expFoo.Status.URL = tools.GetFunctionURL(foo)
+	expFoo.Status.Phase = serverlessv1alpha1.RolloutPhaseProgressing
+	expFoo.Status.Reason = reasonRolloutInProgress
+	expFoo.Status.Message = "0 out of 3 new replicas have been updated"
+	expFoo.Status.Conditions = []metav1.Condition{{
+		Type:               serverlessv1alpha1.ConditionPaused,
+		Status:             metav1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             reasonPausedByAnnotation,
+		Message:            messagePaused,
+	}}
+	f.expectUpdateFooStatusAction(expFoo)
+	f.run(getKey(foo, t))
+	f.expectEvents()
+}
+
+func TestReconcileRequestRevertsHandEdits(t *testing.T) {
+	f := newFixture(t)
+	foo := newFoo("test", int32Ptr(1))
+	foo.Annotations = map[string]string{serverlessv1alpha1.ReconcileRequestAnnotation: "1"}
+	// resumed after an incident
+	foo.Status.Conditions = []metav1.Condition{{
+		Type:               serverlessv1alpha1.ConditionPaused,
+		Status:             metav1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             reasonPausedByAnnotation,
+	}}
+	d := newDeployment(foo, f.config)
+	// edited by hand, leaving the template hash alone
+	d.Spec.Template.Spec.Containers[0].Image = "pilot:debug"
+
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+
+	f.expectUpdateDeploymentAction(newDeployment(foo, f.config))
+	f.expectSyncedResources(foo)
+	expFoo := progressing(foo)
+	expFoo.Status.Conditions = []metav1.Condition{}
+	expFoo.Status.LastHandledReconcileRequest = "1"
+	f.expectUpdateFooStatusAction(expFoo)
+	f.run(getKey(foo, t))
+	f.expectEvents("Normal "+ReasonDeploymentUpdated, "Normal "+SuccessSynced)
+
+	// handled requests are not repeated
+	f = newFixture(t)
+	foo = expFoo
+	d = newDeployment(foo, f.config)
+	d.Spec.Template.Spec.Containers[0].Image = "pilot:debug"
+	f.crdLister = append(f.crdLister, foo)
+	f.objects = append(f.objects, foo)
+	f.deploymentLister = append(f.deploymentLister, d)
+	f.kubeobjects = append(f.kubeobjects, d, newService(foo, f.config), newNetworkPolicy(foo, f.config), f.newFooIngress(foo))
+	f.expectSyncedResources(foo)
+	f.expectUpdateFooStatusAction(progressing(foo))
+	f.run(getKey(foo, t))
+	f.expectEvents()
+}
+
+func TestPlan(t *testing.T) {
+	cfg := config.Default()
+	created := newFoo("a", int32Ptr(1))
+	scaled := newFoo("b", int32Ptr(2))
+	d := newDeployment(scaled, cfg)
+	d.Spec.Replicas = int32Ptr(1)
+	ingress, err := updateIngress(newIngress(metav1.NamespaceDefault, cfg), scaled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kubeclient := k8sfake.NewSimpleClientset(d, newService(scaled, cfg), newNetworkPolicy(scaled, cfg), ingress)
+	crdclient := crdfake.NewSimpleClientset(created, scaled)
+
+	plan, err := MakePlan(context.TODO(), kubeclient, crdclient, []string{metav1.NamespaceAll}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Plan{Functions: []FunctionPlan{
+		{Namespace: metav1.NamespaceDefault, Name: "a", Changes: []ObjectChange{
+			{Action: PlanCreate, Kind: "Deployment", Name: "func-a-deployment"},
+			{Action: PlanCreate, Kind: "Service", Name: "func-a-service"},
+			{Action: PlanCreate, Kind: "NetworkPolicy", Name: "func-a-networkpolicy"},
+			{Action: PlanUpdate, Kind: "Ingress", Name: cfg.Ingress.Name, Diff: []tools.DiffResult{
+				{
+					Field: "Metadata.Annotations",
+					Left:  map[string]string{tools.IngressRoutesAnnotation: `{"a":"/serverlessfunc/a(/|$)(.*)","b":"/serverlessfunc/b(/|$)(.*)"}`},
+					Right: map[string]string{tools.IngressRoutesAnnotation: `{"b":"/serverlessfunc/b(/|$)(.*)"}`},
+				},
+				{Field: "Spec.Rules.Http.Paths[/serverlessfunc/a(/|$)(.*)]", Left: "func-a-service:80"},
+			}},
+		}},
+		{Namespace: metav1.NamespaceDefault, Name: "b", Changes: []ObjectChange{
+			{Action: PlanUpdate, Kind: "Deployment", Name: "func-b-deployment", Diff: []tools.DiffResult{
+				{Field: "Spec.Replicas", Left: int32Ptr(2), Right: int32Ptr(1)},
+			}},
+		}},
+	}}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Errorf("unexpected plan\nDiff:\n %s", diff.ObjectGoPrintSideBySide(expected, plan))
+	}
+	if !plan.HasChanges() {
+		t.Error("expected the plan to have changes")
+	}
+
+	// the cluster is only read
+	for _, action := range kubeclient.Actions() {
+		if verb := action.GetVerb(); verb != "list" {
+			t.Errorf("expected the cluster to only be listed, got %s %s", verb, action.GetResource().Resource)
+		}
+	}
+
+	var out strings.Builder
+	if err := plan.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "      Spec.Replicas: 1 -> 2\n") || !strings.HasSuffix(out.String(), "Plan: 3 to create, 2 to update, 0 to delete, 0 failing.\n") {
+		t.Errorf("unexpected text plan:\n%s", out.String())
+	}
 }
 
 func int32Ptr(i int32) *int32 { return &i }
@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/tools"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// The reasons of the events the controller records. Normal events report a
+// change the controller made to the cluster, Warning events a problem that
+// retrying does not fix. A sync that finds every object as desired records
+// nothing, so that resyncs do not flood the events of a ServerlessFunc.
+const (
+	// SuccessSynced is recorded on a ServerlessFunc once a sync that
+	// changed any of its objects succeeds
+	SuccessSynced = "Synced"
+	// ReasonDeploymentCreated is recorded on a ServerlessFunc when its
+	// Deployment is created
+	ReasonDeploymentCreated = "DeploymentCreated"
+	// ReasonDeploymentUpdated is recorded on a ServerlessFunc when its
+	// Deployment is updated, with the fields that changed
+	ReasonDeploymentUpdated = "DeploymentUpdated"
+	// ReasonScaledToZero is recorded on a ServerlessFunc when its Deployment
+	// is scaled down to no replicas
+	ReasonScaledToZero = "ScaledToZero"
+	// ReasonServiceCreated is recorded on a ServerlessFunc when its Service
+	// is created
+	ReasonServiceCreated = "ServiceCreated"
+	// ReasonServiceUpdated is recorded on a ServerlessFunc when changes to
+	// the ports or selector of its Service are reverted
+	ReasonServiceUpdated = "ServiceUpdated"
+	// ReasonNetworkPolicyCreated is recorded on a ServerlessFunc when its
+	// NetworkPolicy is created
+	ReasonNetworkPolicyCreated = "NetworkPolicyCreated"
+	// ReasonNetworkPolicyUpdated is recorded on a ServerlessFunc when its
+	// NetworkPolicy is updated
+	ReasonNetworkPolicyUpdated = "NetworkPolicyUpdated"
+	// ReasonIngressCreated is recorded on a ServerlessFunc when its own
+	// ingress is created under the nginx profile
+	ReasonIngressCreated = "IngressCreated"
+	// ReasonIngressUpdated is recorded on a ServerlessFunc when its own
+	// ingress is updated
+	ReasonIngressUpdated = "IngressUpdated"
+	// ReasonIngressDeleted is recorded on a ServerlessFunc when its own
+	// ingress is deleted, after moving to the shared profile
+	ReasonIngressDeleted = "IngressDeleted"
+	// ReasonRouteAdded is recorded on a ServerlessFunc when its path is
+	// added to the shared ingress
+	ReasonRouteAdded = "RouteAdded"
+	// ReasonRouteRemoved is recorded on the shared ingress when the path of
+	// a deleted ServerlessFunc is removed from it
+	ReasonRouteRemoved = "RouteRemoved"
+	// ReasonAdopted is recorded on a ServerlessFunc with the adopt
+	// annotation when it takes ownership of an existing Deployment or
+	// Service
+	ReasonAdopted = "Adopted"
+	// ReasonReleased is recorded on a ServerlessFunc deleted with the Orphan
+	// deletion policy when the owner reference to it is removed from its
+	// Deployment or Service
+	ReasonReleased = "Released"
+	// ReasonRolloutCompleted is recorded on a ServerlessFunc when every
+	// replica of its Deployment is updated and available
+	ReasonRolloutCompleted = "RolloutCompleted"
+	// ReasonRolloutFailed is a Warning recorded on a ServerlessFunc when the
+	// rollout of its Deployment stalls, with the reason of the Deployment
+	ReasonRolloutFailed = "RolloutFailed"
+
+	// ErrResourceExists is used as part of the Event 'reason' when a
+	// ServerlessFunc fails to sync due to a Deployment of the same name
+	// already existing.
+	ErrResourceExists = "ErrResourceExists"
+	// ErrIngressPathConflict is used as part of the Event 'reason' when the
+	// ingress path of a ServerlessFunc is already taken by a path the
+	// controller does not manage for it.
+	ErrIngressPathConflict = "ErrIngressPathConflict"
+	// ErrProtocolUnsupported is used as part of the Event 'reason' when the
+	// protocol of a ServerlessFunc cannot be proxied by the shared ingress.
+	ErrProtocolUnsupported = "ErrProtocolUnsupported"
+)
+
+const (
+	// MessageResourceExists is the message used for Events when a resource
+	// fails to sync due to a Deployment already existing
+	MessageResourceExists = "Resource %q already exists and is not managed by ServerlessFunc"
+	// MessageAdoptionRejected is the message used for Events when an
+	// existing resource cannot be adopted since its selector differs
+	MessageAdoptionRejected = "Resource %q cannot be adopted, it selects %q instead of %q"
+	// MessageResourceSynced is the message used for an Event fired when a
+	// ServerlessFunc is synced successfully
+	MessageResourceSynced = "ServerlessFunc synced successfully"
+	// MessageIngressPathConflict is the message used for Events when the
+	// ingress path of a ServerlessFunc conflicts with an existing path
+	MessageIngressPathConflict = "Ingress path %q in %q conflicts with %s"
+	// MessageProtocolUnsupported is the message used for Events when the
+	// protocol of a ServerlessFunc needs another ingress profile
+	MessageProtocolUnsupported = "Protocol %s is routed as HTTP/1.1 by the shared ingress, it needs the %s ingress profile"
+)
+
+// eventCorrelatorOptions aggregates the similar events of an object, which
+// only differ by their message, into one counted event once there are 5 of
+// them within 10 minutes, e.g. the Warning recorded by every retry of a
+// failing ServerlessFunc. The events of each object are also rate limited.
+var eventCorrelatorOptions = record.CorrelatorOptions{
+	MaxEvents:            5,
+	MaxIntervalInSeconds: 600,
+	BurstSize:            25,
+	QPS:                  1. / 60,
+}
+
+// maxDiffValueLength is the length above which the values of a changed field
+// are left out of the message of an event.
+const maxDiffValueLength = 40
+
+// changesKey is the context key of the changes counted by recordChange.
+type changesKey struct{}
+
+// withChanges returns a context counting the changes recorded within it.
+func withChanges(ctx context.Context) (context.Context, *int) {
+	changes := new(int)
+	return context.WithValue(ctx, changesKey{}, changes), changes
+}
+
+// recordChange records a Normal event of a change made to the objects of
+// foo, and counts it in ctx.
+func (c *Controller) recordChange(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc, reason, messageFmt string, args ...interface{}) {
+	if changes, ok := ctx.Value(changesKey{}).(*int); ok {
+		*changes++
+	}
+	c.recorder.Eventf(foo, corev1.EventTypeNormal, reason, messageFmt, args...)
+}
+
+// recordWarning sets the condition of a problem of foo that retrying does not
+// fix, which is written along with its status. The Warning event is only
+// recorded when the condition changes, so that resyncs do not repeat it.
+func (c *Controller) recordWarning(foo *serverlessv1alpha1.ServerlessFunc, conditionType, reason, message string) {
+	current := meta.FindStatusCondition(foo.Status.Conditions, conditionType)
+	if current == nil || current.Status != metav1.ConditionTrue || current.Reason != reason || current.Message != message {
+		c.recorder.Event(foo, corev1.EventTypeWarning, reason, message)
+	}
+	meta.SetStatusCondition(&foo.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: foo.Generation,
+		LastTransitionTime: metav1.NewTime(c.clock.Now()),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// clearWarning removes the condition set by recordWarning once the problem
+// is gone. RemoveStatusCondition panics when the condition is missing from an
+// empty list.
+func clearWarning(foo *serverlessv1alpha1.ServerlessFunc, conditionType string) {
+	if meta.FindStatusCondition(foo.Status.Conditions, conditionType) != nil {
+		meta.RemoveStatusCondition(&foo.Status.Conditions, conditionType)
+	}
+}
+
+// summarizeDiff lists the fields of diff, with their desired and current
+// values when these are short.
+func summarizeDiff(diff []tools.DiffResult) string {
+	fields := make([]string, 0, len(diff))
+	for _, item := range diff {
+		desired, current := fmt.Sprint(item.Left), fmt.Sprint(item.Right)
+		if len(desired) > maxDiffValueLength || len(current) > maxDiffValueLength {
+			fields = append(fields, item.Field)
+			continue
+		}
+		fields = append(fields, fmt.Sprintf("%s (%s -> %s)", item.Field, current, desired))
+	}
+	return strings.Join(fields, ", ")
+}
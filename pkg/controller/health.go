@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// stuckThreshold is how long a worker may spend on a single ServerlessFunc
+// before the controller is reported as unhealthy.
+const stuckThreshold = 5 * time.Minute
+
+// reconcileResult is the outcome of the last sync of a ServerlessFunc.
+type reconcileResult struct {
+	Time     time.Time `json:"time"`
+	Duration string    `json:"duration"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// informerState is the state of an informer as reported by the debug
+// endpoint.
+type informerState struct {
+	Synced          bool   `json:"synced"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// debugState is the body of the debug endpoint.
+type debugState struct {
+	Leader    bool                       `json:"leader"`
+	Informers map[string]informerState   `json:"informers"`
+	Queue     []queueItem                `json:"queue"`
+	Results   map[string]reconcileResult `json:"results"`
+}
+
+// InstallHandlers registers the health, readiness and debug endpoints on mux.
+func (c *Controller) InstallHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", c.serveHealthz)
+	mux.HandleFunc("/readyz", c.serveReadyz)
+	mux.HandleFunc("/debug", c.serveDebug)
+}
+
+func (c *Controller) setLeading(leading bool) {
+	var v int32
+	if leading {
+		v = 1
+	}
+	atomic.StoreInt32(&c.leading, v)
+}
+
+// Leading reports whether this replica runs the workers.
+func (c *Controller) Leading() bool {
+	return atomic.LoadInt32(&c.leading) == 1
+}
+
+// recordResult remembers the outcome of the sync of key.
+func (c *Controller) recordResult(key string, start time.Time, err error) {
+	result := reconcileResult{
+		Time:     start,
+		Duration: time.Since(start).String(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	c.resultsLock.Lock()
+	defer c.resultsLock.Unlock()
+	c.results[key] = result
+}
+
+// forgetResult drops the result of a ServerlessFunc that no longer exists.
+func (c *Controller) forgetResult(key string) {
+	c.resultsLock.Lock()
+	defer c.resultsLock.Unlock()
+	delete(c.results, key)
+}
+
+// serveHealthz fails when a worker is stuck on a ServerlessFunc, in which
+// case restarting the controller is the only way out. The informers are left
+// to /readyz, since a reflector that lost its watch keeps retrying on its own.
+func (c *Controller) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	var problems []string
+	if item, since, ok := c.workqueue.OldestProcessing(); ok && time.Since(since) > stuckThreshold {
+		problems = append(problems, fmt.Sprintf("worker stuck on %v for %s", item, time.Since(since).Round(time.Second)))
+	}
+	writeCheck(w, problems, "ok")
+}
+
+// serveReadyz fails until the informer caches are synced. Standbys are ready
+// too, so that they are kept while waiting to take over.
+func (c *Controller) serveReadyz(w http.ResponseWriter, r *http.Request) {
+	var problems []string
+	for _, informer := range c.informerStates() {
+		if !informer.synced() {
+			problems = append(problems, fmt.Sprintf("informer %s is not synced", informer.name))
+		}
+	}
+	state := "standby"
+	if c.Leading() {
+		state = "leader"
+	}
+	writeCheck(w, problems, "ok: "+state)
+}
+
+// serveDebug dumps the workqueue, the last result of every ServerlessFunc and
+// the state of the informers as JSON.
+func (c *Controller) serveDebug(w http.ResponseWriter, r *http.Request) {
+	state := debugState{
+		Leader:    c.Leading(),
+		Informers: map[string]informerState{},
+		Queue:     c.workqueue.Items(),
+		Results:   map[string]reconcileResult{},
+	}
+	for _, informer := range c.informerStates() {
+		state.Informers[informer.name] = informerState{
+			Synced:          informer.synced(),
+			ResourceVersion: informer.version(),
+		}
+	}
+	c.resultsLock.Lock()
+	for key, result := range c.results {
+		state.Results[key] = result
+	}
+	c.resultsLock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(state)
+}
+
+type namedInformer struct {
+	name    string
+	synced  cache.InformerSynced
+	version func() string
+}
+
+func (c *Controller) informerStates() []namedInformer {
+	informers := []namedInformer{
+		{"deployments", c.deploymentsSynced, c.deploymentsVersion},
+		{"serverlessfuncs", c.crdSynced, c.crdVersion},
+	}
+	if c.namespacesSynced != nil {
+		informers = append(informers, namedInformer{"namespaces", c.namespacesSynced, c.namespacesVersion})
+	}
+	return informers
+}
+
+func writeCheck(w http.ResponseWriter, problems []string, ok string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if len(problems) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, strings.Join(problems, "\n"))
+		return
+	}
+	fmt.Fprintln(w, ok)
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/config"
+)
+
+// corsByIngress reports whether the ingress answers the CORS requests of
+// every function. The pilot must then leave the headers alone, or they would
+// be sent twice.
+func corsByIngress(cfg *config.ControllerConfig) bool {
+	return cfg.Ingress.Profile == config.IngressProfileNginx
+}
+
+// applyHTTP configures the pilot container of template to serve requests as
+// described by the http block of foo.
+func applyHTTP(foo *serverlessv1alpha1.ServerlessFunc, cfg *config.ControllerConfig, template *corev1.PodTemplateSpec) {
+	spec := foo.Spec.HTTP
+	if spec == nil {
+		return
+	}
+	pilot := &template.Spec.Containers[0]
+	if cors := spec.CORS; cors != nil && !corsByIngress(cfg) {
+		origins, methods, headers := corsLists(cors)
+		pilot.Env = append(pilot.Env,
+			corev1.EnvVar{Name: "SERVERLESS_HTTP_CORS_ALLOW_ORIGINS", Value: strings.Join(origins, ",")},
+			corev1.EnvVar{Name: "SERVERLESS_HTTP_CORS_ALLOW_METHODS", Value: strings.Join(methods, ",")},
+			corev1.EnvVar{Name: "SERVERLESS_HTTP_CORS_ALLOW_HEADERS", Value: strings.Join(headers, ",")},
+			corev1.EnvVar{Name: "SERVERLESS_HTTP_CORS_ALLOW_CREDENTIALS", Value: fmt.Sprint(cors.AllowCredentials)},
+		)
+	}
+	if spec.RequestTimeout != nil {
+		pilot.Env = append(pilot.Env, corev1.EnvVar{Name: "SERVERLESS_HTTP_REQUEST_TIMEOUT", Value: spec.RequestTimeout.Duration.String()})
+	}
+	if spec.IdleTimeout != nil {
+		pilot.Env = append(pilot.Env, corev1.EnvVar{Name: "SERVERLESS_HTTP_IDLE_TIMEOUT", Value: spec.IdleTimeout.Duration.String()})
+	}
+	if spec.MaxRequestSize != nil {
+		pilot.Env = append(pilot.Env, corev1.EnvVar{Name: "SERVERLESS_HTTP_MAX_REQUEST_SIZE", Value: fmt.Sprint(spec.MaxRequestSize.Value())})
+	}
+}
+
+// httpIngressAnnotations returns the ingress-nginx annotations mirroring the
+// http block of foo. ingress-nginx has no per ingress idle timeout, which is
+// left to the pilot.
+func httpIngressAnnotations(foo *serverlessv1alpha1.ServerlessFunc) map[string]string {
+	spec := foo.Spec.HTTP
+	if spec == nil {
+		return nil
+	}
+	annotations := map[string]string{}
+	if cors := spec.CORS; cors != nil {
+		annotations["nginx.ingress.kubernetes.io/enable-cors"] = "true"
+		annotations["nginx.ingress.kubernetes.io/cors-allow-credentials"] = fmt.Sprint(cors.AllowCredentials)
+		origins, methods, headers := corsLists(cors)
+		if len(origins) > 0 {
+			annotations["nginx.ingress.kubernetes.io/cors-allow-origin"] = strings.Join(origins, ", ")
+		}
+		if len(methods) > 0 {
+			annotations["nginx.ingress.kubernetes.io/cors-allow-methods"] = strings.Join(methods, ", ")
+		}
+		if len(headers) > 0 {
+			annotations["nginx.ingress.kubernetes.io/cors-allow-headers"] = strings.Join(headers, ", ")
+		}
+	}
+	if spec.RequestTimeout != nil {
+		// ingress-nginx expects whole seconds
+		seconds := strconv.FormatInt(int64(math.Ceil(spec.RequestTimeout.Duration.Seconds())), 10)
+		annotations["nginx.ingress.kubernetes.io/proxy-read-timeout"] = seconds
+		annotations["nginx.ingress.kubernetes.io/proxy-send-timeout"] = seconds
+	}
+	if spec.MaxRequestSize != nil {
+		annotations["nginx.ingress.kubernetes.io/proxy-body-size"] = fmt.Sprint(spec.MaxRequestSize.Value())
+	}
+	return annotations
+}
+
+// corsLists returns the allowed origins, methods and headers of cors as
+// strings.
+func corsLists(cors *serverlessv1alpha1.CORSSpec) (origins, methods, headers []string) {
+	for _, origin := range cors.AllowOrigins {
+		origins = append(origins, string(origin))
+	}
+	for _, method := range cors.AllowMethods {
+		methods = append(methods, string(method))
+	}
+	for _, header := range cors.AllowHeaders {
+		headers = append(headers, string(header))
+	}
+	return origins, methods, headers
+}
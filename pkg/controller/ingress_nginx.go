@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/config"
+	"github.com/peizhong/serverless-controller/pkg/logging"
+	"github.com/peizhong/serverless-controller/pkg/tools"
+)
+
+// newFunctionIngress creates the ingress of a ServerlessFunc for the nginx
+// profile. The path is the same as in the shared ingress, with the function
+// prefix stripped by ingress-nginx.
+func newFunctionIngress(foo *serverlessv1alpha1.ServerlessFunc, cfg *config.ControllerConfig) *networkingv1.Ingress {
+	annotations := map[string]string{
+		"nginx.ingress.kubernetes.io/use-regex":      "true",
+		"nginx.ingress.kubernetes.io/rewrite-target": "/$2",
+	}
+	for k, v := range authIngressAnnotations(foo, cfg) {
+		annotations[k] = v
+	}
+	// the http block goes last, as its timeouts override the protocol ones
+	for k, v := range protocolIngressAnnotations(foo) {
+		annotations[k] = v
+	}
+	for k, v := range httpIngressAnnotations(foo) {
+		annotations[k] = v
+	}
+	pathTypeImplementationSpecific := networkingv1.PathTypeImplementationSpecific
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      tools.GetFunctionIngressName(foo),
+			Namespace: foo.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(foo, serverlessv1alpha1.SchemeGroupVersion.WithKind("ServerlessFunc")),
+			},
+			Labels: map[string]string{
+				"serverlessfunc":     tools.GetAppName(foo),
+				tools.ManagedByLabel: tools.ManagedBy,
+			},
+			Annotations: annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				{
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     tools.GetIngressPath(foo),
+									PathType: &pathTypeImplementationSpecific,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: tools.GetServiceName(foo),
+											Port: networkingv1.ServiceBackendPort{
+												Number: 80,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// syncFunctionIngress creates or updates the ingress of foo, and removes
+// its path from the shared ingress.
+func (c *Controller) syncFunctionIngress(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc, cfg *config.ControllerConfig) error {
+	logger := logging.FromContext(ctx)
+	desired := newFunctionIngress(foo, cfg)
+	ingress, err := c.kubeclientset.NetworkingV1().Ingresses(foo.Namespace).Get(ctx, desired.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		logger.Info("Creating ingress", "ingress", desired.Name)
+		_, err = c.kubeclientset.NetworkingV1().Ingresses(foo.Namespace).Create(ctx, desired, metav1.CreateOptions{})
+		if err == nil {
+			c.recordChange(ctx, foo, ReasonIngressCreated, "Created ingress %q", desired.Name)
+		}
+	} else if err == nil {
+		if !metav1.IsControlledBy(ingress, foo) {
+			return permanentError(ErrResourceExists, fmt.Errorf(MessageResourceExists, ingress.Name))
+		}
+		if diff := tools.DiffIngress(desired, ingress); len(diff) > 0 {
+			logDiff(logger, "Ingress", diff)
+			logger.Info("Updating ingress", "ingress", desired.Name)
+			desired.ResourceVersion = ingress.ResourceVersion
+			_, err = c.kubeclientset.NetworkingV1().Ingresses(foo.Namespace).Update(ctx, desired, metav1.UpdateOptions{})
+			if err == nil {
+				c.recordChange(ctx, foo, ReasonIngressUpdated, "Updated ingress %q: %s", desired.Name, summarizeDiff(diff))
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return c.cleanupIngress(ctx, foo.Namespace, cfg)
+}
+
+// deleteFunctionIngress deletes the ingress foo had under the nginx profile.
+func (c *Controller) deleteFunctionIngress(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc) error {
+	ingress, err := c.kubeclientset.NetworkingV1().Ingresses(foo.Namespace).Get(ctx, tools.GetFunctionIngressName(foo), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(ingress, foo) {
+		return nil
+	}
+	logging.FromContext(ctx).Info("Deleting ingress", "ingress", ingress.Name)
+	if err := c.kubeclientset.NetworkingV1().Ingresses(foo.Namespace).Delete(ctx, ingress.Name, metav1.DeleteOptions{}); err != nil {
+		return err
+	}
+	c.recordChange(ctx, foo, ReasonIngressDeleted, "Deleted ingress %q", ingress.Name)
+	return nil
+}
@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+)
+
+// serviceAccountNamespaceFile holds the namespace of the Pod when running in
+// a cluster.
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// LeaderElection configures how the replicas of the controller elect the one
+// running the workers. Only the leader writes to the cluster, the others are
+// standbys keeping their informer caches warm.
+type LeaderElection struct {
+	Enabled        bool
+	LeaseName      string
+	LeaseNamespace string
+	// Identity tells the replicas apart, the hostname by default
+	Identity      string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// DefaultLeaderElection returns the leader election settings, with the lease
+// in the namespace of the Pod when running in a cluster.
+func DefaultLeaderElection() LeaderElection {
+	namespace := metav1.NamespaceDefault
+	if data, err := ioutil.ReadFile(serviceAccountNamespaceFile); err == nil {
+		namespace = strings.TrimSpace(string(data))
+	}
+	return LeaderElection{
+		LeaseName:      controllerAgentName,
+		LeaseNamespace: namespace,
+		LeaseDuration:  15 * time.Second,
+		RenewDeadline:  10 * time.Second,
+		RetryPeriod:    2 * time.Second,
+	}
+}
+
+// RunWithLeaderElection runs the workers while this replica holds the lease.
+// It returns once ctx is done, after the workers have stopped and the lease
+// is released so that a standby takes over right away. It returns an error
+// when the settings are invalid, or when the lease is lost, in which case the
+// process is expected to exit.
+func (c *Controller) RunWithLeaderElection(ctx context.Context, le LeaderElection, threadiness int) error {
+	if !le.Enabled {
+		c.setLeading(true)
+		return c.Run(threadiness, ctx.Done())
+	}
+	identity := le.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("get hostname for leader election: %v", err)
+		}
+		// the pid keeps replicas sharing a hostname apart
+		identity = fmt.Sprintf("%s_%d", hostname, os.Getpid())
+	}
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      le.LeaseName,
+			Namespace: le.LeaseNamespace,
+		},
+		Client: c.kubeclientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+
+	// The lease is released when the election is cancelled, which only
+	// happens after ctx is done and the workers have stopped, so that a
+	// standby does not take over while a sync is still writing.
+	electionCtx, cancelElection := context.WithCancel(context.Background())
+	defer cancelElection()
+	var mu sync.Mutex
+	var workers sync.WaitGroup
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-electionCtx.Done():
+			return
+		}
+		// the workers are not started anymore once ctx is done
+		mu.Lock()
+		mu.Unlock()
+		workers.Wait()
+		cancelElection()
+	}()
+
+	// the workers run in a goroutine of the leader election, which is waited
+	// for so that they are stopped before returning
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	lease := klog.KRef(le.LeaseNamespace, le.LeaseName)
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   le.LeaseDuration,
+		RenewDeadline:   le.RenewDeadline,
+		RetryPeriod:     le.RetryPeriod,
+		Name:            le.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				mu.Lock()
+				if ctx.Err() != nil {
+					mu.Unlock()
+					return
+				}
+				workers.Add(1)
+				mu.Unlock()
+				defer workers.Done()
+
+				klog.InfoS("Acquired lease", "lease", lease)
+				c.setLeading(true)
+				close(started)
+				// the workers stop when ctx is done or the lease is lost
+				stopCh := make(chan struct{})
+				go func() {
+					defer close(stopCh)
+					select {
+					case <-ctx.Done():
+					case <-leaderCtx.Done():
+					}
+				}()
+				done <- c.Run(threadiness, stopCh)
+			},
+			OnStoppedLeading: func() {
+				c.setLeading(false)
+				klog.InfoS("Stopped leading", "lease", lease)
+			},
+			OnNewLeader: func(current string) {
+				if current != identity {
+					klog.InfoS("Standby", "leader", current)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("leader election: %v", err)
+	}
+	klog.InfoS("Waiting to acquire lease", "lease", lease, "identity", identity)
+	elector.Run(electionCtx)
+	select {
+	case <-started:
+		if err := <-done; err != nil {
+			return err
+		}
+	default:
+	}
+	if ctx.Err() == nil {
+		return fmt.Errorf("lost lease %s/%s", le.LeaseNamespace, le.LeaseName)
+	}
+	return nil
+}
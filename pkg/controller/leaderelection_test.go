@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestLeaderElectionSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(le *LeaderElection)
+		expected string
+	}{
+		{
+			name:     "lease duration not above renew deadline",
+			modify:   func(le *LeaderElection) { le.LeaseDuration = le.RenewDeadline },
+			expected: "leaseDuration must be greater than renewDeadline",
+		},
+		{
+			name:     "renew deadline not above retry period",
+			modify:   func(le *LeaderElection) { le.RenewDeadline = le.RetryPeriod },
+			expected: "renewDeadline must be greater than retryPeriod*JitterFactor",
+		},
+		{
+			name:     "retry period missing",
+			modify:   func(le *LeaderElection) { le.RetryPeriod = 0 },
+			expected: "retryPeriod must be greater than zero",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, _, _ := newFixture(t).newController()
+			le := DefaultLeaderElection()
+			le.Enabled = true
+			le.Identity = "test"
+			test.modify(&le)
+
+			err := c.RunWithLeaderElection(context.Background(), le, 1)
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected error %q, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestLeaderElectionReleasesLease(t *testing.T) {
+	f := newFixture(t)
+	c, _, _ := f.newController()
+	le := DefaultLeaderElection()
+	le.Enabled = true
+	le.Identity = "test"
+	le.LeaseDuration = 2 * time.Second
+	le.RenewDeadline = time.Second
+	le.RetryPeriod = 100 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- c.RunWithLeaderElection(ctx, le, 1) }()
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return c.Leading(), nil
+	}); err != nil {
+		t.Fatalf("expected to acquire the lease: %v", err)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected to return once ctx is done")
+	}
+	lease, err := f.kubeclient.CoordinationV1().Leases(le.LeaseNamespace).Get(context.Background(), le.LeaseName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if holder := lease.Spec.HolderIdentity; holder != nil && *holder != "" {
+		t.Errorf("expected the lease to be released, held by %q", *holder)
+	}
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/logging"
+	"github.com/peizhong/serverless-controller/pkg/tools"
+)
+
+// objectNames returns the names of the objects of foo, which are recorded in
+// its status. A function synced before its names were recorded keeps the
+// objects it has, whose legacy names are longer than the generated ones when
+// the name of the function is long.
+func (c *Controller) objectNames(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc) (*serverlessv1alpha1.ObjectNames, error) {
+	names := tools.GetObjectNames(foo)
+	if foo.Status.ObjectNames != nil {
+		return names, nil
+	}
+	legacy := tools.GetLegacyNames(foo)
+	logger := logging.FromContext(ctx)
+
+	keep := func(kind string, name *string, legacy string, get func(name string) (metav1.Object, error)) error {
+		if *name == legacy {
+			return nil
+		}
+		object, err := get(legacy)
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// an object of another owner is a conflict for the sync to report
+		if metav1.IsControlledBy(object, foo) {
+			logger.Info("Keeping legacy name", "kind", kind, "name", legacy)
+			*name = legacy
+		}
+		return nil
+	}
+	if err := keep("Deployment", &names.Deployment, legacy.Deployment, func(name string) (metav1.Object, error) {
+		deployment, err := c.deploymentsLister.Deployments(foo.Namespace).Get(name)
+		// the informer only holds labelled deployments, a legacy one may
+		// predate the label
+		if errors.IsNotFound(err) {
+			return c.kubeclientset.AppsV1().Deployments(foo.Namespace).Get(ctx, name, metav1.GetOptions{})
+		}
+		return deployment, err
+	}); err != nil {
+		return nil, err
+	}
+	if err := keep("Ingress", &names.Ingress, legacy.Ingress, func(name string) (metav1.Object, error) {
+		return c.kubeclientset.NetworkingV1().Ingresses(foo.Namespace).Get(ctx, name, metav1.GetOptions{})
+	}); err != nil {
+		return nil, err
+	}
+	if err := keep("NetworkPolicy", &names.NetworkPolicy, legacy.NetworkPolicy, func(name string) (metav1.Object, error) {
+		return c.kubeclientset.NetworkingV1().NetworkPolicies(foo.Namespace).Get(ctx, name, metav1.GetOptions{})
+	}); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
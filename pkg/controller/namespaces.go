@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	listers "github.com/peizhong/serverless-controller/pkg/generated/listers/serverlesscontroller/v1alpha1"
+)
+
+// The informers of a namespace only hold the objects of that namespace, so
+// the listers of several namespaces are read one after the other until the
+// object is found.
+
+// multiDeploymentLister lists the Deployments of several informers.
+type multiDeploymentLister []appslisters.DeploymentLister
+
+func (l multiDeploymentLister) List(selector labels.Selector) ([]*appsv1.Deployment, error) {
+	var result []*appsv1.Deployment
+	for _, lister := range l {
+		items, err := lister.List(selector)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+	}
+	return result, nil
+}
+
+func (l multiDeploymentLister) Deployments(namespace string) appslisters.DeploymentNamespaceLister {
+	namespaced := make(multiDeploymentNamespaceLister, 0, len(l))
+	for _, lister := range l {
+		namespaced = append(namespaced, lister.Deployments(namespace))
+	}
+	return namespaced
+}
+
+type multiDeploymentNamespaceLister []appslisters.DeploymentNamespaceLister
+
+func (l multiDeploymentNamespaceLister) List(selector labels.Selector) ([]*appsv1.Deployment, error) {
+	var result []*appsv1.Deployment
+	for _, lister := range l {
+		items, err := lister.List(selector)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+	}
+	return result, nil
+}
+
+func (l multiDeploymentNamespaceLister) Get(name string) (*appsv1.Deployment, error) {
+	for _, lister := range l {
+		deployment, err := lister.Get(name)
+		if !errors.IsNotFound(err) {
+			return deployment, err
+		}
+	}
+	return nil, errors.NewNotFound(appsv1.Resource("deployment"), name)
+}
+
+// multiServerlessFuncLister lists the ServerlessFuncs of several informers.
+type multiServerlessFuncLister []listers.ServerlessFuncLister
+
+func (l multiServerlessFuncLister) List(selector labels.Selector) ([]*serverlessv1alpha1.ServerlessFunc, error) {
+	var result []*serverlessv1alpha1.ServerlessFunc
+	for _, lister := range l {
+		items, err := lister.List(selector)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+	}
+	return result, nil
+}
+
+func (l multiServerlessFuncLister) ServerlessFuncs(namespace string) listers.ServerlessFuncNamespaceLister {
+	namespaced := make(multiServerlessFuncNamespaceLister, 0, len(l))
+	for _, lister := range l {
+		namespaced = append(namespaced, lister.ServerlessFuncs(namespace))
+	}
+	return namespaced
+}
+
+type multiServerlessFuncNamespaceLister []listers.ServerlessFuncNamespaceLister
+
+func (l multiServerlessFuncNamespaceLister) List(selector labels.Selector) ([]*serverlessv1alpha1.ServerlessFunc, error) {
+	var result []*serverlessv1alpha1.ServerlessFunc
+	for _, lister := range l {
+		items, err := lister.List(selector)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+	}
+	return result, nil
+}
+
+func (l multiServerlessFuncNamespaceLister) Get(name string) (*serverlessv1alpha1.ServerlessFunc, error) {
+	for _, lister := range l {
+		foo, err := lister.Get(name)
+		if !errors.IsNotFound(err) {
+			return foo, err
+		}
+	}
+	return nil, errors.NewNotFound(serverlessv1alpha1.Resource("serverlessfunc"), name)
+}
+
+// allSynced returns an InformerSynced reporting whether every informer is
+// synced.
+func allSynced(informers ...cache.SharedIndexInformer) cache.InformerSynced {
+	return func() bool {
+		for _, informer := range informers {
+			if !informer.HasSynced() {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// lastSyncResourceVersion returns the resource versions of the informers,
+// separated by commas.
+func lastSyncResourceVersion(informers ...cache.SharedIndexInformer) func() string {
+	return func() string {
+		versions := make([]string, 0, len(informers))
+		for _, informer := range informers {
+			versions = append(versions, informer.LastSyncResourceVersion())
+		}
+		return strings.Join(versions, ",")
+	}
+}
+
+// SelectNamespaces limits the controller to the ServerlessFuncs of the
+// namespaces held by informer, which is expected to be filtered with a label
+// selector. ServerlessFuncs are synced as soon as their namespace gets
+// selected. Objects created for ServerlessFuncs of a namespace that is no
+// longer selected are left alone. Only the reconciles are filtered, the
+// informers of the controller keep watching the ServerlessFuncs and
+// Deployments of every namespace.
+func (c *Controller) SelectNamespaces(informer coreinformers.NamespaceInformer) {
+	c.namespaceLister = informer.Lister()
+	c.namespacesSynced = informer.Informer().HasSynced
+	c.namespacesVersion = informer.Informer().LastSyncResourceVersion
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			namespace, ok := obj.(*corev1.Namespace)
+			if !ok {
+				return
+			}
+			klog.InfoS("Namespace selected", "namespace", namespace.Name)
+			c.enqueueNamespace(namespace.Name)
+		},
+	})
+}
+
+// namespaceSelected reports whether the ServerlessFuncs of namespace are
+// managed by the controller.
+func (c *Controller) namespaceSelected(namespace string) bool {
+	if c.namespaceLister == nil {
+		return true
+	}
+	_, err := c.namespaceLister.Get(namespace)
+	return err == nil
+}
+
+// enqueueNamespace enqueues every ServerlessFunc of namespace.
+func (c *Controller) enqueueNamespace(namespace string) {
+	foos, err := c.crdLister.ServerlessFuncs(namespace).List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "Failed to list foos of namespace", "namespace", namespace)
+		return
+	}
+	for _, foo := range foos {
+		c.enqueueCrd(foo)
+	}
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/config"
+	"github.com/peizhong/serverless-controller/pkg/logging"
+	"github.com/peizhong/serverless-controller/pkg/metrics"
+	"github.com/peizhong/serverless-controller/pkg/tools"
+)
+
+// namespaceNameLabel is set on every namespace by the API server.
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// functionPeer selects the pods of the function named name.
+func functionPeer(name string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{
+		PodSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"serverlessfunc": tools.GetAppName(&serverlessv1alpha1.ServerlessFunc{ObjectMeta: metav1.ObjectMeta{Name: name}}),
+			},
+		},
+	}
+}
+
+func networkPolicyPort(protocol corev1.Protocol, port int32) networkingv1.NetworkPolicyPort {
+	p := intstr.FromInt(int(port))
+	return networkingv1.NetworkPolicyPort{
+		Protocol: &protocol,
+		Port:     &p,
+	}
+}
+
+// newNetworkPolicy creates the NetworkPolicy isolating a ServerlessFunc: only
+// the ingress controller and the functions listed in network.allowFrom may
+// reach the pilot, and outgoing traffic is limited when network.egress is
+// set.
+func newNetworkPolicy(foo *serverlessv1alpha1.ServerlessFunc, cfg *config.ControllerConfig) *networkingv1.NetworkPolicy {
+	labels := map[string]string{
+		"serverlessfunc": tools.GetAppName(foo),
+	}
+	pilotPorts := []networkingv1.NetworkPolicyPort{networkPolicyPort(corev1.ProtocolTCP, cfg.Deployment.Pilot.Port)}
+	ports := pilotPorts
+	if protocolOf(foo) == serverlessv1alpha1.ProtocolGRPC {
+		// the rpcserver is reachable through the Service of gRPC functions
+		ports = append(ports, networkPolicyPort(corev1.ProtocolTCP, cfg.Deployment.RPCServer.Port))
+	}
+	from := []networkingv1.NetworkPolicyPeer{
+		{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{
+						Key:      namespaceNameLabel,
+						Operator: metav1.LabelSelectorOpIn,
+						Values:   cfg.NetworkPolicy.IngressNamespaces,
+					},
+				},
+			},
+		},
+	}
+	policyTypes := []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	var egress []networkingv1.NetworkPolicyEgressRule
+
+	if network := foo.Spec.Network; network != nil {
+		for _, name := range network.AllowFrom {
+			from = append(from, functionPeer(string(name)))
+		}
+		if network.Egress != nil {
+			policyTypes = append(policyTypes, networkingv1.PolicyTypeEgress)
+			// an empty rule list denies all outgoing traffic
+			egress = []networkingv1.NetworkPolicyEgressRule{}
+			for _, cidr := range network.Egress.CIDRs {
+				egress = append(egress, networkingv1.NetworkPolicyEgressRule{
+					To: []networkingv1.NetworkPolicyPeer{
+						{IPBlock: &networkingv1.IPBlock{CIDR: string(cidr)}},
+					},
+				})
+			}
+			if len(network.Egress.Functions) > 0 {
+				rule := networkingv1.NetworkPolicyEgressRule{Ports: pilotPorts}
+				for _, name := range network.Egress.Functions {
+					rule.To = append(rule.To, functionPeer(string(name)))
+				}
+				egress = append(egress, rule)
+			}
+			if network.Egress.DNS {
+				egress = append(egress, networkingv1.NetworkPolicyEgressRule{
+					Ports: []networkingv1.NetworkPolicyPort{
+						networkPolicyPort(corev1.ProtocolUDP, 53),
+						networkPolicyPort(corev1.ProtocolTCP, 53),
+					},
+					To: []networkingv1.NetworkPolicyPeer{
+						{
+							NamespaceSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									namespaceNameLabel: cfg.NetworkPolicy.DNSNamespace,
+								},
+							},
+						},
+					},
+				})
+			}
+		}
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      tools.GetNetworkPolicyName(foo),
+			Namespace: foo.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(foo, serverlessv1alpha1.SchemeGroupVersion.WithKind("ServerlessFunc")),
+			},
+			Labels: map[string]string{
+				"serverlessfunc":     tools.GetAppName(foo),
+				tools.ManagedByLabel: tools.ManagedBy,
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					Ports: ports,
+					From:  from,
+				},
+			},
+			Egress:      egress,
+			PolicyTypes: policyTypes,
+		},
+	}
+}
+
+// syncNetworkPolicy creates the NetworkPolicy of foo, and reverts any change
+// made to it.
+func (c *Controller) syncNetworkPolicy(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc, cfg *config.ControllerConfig) (err error) {
+	defer metrics.ObserveStep(metrics.StepNetworkPolicy, time.Now(), &err)
+
+	logger := logging.FromContext(ctx)
+
+	desired := newNetworkPolicy(foo, cfg)
+	policy, err := c.kubeclientset.NetworkingV1().NetworkPolicies(foo.Namespace).Get(ctx, desired.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		logger.Info("Creating network policy", "networkPolicy", desired.Name)
+		if _, err = c.kubeclientset.NetworkingV1().NetworkPolicies(foo.Namespace).Create(ctx, desired, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+		c.recordChange(ctx, foo, ReasonNetworkPolicyCreated, "Created network policy %q", desired.Name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(policy, foo) {
+		return permanentError(ErrResourceExists, fmt.Errorf(MessageResourceExists, policy.Name))
+	}
+	diff := tools.DiffNetworkPolicy(desired, policy)
+	if len(diff) == 0 {
+		return nil
+	}
+	logDiff(logger, "NetworkPolicy", diff)
+	logger.Info("Updating network policy", "networkPolicy", desired.Name)
+	desired.ResourceVersion = policy.ResourceVersion
+	if _, err = c.kubeclientset.NetworkingV1().NetworkPolicies(foo.Namespace).Update(ctx, desired, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	c.recordChange(ctx, foo, ReasonNetworkPolicyUpdated, "Updated network policy %q: %s", desired.Name, summarizeDiff(diff))
+	return nil
+}
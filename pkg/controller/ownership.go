@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/logging"
+	"github.com/peizhong/serverless-controller/pkg/tools"
+)
+
+// orphanFinalizer holds the deletion of a ServerlessFunc with the Orphan
+// deletion policy until the owner references of its Deployment and Service
+// are removed, so that the garbage collector leaves them running. Foreground
+// deletion still deletes them first, since it does not wait for finalizers.
+const orphanFinalizer = "serverless.peizhong.io/orphan"
+
+// adoptable returns nil when foo may adopt object, an existing object with
+// the name of one of its own whose selector is given, or the permanent error
+// reporting the conflict.
+func adoptable(foo *serverlessv1alpha1.ServerlessFunc, object metav1.Object, selector string) error {
+	if foo.Annotations[serverlessv1alpha1.AdoptAnnotation] != "true" || metav1.GetControllerOf(object) != nil {
+		return permanentError(ErrResourceExists, fmt.Errorf(MessageResourceExists, object.GetName()))
+	}
+	// the pods of foo are selected by their app label alone, an object
+	// selecting other pods would route to or scale them
+	desired := labels.Set{"serverlessfunc": tools.GetAppName(foo)}.String()
+	if selector != desired {
+		return permanentError(ErrResourceExists, fmt.Errorf(MessageAdoptionRejected, object.GetName(), selector, desired))
+	}
+	return nil
+}
+
+// controlledBy returns refs with a controller reference to foo added.
+func controlledBy(refs []metav1.OwnerReference, foo *serverlessv1alpha1.ServerlessFunc) []metav1.OwnerReference {
+	ref := metav1.NewControllerRef(foo, serverlessv1alpha1.SchemeGroupVersion.WithKind("ServerlessFunc"))
+	return append(append([]metav1.OwnerReference(nil), refs...), *ref)
+}
+
+// releasedBy returns refs without the references to foo.
+func releasedBy(refs []metav1.OwnerReference, foo *serverlessv1alpha1.ServerlessFunc) []metav1.OwnerReference {
+	var released []metav1.OwnerReference
+	for _, ref := range refs {
+		if ref.UID != foo.UID {
+			released = append(released, ref)
+		}
+	}
+	return released
+}
+
+// adoptDeployment makes foo the controller of deployment, which it may adopt.
+func (c *Controller) adoptDeployment(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc, deployment *appsv1.Deployment) (*appsv1.Deployment, error) {
+	if err := adoptable(foo, deployment, metav1.FormatLabelSelector(deployment.Spec.Selector)); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("Adopting deployment", "deployment", deployment.Name)
+	update := deployment.DeepCopy()
+	update.OwnerReferences = controlledBy(update.OwnerReferences, foo)
+	deployment, err := c.kubeclientset.AppsV1().Deployments(foo.Namespace).Update(ctx, update, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	c.recordChange(ctx, foo, ReasonAdopted, "Adopted deployment %q", deployment.Name)
+	return deployment, nil
+}
+
+// adoptService makes foo the controller of service, which it may adopt.
+func (c *Controller) adoptService(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc, service *corev1.Service) (*corev1.Service, error) {
+	if err := adoptable(foo, service, labels.Set(service.Spec.Selector).String()); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("Adopting service", "service", service.Name)
+	update := service.DeepCopy()
+	update.OwnerReferences = controlledBy(update.OwnerReferences, foo)
+	service, err := c.kubeclientset.CoreV1().Services(foo.Namespace).Update(ctx, update, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	c.recordChange(ctx, foo, ReasonAdopted, "Adopted service %q", service.Name)
+	return service, nil
+}
+
+// syncFinalizer adds the orphan finalizer to foo when its deletion policy is
+// Orphan, and removes it otherwise. It returns foo as updated.
+func (c *Controller) syncFinalizer(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc) (*serverlessv1alpha1.ServerlessFunc, error) {
+	orphan := foo.Spec.DeletionPolicy == serverlessv1alpha1.DeletionPolicyOrphan
+	if orphan == hasFinalizer(foo, orphanFinalizer) {
+		return foo, nil
+	}
+	update := foo.DeepCopy()
+	if orphan {
+		update.Finalizers = append(update.Finalizers, orphanFinalizer)
+	} else {
+		update.Finalizers = removeFinalizer(update.Finalizers, orphanFinalizer)
+	}
+	updated, err := c.crdClientSet.ServerlesscontrollerV1alpha1().ServerlessFuncs(foo.Namespace).Update(ctx, update, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// the status is not updated along with the finalizers, the names computed
+	// for this sync are kept so that updateCrdStatus records them
+	updated.Status.ObjectNames = foo.Status.ObjectNames
+	return updated, nil
+}
+
+// finalize releases the Deployment and the Service of foo, which is being
+// deleted with the Orphan deletion policy, then lets the deletion proceed.
+// The other objects of foo are deleted along with it.
+func (c *Controller) finalize(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc) error {
+	if !hasFinalizer(foo, orphanFinalizer) {
+		logging.FromContext(ctx).V(2).Info("ServerlessFunc is being deleted along with its objects")
+		return nil
+	}
+	logger := logging.FromContext(ctx)
+
+	deployments := c.kubeclientset.AppsV1().Deployments(foo.Namespace)
+	deployment, err := deployments.Get(ctx, tools.GetDeploymentName(foo), metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && metav1.IsControlledBy(deployment, foo) {
+		logger.Info("Releasing deployment", "deployment", deployment.Name)
+		update := deployment.DeepCopy()
+		update.OwnerReferences = releasedBy(update.OwnerReferences, foo)
+		if _, err := deployments.Update(ctx, update, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		c.recorder.Eventf(foo, corev1.EventTypeNormal, ReasonReleased, "Released deployment %q", deployment.Name)
+	}
+
+	services := c.kubeclientset.CoreV1().Services(foo.Namespace)
+	service, err := services.Get(ctx, tools.GetServiceName(foo), metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && metav1.IsControlledBy(service, foo) {
+		logger.Info("Releasing service", "service", service.Name)
+		update := service.DeepCopy()
+		update.OwnerReferences = releasedBy(update.OwnerReferences, foo)
+		if _, err := services.Update(ctx, update, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		c.recorder.Eventf(foo, corev1.EventTypeNormal, ReasonReleased, "Released service %q", service.Name)
+	}
+
+	update := foo.DeepCopy()
+	update.Finalizers = removeFinalizer(update.Finalizers, orphanFinalizer)
+	_, err = c.crdClientSet.ServerlesscontrollerV1alpha1().ServerlessFuncs(foo.Namespace).Update(ctx, update, metav1.UpdateOptions{})
+	return err
+}
+
+func hasFinalizer(foo *serverlessv1alpha1.ServerlessFunc, finalizer string) bool {
+	for _, f := range foo.Finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func removeFinalizer(finalizers []string, finalizer string) []string {
+	var kept []string
+	for _, f := range finalizers {
+		if f != finalizer {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
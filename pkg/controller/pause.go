@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/logging"
+	"github.com/peizhong/serverless-controller/pkg/tools"
+)
+
+const (
+	// reasonPausedByAnnotation is the reason of the Paused condition
+	reasonPausedByAnnotation = "PausedByAnnotation"
+	// messagePaused is the message of the Paused condition
+	messagePaused = "The objects of the function are not synced while the " + serverlessv1alpha1.PausedAnnotation + " annotation is true"
+)
+
+// paused reports whether the objects of foo are left as they are.
+func paused(foo *serverlessv1alpha1.ServerlessFunc) bool {
+	return foo.Annotations[serverlessv1alpha1.PausedAnnotation] == "true"
+}
+
+// reconcileRequested reports whether foo requests a full reconcile that was
+// not handled yet.
+func reconcileRequested(foo *serverlessv1alpha1.ServerlessFunc) bool {
+	request, ok := foo.Annotations[serverlessv1alpha1.ReconcileRequestAnnotation]
+	return ok && request != foo.Status.LastHandledReconcileRequest
+}
+
+// reconcileRequestChanged reports whether a new full reconcile was requested
+// between old and new.
+func reconcileRequestChanged(old, new interface{}) bool {
+	oldFoo, ok := old.(*serverlessv1alpha1.ServerlessFunc)
+	if !ok {
+		return false
+	}
+	newFoo, ok := new.(*serverlessv1alpha1.ServerlessFunc)
+	if !ok {
+		return false
+	}
+	return oldFoo.Annotations[serverlessv1alpha1.ReconcileRequestAnnotation] != newFoo.Annotations[serverlessv1alpha1.ReconcileRequestAnnotation]
+}
+
+// reconcilePaused reports the status of foo, which is paused, from its
+// Deployment as it is.
+func (c *Controller) reconcilePaused(ctx context.Context, foo *serverlessv1alpha1.ServerlessFunc) (Result, error) {
+	logging.FromContext(ctx).V(2).Info("ServerlessFunc is paused, skipping the sync of its objects")
+	deployment, err := c.deploymentsLister.Deployments(foo.Namespace).Get(tools.GetDeploymentName(foo))
+	if err != nil && !errors.IsNotFound(err) {
+		return Result{}, err
+	}
+	if err != nil || !metav1.IsControlledBy(deployment, foo) {
+		// there is no rollout to follow until foo is resumed
+		err = c.updateCrdStatus(ctx, foo, &appsv1.Deployment{}, rollout{})
+		return Result{}, err
+	}
+	progress := rolloutStatus(deployment)
+	if err := c.updateCrdStatus(ctx, foo, deployment, progress); err != nil {
+		return Result{}, err
+	}
+	return Result{RequeueAfter: progress.requeueAfter()}, nil
+}
@@ -0,0 +1,386 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/config"
+	clientset "github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned"
+	crdfake "github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned/fake"
+	crdinformers "github.com/peizhong/serverless-controller/pkg/generated/informers/externalversions"
+	"github.com/peizhong/serverless-controller/pkg/logging"
+	"github.com/peizhong/serverless-controller/pkg/tools"
+)
+
+// PlanAction is what a sync would do to an object.
+type PlanAction string
+
+const (
+	PlanCreate PlanAction = "create"
+	PlanUpdate PlanAction = "update"
+	PlanDelete PlanAction = "delete"
+)
+
+// ObjectChange is a change a sync of a ServerlessFunc would make to an
+// object.
+type ObjectChange struct {
+	Action PlanAction `json:"action"`
+	Kind   string     `json:"kind"`
+	Name   string     `json:"name"`
+	// Diff lists the fields an update would change
+	Diff []tools.DiffResult `json:"diff,omitempty"`
+}
+
+// FunctionPlan is the changes a sync of a ServerlessFunc would make.
+type FunctionPlan struct {
+	Namespace string         `json:"namespace"`
+	Name      string         `json:"name"`
+	Changes   []ObjectChange `json:"changes,omitempty"`
+	// Error is the error the sync would fail with, after the changes
+	Error string `json:"error,omitempty"`
+}
+
+// Plan is the changes the controller would make to the objects of every
+// ServerlessFunc, in the order of their namespaces and names.
+type Plan struct {
+	Functions []FunctionPlan `json:"functions"`
+}
+
+// HasChanges reports whether p changes any object, or fails to sync any
+// function.
+func (p *Plan) HasChanges() bool {
+	for _, function := range p.Functions {
+		if len(function.Changes) > 0 || function.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSON writes p as indented JSON.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+// WriteText writes p for people, one function after the other, followed by
+// the number of changes.
+func (p *Plan) WriteText(w io.Writer) error {
+	counts := map[PlanAction]int{}
+	var failed int
+	for _, function := range p.Functions {
+		name := function.Namespace + "/" + function.Name
+		if len(function.Changes) == 0 && function.Error == "" {
+			fmt.Fprintf(w, "%s: no changes\n", name)
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", name)
+		for _, change := range function.Changes {
+			counts[change.Action]++
+			fmt.Fprintf(w, "  %s %s %s\n", planSymbols[change.Action], change.Kind, change.Name)
+			for _, diff := range change.Diff {
+				fmt.Fprintf(w, "      %s: %s -> %s\n", diff.Field, formatPlanValue(diff.Right), formatPlanValue(diff.Left))
+			}
+		}
+		if function.Error != "" {
+			failed++
+			fmt.Fprintf(w, "  ! error: %s\n", function.Error)
+		}
+	}
+	_, err := fmt.Fprintf(w, "\nPlan: %d to create, %d to update, %d to delete, %d failing.\n",
+		counts[PlanCreate], counts[PlanUpdate], counts[PlanDelete], failed)
+	return err
+}
+
+var planSymbols = map[PlanAction]string{
+	PlanCreate: "+",
+	PlanUpdate: "~",
+	PlanDelete: "-",
+}
+
+// formatPlanValue formats a value of a DiffResult as compact JSON.
+func formatPlanValue(value interface{}) string {
+	if value == nil {
+		return "<none>"
+	}
+	data, err := json.Marshal(value)
+	if err != nil || string(data) == "null" {
+		return "<none>"
+	}
+	return string(data)
+}
+
+// PlanFromOptions plans the sync of the ServerlessFuncs watched under opts,
+// without changing the cluster.
+func PlanFromOptions(ctx context.Context, opts Options) (*Plan, error) {
+	if len(opts.Namespaces) > 0 && opts.NamespaceSelector != "" {
+		return nil, fmt.Errorf("namespaces and a namespace selector cannot be combined")
+	}
+	cfg, err := loadConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	kubeclient, crdClientSet, err := newClients(opts)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := opts.Namespaces
+	if opts.NamespaceSelector != "" {
+		list, err := kubeclient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: opts.NamespaceSelector})
+		if err != nil {
+			return nil, err
+		}
+		for _, namespace := range list.Items {
+			namespaces = append(namespaces, namespace.Name)
+		}
+		if len(namespaces) == 0 {
+			return &Plan{Functions: []FunctionPlan{}}, nil
+		}
+	}
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return MakePlan(ctx, kubeclient, crdClientSet, namespaces, cfg)
+}
+
+// MakePlan loads the ServerlessFuncs of namespaces and the objects they may
+// own, then syncs every function with cfg against an in-memory copy of these
+// objects, recording the changes the controller would make. The functions are
+// synced one after the other, so that a change to the shared ingress is
+// planned once.
+func MakePlan(ctx context.Context, kubeclient kubernetes.Interface, crdclient clientset.Interface, namespaces []string, cfg *config.ControllerConfig) (*Plan, error) {
+	var foos []runtime.Object
+	var objects []runtime.Object
+	for _, namespace := range namespaces {
+		functions, err := crdclient.ServerlesscontrollerV1alpha1().ServerlessFuncs(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range functions.Items {
+			foos = append(foos, &functions.Items[i])
+		}
+		deployments, err := kubeclient.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range deployments.Items {
+			objects = append(objects, &deployments.Items[i])
+		}
+		services, err := kubeclient.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range services.Items {
+			objects = append(objects, &services.Items[i])
+		}
+		ingresses, err := kubeclient.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range ingresses.Items {
+			objects = append(objects, &ingresses.Items[i])
+		}
+		policies, err := kubeclient.NetworkingV1().NetworkPolicies(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range policies.Items {
+			objects = append(objects, &policies.Items[i])
+		}
+	}
+	sort.Slice(foos, func(i, j int) bool {
+		a, b := foos[i].(*serverlessv1alpha1.ServerlessFunc), foos[j].(*serverlessv1alpha1.ServerlessFunc)
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	fakeKube := k8sfake.NewSimpleClientset(objects...)
+	fakeCrd := crdfake.NewSimpleClientset(foos...)
+	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(fakeKube, 0)
+	crdInformerFactory := crdinformers.NewSharedInformerFactory(fakeCrd, 0)
+	deploymentInformer := kubeInformerFactory.Apps().V1().Deployments()
+	crdInformer := crdInformerFactory.Serverlesscontroller().V1alpha1().ServerlessFuncs()
+	c := NewController(fakeKube, fakeCrd, deploymentInformer, crdInformer)
+	c.config = cfg
+	// the events of the plan are dropped
+	c.recorder = &record.FakeRecorder{}
+	// the informers are filled as the ones of the controller would be,
+	// which only hold the deployments it created
+	for _, foo := range foos {
+		crdInformer.Informer().GetIndexer().Add(foo)
+	}
+	for _, object := range objects {
+		if deployment, ok := object.(*appsv1.Deployment); ok && deployment.Labels[tools.ManagedByLabel] == tools.ManagedBy {
+			deploymentInformer.Informer().GetIndexer().Add(deployment)
+		}
+	}
+
+	current := map[planKey]runtime.Object{}
+	for _, object := range objects {
+		current[newPlanKey(object)] = object
+	}
+	plan := &Plan{Functions: []FunctionPlan{}}
+	for _, object := range foos {
+		foo := object.(*serverlessv1alpha1.ServerlessFunc)
+		logger := logging.Logger().WithValues("namespace", foo.Namespace, "name", foo.Name)
+		fakeKube.ClearActions()
+		_, err := c.reconcile(logging.NewContext(ctx, logger), foo)
+		function := FunctionPlan{Namespace: foo.Namespace, Name: foo.Name}
+		function.Changes = plannedChanges(fakeKube.Actions(), current)
+		if err != nil {
+			function.Error = err.Error()
+		}
+		plan.Functions = append(plan.Functions, function)
+	}
+	return plan, nil
+}
+
+// planKey identifies an object of the plan.
+type planKey struct {
+	kind, namespace, name string
+}
+
+func newPlanKey(object runtime.Object) planKey {
+	accessor, _ := meta.Accessor(object)
+	return planKey{kind: planKind(object), namespace: accessor.GetNamespace(), name: accessor.GetName()}
+}
+
+// planKind returns the kind of the objects of a plan, which the fake client
+// leaves out of their TypeMeta.
+func planKind(object runtime.Object) string {
+	switch object.(type) {
+	case *appsv1.Deployment:
+		return "Deployment"
+	case *corev1.Service:
+		return "Service"
+	case *networkingv1.Ingress:
+		return "Ingress"
+	case *networkingv1.NetworkPolicy:
+		return "NetworkPolicy"
+	}
+	return fmt.Sprintf("%T", object)
+}
+
+// plannedChanges returns the changes made by actions to the objects in
+// current, which it updates. The changes to an object are merged into one.
+func plannedChanges(actions []core.Action, current map[planKey]runtime.Object) []ObjectChange {
+	type pending struct {
+		action PlanAction
+		before runtime.Object
+		after  runtime.Object
+	}
+	var order []planKey
+	changes := map[planKey]*pending{}
+	for _, action := range actions {
+		var planned PlanAction
+		var object runtime.Object
+		var key planKey
+		// a get action also has a name, so the actions are told apart by
+		// their verb
+		switch action.GetVerb() {
+		case "create":
+			planned, object = PlanCreate, action.(core.CreateAction).GetObject()
+			key = newPlanKey(object)
+		case "update":
+			planned, object = PlanUpdate, action.(core.UpdateAction).GetObject()
+			key = newPlanKey(object)
+		case "delete":
+			a := action.(core.DeleteAction)
+			planned, object = PlanDelete, current[planKey{kind: deletedKind(a), namespace: a.GetNamespace(), name: a.GetName()}]
+			if object == nil {
+				continue
+			}
+			key = newPlanKey(object)
+		default:
+			continue
+		}
+		if key.namespace == "" {
+			key.namespace = action.GetNamespace()
+		}
+		change, ok := changes[key]
+		if !ok {
+			change = &pending{action: planned, before: current[key]}
+			changes[key] = change
+			order = append(order, key)
+		}
+		switch {
+		case planned == PlanDelete:
+			change.action = PlanDelete
+			delete(current, key)
+		case change.action == PlanDelete:
+			// recreated, e.g. a function ingress moved back
+			change.action = PlanUpdate
+		}
+		if planned != PlanDelete {
+			change.after = object
+			current[key] = object
+		}
+	}
+
+	var result []ObjectChange
+	for _, key := range order {
+		change := changes[key]
+		planned := ObjectChange{Action: change.action, Kind: key.kind, Name: key.name}
+		if change.action == PlanUpdate {
+			if change.before == nil {
+				planned.Action = PlanCreate
+			} else if planned.Diff = diffPlanned(change.before, change.after); len(planned.Diff) == 0 {
+				continue
+			}
+		}
+		result = append(result, planned)
+	}
+	return result
+}
+
+// deletedKind returns the kind of the object deleted by action.
+func deletedKind(action core.DeleteAction) string {
+	switch action.GetResource().Resource {
+	case "deployments":
+		return "Deployment"
+	case "services":
+		return "Service"
+	case "ingresses":
+		return "Ingress"
+	case "networkpolicies":
+		return "NetworkPolicy"
+	}
+	return action.GetResource().Resource
+}
+
+// diffPlanned compares an object before and after the updates of a sync.
+func diffPlanned(before, after runtime.Object) []tools.DiffResult {
+	switch desired := after.(type) {
+	case *appsv1.Deployment:
+		return tools.DiffDeployment(desired, before.(*appsv1.Deployment))
+	case *corev1.Service:
+		current := before.(*corev1.Service)
+		return append(tools.DiffMetadata(desired, current), tools.DiffService(desired, current)...)
+	case *networkingv1.Ingress:
+		current := before.(*networkingv1.Ingress)
+		return append(tools.DiffMetadata(desired, current), tools.DiffIngressPaths(desired, current)...)
+	case *networkingv1.NetworkPolicy:
+		current := before.(*networkingv1.NetworkPolicy)
+		return append(tools.DiffMetadata(desired, current), tools.DiffNetworkPolicy(desired, current)...)
+	}
+	return nil
+}
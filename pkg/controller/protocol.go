@@ -0,0 +1,57 @@
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+)
+
+// appProtocols maps the protocol of a function to the appProtocol of its
+// Service port.
+var appProtocols = map[serverlessv1alpha1.Protocol]string{
+	serverlessv1alpha1.ProtocolHTTP:      "http",
+	serverlessv1alpha1.ProtocolH2C:       "kubernetes.io/h2c",
+	serverlessv1alpha1.ProtocolGRPC:      "grpc",
+	serverlessv1alpha1.ProtocolWebSocket: "kubernetes.io/ws",
+}
+
+// protocolOf returns the protocol of foo, http when unset.
+func protocolOf(foo *serverlessv1alpha1.ServerlessFunc) serverlessv1alpha1.Protocol {
+	if foo.Spec.Protocol == "" {
+		return serverlessv1alpha1.ProtocolHTTP
+	}
+	return foo.Spec.Protocol
+}
+
+// applyProtocol tells the pilot container of template which protocol to
+// proxy. Plain http is the pilot default and is left unset.
+func applyProtocol(foo *serverlessv1alpha1.ServerlessFunc, template *corev1.PodTemplateSpec) {
+	protocol := protocolOf(foo)
+	if protocol == serverlessv1alpha1.ProtocolHTTP {
+		return
+	}
+	pilot := &template.Spec.Containers[0]
+	pilot.Env = append(pilot.Env, corev1.EnvVar{
+		Name:  "SERVERLESS_PROTOCOL",
+		Value: string(protocol),
+	})
+}
+
+// protocolIngressAnnotations returns the ingress-nginx annotations needed to
+// proxy the protocol of foo. ingress-nginx cannot talk h2c to a backend, so
+// h2c functions are reached over HTTP/1.1 through the ingress.
+func protocolIngressAnnotations(foo *serverlessv1alpha1.ServerlessFunc) map[string]string {
+	switch protocolOf(foo) {
+	case serverlessv1alpha1.ProtocolGRPC:
+		return map[string]string{
+			"nginx.ingress.kubernetes.io/backend-protocol": "GRPC",
+		}
+	case serverlessv1alpha1.ProtocolWebSocket:
+		// connections are otherwise closed after a minute without messages
+		return map[string]string{
+			"nginx.ingress.kubernetes.io/proxy-read-timeout": "3600",
+			"nginx.ingress.kubernetes.io/proxy-send-timeout": "3600",
+		}
+	}
+	return nil
+}
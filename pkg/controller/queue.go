@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/peizhong/serverless-controller/pkg/metrics"
+)
+
+// Queue states reported by the debug endpoint.
+const (
+	queueStateQueued     = "queued"
+	queueStateWaiting    = "waiting"
+	queueStateProcessing = "processing"
+)
+
+// queueItem is an item of the workqueue as reported by the debug endpoint.
+type queueItem struct {
+	Key   string `json:"key"`
+	State string `json:"state"`
+	// Since is when the item was added, or when it was picked by a worker
+	Since time.Time `json:"since"`
+	// ReadyAt is when a waiting item is added to the queue
+	ReadyAt  *time.Time `json:"readyAt,omitempty"`
+	Requeues int        `json:"requeues"`
+}
+
+// trackingQueue is a rate limiting workqueue that remembers its items, since
+// the workqueue of client-go cannot be listed. The rate limiting is done here
+// rather than by NewRateLimitingQueue, so that rate limited items go through
+// AddAfter and are tracked as well. Only rate limited items count as
+// retries, the delaying queue of client-go would count every AddAfter.
+type trackingQueue struct {
+	workqueue.DelayingInterface
+	rateLimiter workqueue.RateLimiter
+	retries     workqueue.CounterMetric
+
+	lock       sync.Mutex
+	queued     map[interface{}]time.Time
+	waiting    map[interface{}]waitingItem
+	processing map[interface{}]time.Time
+}
+
+// waitingItem is an item added with a delay.
+type waitingItem struct {
+	since   time.Time
+	readyAt time.Time
+}
+
+func newTrackingQueue(rateLimiter workqueue.RateLimiter, name string) *trackingQueue {
+	return &trackingQueue{
+		// without a name the delaying queue has no retries metric
+		DelayingInterface: workqueue.NewDelayingQueueWithCustomQueue(workqueue.NewNamed(name), ""),
+		rateLimiter:       rateLimiter,
+		retries:           metrics.WorkqueueRetries(name),
+		queued:            map[interface{}]time.Time{},
+		waiting:           map[interface{}]waitingItem{},
+		processing:        map[interface{}]time.Time{},
+	}
+}
+
+func (q *trackingQueue) Add(item interface{}) {
+	q.lock.Lock()
+	if _, ok := q.queued[item]; !ok {
+		q.queued[item] = time.Now()
+	}
+	q.lock.Unlock()
+	q.DelayingInterface.Add(item)
+}
+
+func (q *trackingQueue) AddAfter(item interface{}, duration time.Duration) {
+	if duration <= 0 {
+		q.Add(item)
+		return
+	}
+	now := time.Now()
+	readyAt := now.Add(duration)
+	q.lock.Lock()
+	// the delaying queue keeps the earliest time as well
+	if current, ok := q.waiting[item]; !ok {
+		q.waiting[item] = waitingItem{since: now, readyAt: readyAt}
+	} else if readyAt.Before(current.readyAt) {
+		q.waiting[item] = waitingItem{since: current.since, readyAt: readyAt}
+	}
+	q.lock.Unlock()
+	q.DelayingInterface.AddAfter(item, duration)
+}
+
+func (q *trackingQueue) AddRateLimited(item interface{}) {
+	q.retries.Inc()
+	q.AddAfter(item, q.rateLimiter.When(item))
+}
+
+func (q *trackingQueue) Forget(item interface{}) {
+	q.rateLimiter.Forget(item)
+}
+
+func (q *trackingQueue) NumRequeues(item interface{}) int {
+	return q.rateLimiter.NumRequeues(item)
+}
+
+func (q *trackingQueue) Get() (interface{}, bool) {
+	item, shutdown := q.DelayingInterface.Get()
+	if shutdown {
+		return item, shutdown
+	}
+	now := time.Now()
+	q.lock.Lock()
+	delete(q.queued, item)
+	// an item added with a delay is still added once the delay is over
+	if waiting, ok := q.waiting[item]; ok && !waiting.readyAt.After(now) {
+		delete(q.waiting, item)
+	}
+	q.processing[item] = now
+	q.lock.Unlock()
+	return item, shutdown
+}
+
+func (q *trackingQueue) Done(item interface{}) {
+	q.lock.Lock()
+	delete(q.processing, item)
+	q.lock.Unlock()
+	q.DelayingInterface.Done(item)
+}
+
+// Items returns the items of the queue sorted by key. An item may appear
+// twice, e.g. when it is added again while it is processed.
+func (q *trackingQueue) Items() []queueItem {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	now := time.Now()
+	var items []queueItem
+	add := func(item interface{}, state string, since time.Time, readyAt *time.Time) {
+		key, _ := item.(string)
+		items = append(items, queueItem{
+			Key:      key,
+			State:    state,
+			Since:    since,
+			ReadyAt:  readyAt,
+			Requeues: q.rateLimiter.NumRequeues(item),
+		})
+	}
+	for item, since := range q.queued {
+		add(item, queueStateQueued, since, nil)
+	}
+	for item, waiting := range q.waiting {
+		readyAt := waiting.readyAt
+		if !readyAt.After(now) {
+			// moved to the queue by the delaying queue itself
+			if _, ok := q.queued[item]; !ok {
+				add(item, queueStateQueued, readyAt, nil)
+			}
+			continue
+		}
+		add(item, queueStateWaiting, waiting.since, &readyAt)
+	}
+	for item, since := range q.processing {
+		add(item, queueStateProcessing, since, nil)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Key != items[j].Key {
+			return items[i].Key < items[j].Key
+		}
+		return items[i].State < items[j].State
+	})
+	return items
+}
+
+// OldestProcessing returns the item processed for the longest time, and when
+// its processing started.
+func (q *trackingQueue) OldestProcessing() (interface{}, time.Time, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	var oldest interface{}
+	var start time.Time
+	for item, since := range q.processing {
+		if oldest == nil || since.Before(start) {
+			oldest, start = item, since
+		}
+	}
+	return oldest, start, oldest != nil
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/time/rate"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Result tells the workqueue when to sync a ServerlessFunc again after a
+// successful sync. The ServerlessFunc is only synced again on changes when it
+// is empty.
+type Result struct {
+	// RequeueAfter syncs the ServerlessFunc again after this duration
+	RequeueAfter time.Duration
+}
+
+// errorClass tells how a failed sync is retried.
+type errorClass int
+
+const (
+	// errorNone is the class of a nil error
+	errorNone errorClass = iota
+	// errorTransient errors, e.g. timeouts, are retried with backoff
+	errorTransient
+	// errorConflict errors are caused by a cache behind the API server,
+	// which soon catches up, so they are retried without growing the backoff
+	errorConflict
+	// errorPermanent errors cannot be fixed by retrying. They are reported in
+	// the status of the ServerlessFunc, which is synced again on its next
+	// change or resync
+	errorPermanent
+)
+
+// reconcileError is an error whose class is known where it occurs.
+type reconcileError struct {
+	class errorClass
+	// reason is the reason of the condition and Event reporting the error
+	reason string
+	err    error
+}
+
+func (e *reconcileError) Error() string {
+	return e.err.Error()
+}
+
+func (e *reconcileError) Unwrap() error {
+	return e.err
+}
+
+// permanentError marks err as an error retrying cannot fix.
+func permanentError(reason string, err error) error {
+	return &reconcileError{class: errorPermanent, reason: reason, err: err}
+}
+
+// classify returns the class of err, and the reason reported for permanent
+// errors. Requests rejected by the API server as invalid are permanent, since
+// the same request is sent again.
+func classify(err error) (errorClass, string) {
+	if err == nil {
+		return errorNone, ""
+	}
+	var reconcileErr *reconcileError
+	if errors.As(err, &reconcileErr) {
+		return reconcileErr.class, reconcileErr.reason
+	}
+	switch {
+	case apierrors.IsConflict(err):
+		return errorConflict, ""
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return errorPermanent, string(apierrors.ReasonForError(err))
+	}
+	return errorTransient, ""
+}
+
+// Backoff is how long a ServerlessFunc waits before being synced again after a
+// transient error.
+type Backoff struct {
+	// Base is the delay after the first failure, doubled on every following
+	// failure. Conflicts are retried after Base as well
+	Base time.Duration
+	// Max bounds the delay
+	Max time.Duration
+	// QPS and Burst bound the rate of retries over all ServerlessFuncs
+	QPS   float64
+	Burst int
+}
+
+// DefaultBackoff returns the backoff of workqueue.DefaultControllerRateLimiter.
+func DefaultBackoff() Backoff {
+	return Backoff{
+		Base:  5 * time.Millisecond,
+		Max:   1000 * time.Second,
+		QPS:   10,
+		Burst: 100,
+	}
+}
+
+func (b Backoff) rateLimiter() workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(b.Base, b.Max),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(b.QPS), b.Burst)},
+	)
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// rolloutPollPeriod is how often a ServerlessFunc is synced again while the
+// rollout of its Deployment is in progress, so that its status follows the
+// pods even when an event of the Deployment is missed or delivered late.
+const rolloutPollPeriod = 5 * time.Second
+
+const (
+	// reasonObservedGenerationPending is the rollout reason of a Deployment
+	// whose latest spec is not observed by the deployment controller yet
+	reasonObservedGenerationPending = "ObservedGenerationPending"
+	// reasonRolloutInProgress is the rollout reason of a Deployment in
+	// progress without a Progressing condition
+	reasonRolloutInProgress = "RolloutInProgress"
+	// reasonMinimumReplicasAvailable is the rollout reason of a complete
+	// rollout without an Available condition
+	reasonMinimumReplicasAvailable = "MinimumReplicasAvailable"
+	// reasonProgressDeadlineExceeded is the reason the deployment
+	// controller sets on the Progressing condition of a stalled rollout
+	reasonProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+)
+
+// rollout is the progress of the rollout of a Deployment.
+type rollout struct {
+	Phase   serverlessv1alpha1.RolloutPhase
+	Reason  string
+	Message string
+}
+
+// rolloutStatus tells how far the rollout of deployment is, the same way
+// kubectl rollout status does.
+func rolloutStatus(deployment *appsv1.Deployment) rollout {
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		return rollout{
+			Phase:   serverlessv1alpha1.RolloutPhaseProgressing,
+			Reason:  reasonObservedGenerationPending,
+			Message: "Waiting for the deployment spec update to be observed",
+		}
+	}
+	if c := deploymentCondition(deployment, appsv1.DeploymentReplicaFailure); c != nil && c.Status == corev1.ConditionTrue {
+		return rollout{Phase: serverlessv1alpha1.RolloutPhaseStalled, Reason: c.Reason, Message: c.Message}
+	}
+	progressing := deploymentCondition(deployment, appsv1.DeploymentProgressing)
+	if progressing != nil && progressing.Reason == reasonProgressDeadlineExceeded {
+		return rollout{Phase: serverlessv1alpha1.RolloutPhaseStalled, Reason: progressing.Reason, Message: progressing.Message}
+	}
+
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+	var message string
+	switch {
+	case status.UpdatedReplicas < desired:
+		message = fmt.Sprintf("%d out of %d new replicas have been updated", status.UpdatedReplicas, desired)
+	case status.Replicas > status.UpdatedReplicas:
+		message = fmt.Sprintf("%d old replicas are pending termination", status.Replicas-status.UpdatedReplicas)
+	case status.AvailableReplicas < status.UpdatedReplicas:
+		message = fmt.Sprintf("%d of %d updated replicas are available", status.AvailableReplicas, status.UpdatedReplicas)
+	}
+	if message != "" {
+		reason := reasonRolloutInProgress
+		if progressing != nil {
+			reason = progressing.Reason
+		}
+		return rollout{Phase: serverlessv1alpha1.RolloutPhaseProgressing, Reason: reason, Message: message}
+	}
+
+	available := rollout{
+		Phase:   serverlessv1alpha1.RolloutPhaseAvailable,
+		Reason:  reasonMinimumReplicasAvailable,
+		Message: fmt.Sprintf("%d of %d replicas are available", status.AvailableReplicas, desired),
+	}
+	if c := deploymentCondition(deployment, appsv1.DeploymentAvailable); c != nil {
+		available.Reason = c.Reason
+	}
+	return available
+}
+
+// requeueAfter is the delay before the ServerlessFunc of the rollout is
+// synced again, zero once the rollout is stable.
+func (r rollout) requeueAfter() time.Duration {
+	if r.Phase == serverlessv1alpha1.RolloutPhaseProgressing {
+		return rolloutPollPeriod
+	}
+	return 0
+}
+
+// deploymentCondition returns the condition of deployment of type t, nil if
+// it is missing.
+func deploymentCondition(deployment *appsv1.Deployment, t appsv1.DeploymentConditionType) *appsv1.DeploymentCondition {
+	for i := range deployment.Status.Conditions {
+		if deployment.Status.Conditions[i].Type == t {
+			return &deployment.Status.Conditions[i]
+		}
+	}
+	return nil
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 
 	serverlesscontrollerv1alpha1 "github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned/typed/serverlesscontroller/v1alpha1"
+	serverlesscontrollerv1beta1 "github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned/typed/serverlesscontroller/v1beta1"
 	discovery "k8s.io/client-go/discovery"
 	rest "k8s.io/client-go/rest"
 	flowcontrol "k8s.io/client-go/util/flowcontrol"
@@ -30,6 +31,7 @@ import (
 type Interface interface {
 	Discovery() discovery.DiscoveryInterface
 	ServerlesscontrollerV1alpha1() serverlesscontrollerv1alpha1.ServerlesscontrollerV1alpha1Interface
+	ServerlesscontrollerV1beta1() serverlesscontrollerv1beta1.ServerlesscontrollerV1beta1Interface
 }
 
 // Clientset contains the clients for groups. Each group has exactly one
@@ -37,6 +39,7 @@ type Interface interface {
 type Clientset struct {
 	*discovery.DiscoveryClient
 	serverlesscontrollerV1alpha1 *serverlesscontrollerv1alpha1.ServerlesscontrollerV1alpha1Client
+	serverlesscontrollerV1beta1  *serverlesscontrollerv1beta1.ServerlesscontrollerV1beta1Client
 }
 
 // ServerlesscontrollerV1alpha1 retrieves the ServerlesscontrollerV1alpha1Client
@@ -44,6 +47,11 @@ func (c *Clientset) ServerlesscontrollerV1alpha1() serverlesscontrollerv1alpha1.
 	return c.serverlesscontrollerV1alpha1
 }
 
+// ServerlesscontrollerV1beta1 retrieves the ServerlesscontrollerV1beta1Client
+func (c *Clientset) ServerlesscontrollerV1beta1() serverlesscontrollerv1beta1.ServerlesscontrollerV1beta1Interface {
+	return c.serverlesscontrollerV1beta1
+}
+
 // Discovery retrieves the DiscoveryClient
 func (c *Clientset) Discovery() discovery.DiscoveryInterface {
 	if c == nil {
@@ -69,6 +77,10 @@ func NewForConfig(c *rest.Config) (*Clientset, error) {
 	if err != nil {
 		return nil, err
 	}
+	cs.serverlesscontrollerV1beta1, err = serverlesscontrollerv1beta1.NewForConfig(&configShallowCopy)
+	if err != nil {
+		return nil, err
+	}
 
 	cs.DiscoveryClient, err = discovery.NewDiscoveryClientForConfig(&configShallowCopy)
 	if err != nil {
@@ -82,6 +94,7 @@ func NewForConfig(c *rest.Config) (*Clientset, error) {
 func NewForConfigOrDie(c *rest.Config) *Clientset {
 	var cs Clientset
 	cs.serverlesscontrollerV1alpha1 = serverlesscontrollerv1alpha1.NewForConfigOrDie(c)
+	cs.serverlesscontrollerV1beta1 = serverlesscontrollerv1beta1.NewForConfigOrDie(c)
 
 	cs.DiscoveryClient = discovery.NewDiscoveryClientForConfigOrDie(c)
 	return &cs
@@ -91,6 +104,7 @@ func NewForConfigOrDie(c *rest.Config) *Clientset {
 func New(c rest.Interface) *Clientset {
 	var cs Clientset
 	cs.serverlesscontrollerV1alpha1 = serverlesscontrollerv1alpha1.New(c)
+	cs.serverlesscontrollerV1beta1 = serverlesscontrollerv1beta1.New(c)
 
 	cs.DiscoveryClient = discovery.NewDiscoveryClient(c)
 	return &cs
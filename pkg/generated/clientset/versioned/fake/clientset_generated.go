@@ -22,6 +22,8 @@ import (
 	clientset "github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned"
 	serverlesscontrollerv1alpha1 "github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned/typed/serverlesscontroller/v1alpha1"
 	fakeserverlesscontrollerv1alpha1 "github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned/typed/serverlesscontroller/v1alpha1/fake"
+	serverlesscontrollerv1beta1 "github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned/typed/serverlesscontroller/v1beta1"
+	fakeserverlesscontrollerv1beta1 "github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned/typed/serverlesscontroller/v1beta1/fake"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/discovery"
@@ -80,3 +82,8 @@ var _ clientset.Interface = &Clientset{}
 func (c *Clientset) ServerlesscontrollerV1alpha1() serverlesscontrollerv1alpha1.ServerlesscontrollerV1alpha1Interface {
 	return &fakeserverlesscontrollerv1alpha1.FakeServerlesscontrollerV1alpha1{Fake: &c.Fake}
 }
+
+// ServerlesscontrollerV1beta1 retrieves the ServerlesscontrollerV1beta1Client
+func (c *Clientset) ServerlesscontrollerV1beta1() serverlesscontrollerv1beta1.ServerlesscontrollerV1beta1Interface {
+	return &fakeserverlesscontrollerv1beta1.FakeServerlesscontrollerV1beta1{Fake: &c.Fake}
+}
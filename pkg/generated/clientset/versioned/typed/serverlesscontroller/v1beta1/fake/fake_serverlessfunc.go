@@ -0,0 +1,142 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1beta1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeServerlessFuncs implements ServerlessFuncInterface
+type FakeServerlessFuncs struct {
+	Fake *FakeServerlesscontrollerV1beta1
+	ns   string
+}
+
+var serverlessfuncsResource = schema.GroupVersionResource{Group: "serverlesscontroller.peizhong.io", Version: "v1beta1", Resource: "serverlessfuncs"}
+
+var serverlessfuncsKind = schema.GroupVersionKind{Group: "serverlesscontroller.peizhong.io", Version: "v1beta1", Kind: "ServerlessFunc"}
+
+// Get takes name of the serverlessFunc, and returns the corresponding serverlessFunc object, and an error if there is any.
+func (c *FakeServerlessFuncs) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1beta1.ServerlessFunc, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(serverlessfuncsResource, c.ns, name), &v1beta1.ServerlessFunc{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.ServerlessFunc), err
+}
+
+// List takes label and field selectors, and returns the list of ServerlessFuncs that match those selectors.
+func (c *FakeServerlessFuncs) List(ctx context.Context, opts v1.ListOptions) (result *v1beta1.ServerlessFuncList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(serverlessfuncsResource, serverlessfuncsKind, c.ns, opts), &v1beta1.ServerlessFuncList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1beta1.ServerlessFuncList{ListMeta: obj.(*v1beta1.ServerlessFuncList).ListMeta}
+	for _, item := range obj.(*v1beta1.ServerlessFuncList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested serverlessFuncs.
+func (c *FakeServerlessFuncs) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(serverlessfuncsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a serverlessFunc and creates it.  Returns the server's representation of the serverlessFunc, and an error, if there is any.
+func (c *FakeServerlessFuncs) Create(ctx context.Context, serverlessFunc *v1beta1.ServerlessFunc, opts v1.CreateOptions) (result *v1beta1.ServerlessFunc, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(serverlessfuncsResource, c.ns, serverlessFunc), &v1beta1.ServerlessFunc{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.ServerlessFunc), err
+}
+
+// Update takes the representation of a serverlessFunc and updates it. Returns the server's representation of the serverlessFunc, and an error, if there is any.
+func (c *FakeServerlessFuncs) Update(ctx context.Context, serverlessFunc *v1beta1.ServerlessFunc, opts v1.UpdateOptions) (result *v1beta1.ServerlessFunc, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(serverlessfuncsResource, c.ns, serverlessFunc), &v1beta1.ServerlessFunc{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.ServerlessFunc), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeServerlessFuncs) UpdateStatus(ctx context.Context, serverlessFunc *v1beta1.ServerlessFunc, opts v1.UpdateOptions) (*v1beta1.ServerlessFunc, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(serverlessfuncsResource, "status", c.ns, serverlessFunc), &v1beta1.ServerlessFunc{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.ServerlessFunc), err
+}
+
+// Delete takes name of the serverlessFunc and deletes it. Returns an error if one occurs.
+func (c *FakeServerlessFuncs) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(serverlessfuncsResource, c.ns, name), &v1beta1.ServerlessFunc{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeServerlessFuncs) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(serverlessfuncsResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1beta1.ServerlessFuncList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched serverlessFunc.
+func (c *FakeServerlessFuncs) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1beta1.ServerlessFunc, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(serverlessfuncsResource, c.ns, name, pt, data, subresources...), &v1beta1.ServerlessFunc{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.ServerlessFunc), err
+}
@@ -0,0 +1,195 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	"context"
+	"time"
+
+	v1beta1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1beta1"
+	scheme "github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// ServerlessFuncsGetter has a method to return a ServerlessFuncInterface.
+// A group's client should implement this interface.
+type ServerlessFuncsGetter interface {
+	ServerlessFuncs(namespace string) ServerlessFuncInterface
+}
+
+// ServerlessFuncInterface has methods to work with ServerlessFunc resources.
+type ServerlessFuncInterface interface {
+	Create(ctx context.Context, serverlessFunc *v1beta1.ServerlessFunc, opts v1.CreateOptions) (*v1beta1.ServerlessFunc, error)
+	Update(ctx context.Context, serverlessFunc *v1beta1.ServerlessFunc, opts v1.UpdateOptions) (*v1beta1.ServerlessFunc, error)
+	UpdateStatus(ctx context.Context, serverlessFunc *v1beta1.ServerlessFunc, opts v1.UpdateOptions) (*v1beta1.ServerlessFunc, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1beta1.ServerlessFunc, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v1beta1.ServerlessFuncList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1beta1.ServerlessFunc, err error)
+	ServerlessFuncExpansion
+}
+
+// serverlessFuncs implements ServerlessFuncInterface
+type serverlessFuncs struct {
+	client rest.Interface
+	ns     string
+}
+
+// newServerlessFuncs returns a ServerlessFuncs
+func newServerlessFuncs(c *ServerlesscontrollerV1beta1Client, namespace string) *serverlessFuncs {
+	return &serverlessFuncs{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the serverlessFunc, and returns the corresponding serverlessFunc object, and an error if there is any.
+func (c *serverlessFuncs) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1beta1.ServerlessFunc, err error) {
+	result = &v1beta1.ServerlessFunc{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("serverlessfuncs").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of ServerlessFuncs that match those selectors.
+func (c *serverlessFuncs) List(ctx context.Context, opts v1.ListOptions) (result *v1beta1.ServerlessFuncList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1beta1.ServerlessFuncList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("serverlessfuncs").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested serverlessFuncs.
+func (c *serverlessFuncs) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("serverlessfuncs").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a serverlessFunc and creates it.  Returns the server's representation of the serverlessFunc, and an error, if there is any.
+func (c *serverlessFuncs) Create(ctx context.Context, serverlessFunc *v1beta1.ServerlessFunc, opts v1.CreateOptions) (result *v1beta1.ServerlessFunc, err error) {
+	result = &v1beta1.ServerlessFunc{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("serverlessfuncs").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(serverlessFunc).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a serverlessFunc and updates it. Returns the server's representation of the serverlessFunc, and an error, if there is any.
+func (c *serverlessFuncs) Update(ctx context.Context, serverlessFunc *v1beta1.ServerlessFunc, opts v1.UpdateOptions) (result *v1beta1.ServerlessFunc, err error) {
+	result = &v1beta1.ServerlessFunc{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("serverlessfuncs").
+		Name(serverlessFunc.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(serverlessFunc).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *serverlessFuncs) UpdateStatus(ctx context.Context, serverlessFunc *v1beta1.ServerlessFunc, opts v1.UpdateOptions) (result *v1beta1.ServerlessFunc, err error) {
+	result = &v1beta1.ServerlessFunc{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("serverlessfuncs").
+		Name(serverlessFunc.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(serverlessFunc).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the serverlessFunc and deletes it. Returns an error if one occurs.
+func (c *serverlessFuncs) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("serverlessfuncs").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *serverlessFuncs) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("serverlessfuncs").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched serverlessFunc.
+func (c *serverlessFuncs) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1beta1.ServerlessFunc, err error) {
+	result = &v1beta1.ServerlessFunc{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("serverlessfuncs").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
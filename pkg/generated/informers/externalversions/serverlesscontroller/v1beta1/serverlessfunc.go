@@ -0,0 +1,90 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	"context"
+	time "time"
+
+	serverlesscontrollerv1beta1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1beta1"
+	versioned "github.com/peizhong/serverless-controller/pkg/generated/clientset/versioned"
+	internalinterfaces "github.com/peizhong/serverless-controller/pkg/generated/informers/externalversions/internalinterfaces"
+	v1beta1 "github.com/peizhong/serverless-controller/pkg/generated/listers/serverlesscontroller/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// ServerlessFuncInformer provides access to a shared informer and lister for
+// ServerlessFuncs.
+type ServerlessFuncInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() v1beta1.ServerlessFuncLister
+}
+
+type serverlessFuncInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewServerlessFuncInformer constructs a new informer for ServerlessFunc type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewServerlessFuncInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredServerlessFuncInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredServerlessFuncInformer constructs a new informer for ServerlessFunc type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredServerlessFuncInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.ServerlesscontrollerV1beta1().ServerlessFuncs(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.ServerlesscontrollerV1beta1().ServerlessFuncs(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&serverlesscontrollerv1beta1.ServerlessFunc{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *serverlessFuncInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredServerlessFuncInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *serverlessFuncInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&serverlesscontrollerv1beta1.ServerlessFunc{}, f.defaultInformer)
+}
+
+func (f *serverlessFuncInformer) Lister() v1beta1.ServerlessFuncLister {
+	return v1beta1.NewServerlessFuncLister(f.Informer().GetIndexer())
+}
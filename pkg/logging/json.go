@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/klog/v2"
+)
+
+// jsonLogger writes every line as a JSON object holding the time, level,
+// verbosity, name and message followed by the key/value pairs.
+type jsonLogger struct {
+	out    *syncWriter
+	name   string
+	level  int
+	values []interface{}
+	// now is replaced by tests
+	now func() time.Time
+}
+
+type syncWriter struct {
+	sync.Mutex
+	w io.Writer
+}
+
+// NewJSONLogger returns a logger writing JSON lines to w. Verbosity is set
+// by the klog -v flag.
+func NewJSONLogger(w io.Writer) logr.Logger {
+	return jsonLogger{out: &syncWriter{w: w}, now: time.Now}
+}
+
+func (l jsonLogger) Enabled() bool {
+	return klog.V(klog.Level(l.level)).Enabled()
+}
+
+func (l jsonLogger) Info(msg string, keysAndValues ...interface{}) {
+	if l.Enabled() {
+		l.write("info", msg, nil, keysAndValues)
+	}
+}
+
+func (l jsonLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.write("error", msg, err, keysAndValues)
+}
+
+func (l jsonLogger) V(level int) logr.Logger {
+	l.level += level
+	return l
+}
+
+func (l jsonLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	values := make([]interface{}, 0, len(l.values)+len(keysAndValues))
+	l.values = append(append(values, l.values...), keysAndValues...)
+	return l
+}
+
+func (l jsonLogger) WithName(name string) logr.Logger {
+	if l.name != "" {
+		name = l.name + "/" + name
+	}
+	l.name = name
+	return l
+}
+
+func (l jsonLogger) write(level, msg string, err error, keysAndValues []interface{}) {
+	b := &bytes.Buffer{}
+	b.WriteString(`{"ts":`)
+	writeValue(b, l.now().UTC().Format(time.RFC3339Nano))
+	writeField(b, "level", level)
+	writeField(b, "v", l.level)
+	if l.name != "" {
+		writeField(b, "logger", l.name)
+	}
+	writeField(b, "msg", msg)
+	if err != nil {
+		writeField(b, "err", err.Error())
+	}
+	writePairs(b, l.values)
+	writePairs(b, keysAndValues)
+	b.WriteString("}\n")
+
+	l.out.Lock()
+	defer l.out.Unlock()
+	l.out.w.Write(b.Bytes())
+}
+
+func writePairs(b *bytes.Buffer, keysAndValues []interface{}) {
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+		if i+1 == len(keysAndValues) {
+			writeField(b, key, "(MISSING)")
+			break
+		}
+		writeField(b, key, keysAndValues[i+1])
+	}
+}
+
+func writeField(b *bytes.Buffer, key string, value interface{}) {
+	b.WriteByte(',')
+	writeValue(b, key)
+	b.WriteByte(':')
+	writeValue(b, value)
+}
+
+// writeValue writes value as JSON, errors as their message and values that
+// cannot be encoded as their Go representation.
+func writeValue(b *bytes.Buffer, value interface{}) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("%+v", value))
+	}
+	b.Write(data)
+}
@@ -0,0 +1,65 @@
+// Package logging sets up the structured logs of the controller, printed by
+// klog as text or as one JSON object per line.
+//
+// Verbosity levels:
+//
+//	0  changes made to the cluster, errors, startup and config reloads
+//	2  the start and outcome of every reconcile
+//	4  events received from informers and steps that changed nothing
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/go-logr/logr"
+	"k8s.io/klog/v2"
+	"k8s.io/klog/v2/klogr"
+)
+
+// Formats of the logs.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// base is the logger every other logger derives from.
+var base = klogr.New()
+
+// Setup prints the logs in format to w. Lines logged through klog, by the
+// controller and by client-go alike, are printed in the same format.
+func Setup(format string, w io.Writer) error {
+	switch format {
+	case FormatText:
+		klog.SetOutput(w)
+		base = klogr.New()
+	case FormatJSON:
+		base = NewJSONLogger(w)
+		klog.SetLogger(base)
+	default:
+		return fmt.Errorf("unsupported log format %q, must be %s or %s", format, FormatText, FormatJSON)
+	}
+	return nil
+}
+
+// Logger returns the logger of the controller.
+func Logger() logr.Logger {
+	return base
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying logger.
+func NewContext(ctx context.Context, logger logr.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the logger of the
+// controller when there is none.
+func FromContext(ctx context.Context) logr.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(logr.Logger); ok {
+		return logger
+	}
+	return base
+}
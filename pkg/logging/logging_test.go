@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+func TestJSONLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewJSONLogger(buf).(jsonLogger)
+	logger.now = func() time.Time { return time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	reconcile := logger.WithName("controller").WithValues("namespace", "default", "name", "test")
+	reconcile.Info("Reconciling foo", "generation", int64(2))
+	reconcile.Error(errors.New("conflict"), "Failed to update", "object", klog.KRef("default", "func-test"))
+	// -v defaults to 0
+	reconcile.V(2).Info("not printed")
+
+	decoder := json.NewDecoder(buf)
+	var lines []map[string]interface{}
+	for decoder.More() {
+		line := map[string]interface{}{}
+		if err := decoder.Decode(&line); err != nil {
+			t.Fatalf("invalid JSON line: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	expected := []map[string]interface{}{
+		{
+			"ts":         "2021-01-02T03:04:05Z",
+			"level":      "info",
+			"v":          float64(0),
+			"logger":     "controller",
+			"msg":        "Reconciling foo",
+			"namespace":  "default",
+			"name":       "test",
+			"generation": float64(2),
+		},
+		{
+			"ts":        "2021-01-02T03:04:05Z",
+			"level":     "error",
+			"v":         float64(0),
+			"logger":    "controller",
+			"msg":       "Failed to update",
+			"err":       "conflict",
+			"namespace": "default",
+			"name":      "test",
+			"object":    map[string]interface{}{"name": "func-test", "namespace": "default"},
+		},
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected\n\t%v\ngot\n\t%v", expected, lines)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if reflect.TypeOf(FromContext(context.Background())) != reflect.TypeOf(Logger()) {
+		t.Error("expected the controller logger without a logger in the context")
+	}
+	buf := &bytes.Buffer{}
+	logger := NewJSONLogger(buf).WithValues("reconcileID", "1")
+	FromContext(NewContext(context.Background(), logger)).Info("Reconciling foo")
+	if !strings.Contains(buf.String(), `"reconcileID":"1"`) {
+		t.Errorf("expected the logger of the context, got %s", buf.String())
+	}
+}
+
+func TestSetupRejectsUnknownFormat(t *testing.T) {
+	if err := Setup("xml", &bytes.Buffer{}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
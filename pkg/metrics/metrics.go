@@ -0,0 +1,98 @@
+// Package metrics defines the Prometheus metrics of the controller and the
+// handler serving them.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "serverless_controller"
+
+// Steps of a reconcile, used as the step label.
+const (
+	StepDeployment    = "deployment"
+	StepService       = "service"
+	StepNetworkPolicy = "networkpolicy"
+	StepIngress       = "ingress"
+	StepStatus        = "status"
+)
+
+var (
+	// Registry holds every metric of the controller, along with the process
+	// and Go runtime metrics.
+	Registry = prometheus.NewRegistry()
+
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "reconcile_step_duration_seconds",
+		Help:      "Duration of each step of a reconcile.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"step"})
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_step_errors_total",
+		Help:      "Number of reconciles that failed at each step.",
+	}, []string{"step"})
+
+	apiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_requests_total",
+		Help:      "Number of requests to the Kubernetes API by verb, resource and status code.",
+	}, []string{"verb", "resource", "code"})
+
+	desiredReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "function_desired_replicas",
+		Help:      "Replicas requested by each function.",
+	}, []string{"namespace", "name"})
+	availableReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "function_available_replicas",
+		Help:      "Available replicas of the deployment of each function.",
+	}, []string{"namespace", "name"})
+)
+
+func init() {
+	Registry.MustRegister(
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		prometheus.NewGoCollector(),
+		reconcileDuration,
+		reconcileErrors,
+		apiRequests,
+		desiredReplicas,
+		availableReplicas,
+	)
+}
+
+// Handler serves the metrics of Registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveStep records the duration of a reconcile step started at start, and
+// counts it as failed when *err is set. It is meant to be deferred with a
+// named error result:
+//
+//	defer metrics.ObserveStep(metrics.StepService, time.Now(), &err)
+func ObserveStep(step string, start time.Time, err *error) {
+	reconcileDuration.WithLabelValues(step).Observe(time.Since(start).Seconds())
+	if *err != nil {
+		reconcileErrors.WithLabelValues(step).Inc()
+	}
+}
+
+// SetReplicas records the desired and available replicas of a function.
+func SetReplicas(namespace, name string, desired, available int32) {
+	desiredReplicas.WithLabelValues(namespace, name).Set(float64(desired))
+	availableReplicas.WithLabelValues(namespace, name).Set(float64(available))
+}
+
+// DeleteFunction removes the gauges of a function that no longer exists.
+func DeleteFunction(namespace, name string) {
+	desiredReplicas.DeleteLabelValues(namespace, name)
+	availableReplicas.DeleteLabelValues(namespace, name)
+}
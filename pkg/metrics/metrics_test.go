@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestRequestInfo(t *testing.T) {
+	tests := []struct {
+		method   string
+		url      string
+		verb     string
+		resource string
+	}{
+		{http.MethodGet, "/api/v1/namespaces/default/services/func-a-service", "get", "services"},
+		{http.MethodGet, "/apis/apps/v1/deployments?limit=500", "list", "deployments"},
+		{http.MethodGet, "/apis/apps/v1/deployments?watch=true", "watch", "deployments"},
+		{http.MethodPost, "/apis/networking.k8s.io/v1/namespaces/default/ingresses", "create", "ingresses"},
+		{http.MethodPut, "/apis/serverlesscontroller.peizhong.io/v1alpha1/namespaces/default/serverlessfuncs/a/status", "update", "serverlessfuncs/status"},
+		{http.MethodDelete, "/api/v1/namespaces/default/services/func-a-service", "delete", "services"},
+		{http.MethodGet, "/api/v1/namespaces/default", "get", "namespaces"},
+		{http.MethodGet, "/version", "get", "<other>"},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, "https://apiserver"+test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verb, resource := requestInfo(req)
+		if verb != test.verb || resource != test.resource {
+			t.Errorf("%s %s: expected %s %s, got %s %s", test.method, test.url, test.verb, test.resource, verb, resource)
+		}
+	}
+}
+
+func TestObserveStep(t *testing.T) {
+	before := testutil.ToFloat64(reconcileErrors.WithLabelValues(StepService))
+	var err error
+	ObserveStep(StepService, time.Now(), &err)
+	err = errors.New("conflict")
+	ObserveStep(StepService, time.Now(), &err)
+	if errs := testutil.ToFloat64(reconcileErrors.WithLabelValues(StepService)) - before; errs != 1 {
+		t.Errorf("expected 1 error counted, got %v", errs)
+	}
+}
+
+func TestReplicaGauges(t *testing.T) {
+	SetReplicas("default", "a", 3, 1)
+	if v := testutil.ToFloat64(desiredReplicas.WithLabelValues("default", "a")); v != 3 {
+		t.Errorf("expected 3 desired replicas, got %v", v)
+	}
+	if v := testutil.ToFloat64(availableReplicas.WithLabelValues("default", "a")); v != 1 {
+		t.Errorf("expected 1 available replica, got %v", v)
+	}
+	DeleteFunction("default", "a")
+	if n := testutil.CollectAndCount(desiredReplicas); n != 0 {
+		t.Errorf("expected the gauges of a deleted function to be removed, got %d series", n)
+	}
+}
+
+func TestWorkqueueMetrics(t *testing.T) {
+	queue := workqueue.NewNamed("metrics-test")
+	defer queue.ShutDown()
+	queue.Add("a")
+	if v := testutil.ToFloat64(workqueueAdds.WithLabelValues("metrics-test")); v != 1 {
+		t.Errorf("expected 1 add, got %v", v)
+	}
+	if v := testutil.ToFloat64(workqueueDepth.WithLabelValues("metrics-test")); v != 1 {
+		t.Errorf("expected depth 1, got %v", v)
+	}
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// The workqueue metrics use the names of controller-runtime, so that existing
+// dashboards work unchanged. The queue name is the name label.
+const workqueueSubsystem = "workqueue"
+
+var (
+	workqueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: workqueueSubsystem,
+		Name:      "depth",
+		Help:      "Current depth of workqueue.",
+	}, []string{"name"})
+	workqueueAdds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: workqueueSubsystem,
+		Name:      "adds_total",
+		Help:      "Total number of adds handled by workqueue.",
+	}, []string{"name"})
+	workqueueLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: workqueueSubsystem,
+		Name:      "queue_duration_seconds",
+		Help:      "How long in seconds an item stays in workqueue before being requested.",
+		Buckets:   prometheus.ExponentialBuckets(10e-9, 10, 10),
+	}, []string{"name"})
+	workqueueWorkDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: workqueueSubsystem,
+		Name:      "work_duration_seconds",
+		Help:      "How long in seconds processing an item from workqueue takes.",
+		Buckets:   prometheus.ExponentialBuckets(10e-9, 10, 10),
+	}, []string{"name"})
+	workqueueUnfinishedWork = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: workqueueSubsystem,
+		Name:      "unfinished_work_seconds",
+		Help: "How many seconds of work has been done that is in progress and hasn't " +
+			"been observed by work_duration. Large values indicate stuck threads.",
+	}, []string{"name"})
+	workqueueLongestRunning = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: workqueueSubsystem,
+		Name:      "longest_running_processor_seconds",
+		Help:      "How many seconds has the longest running processor for workqueue been running.",
+	}, []string{"name"})
+	workqueueRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: workqueueSubsystem,
+		Name:      "retries_total",
+		Help:      "Total number of retries handled by workqueue.",
+	}, []string{"name"})
+)
+
+func init() {
+	Registry.MustRegister(
+		workqueueDepth,
+		workqueueAdds,
+		workqueueLatency,
+		workqueueWorkDuration,
+		workqueueUnfinishedWork,
+		workqueueLongestRunning,
+		workqueueRetries,
+	)
+	// Queues only pick up the provider when they are created, which happens
+	// after the init of every imported package.
+	workqueue.SetProvider(workqueueMetricsProvider{})
+}
+
+// WorkqueueRetries returns the retries counter of the queue named name, for
+// queues that count their retries themselves.
+func WorkqueueRetries(name string) prometheus.Counter {
+	return workqueueRetries.WithLabelValues(name)
+}
+
+type workqueueMetricsProvider struct{}
+
+func (workqueueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return workqueueDepth.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return workqueueAdds.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return workqueueLatency.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return workqueueWorkDuration.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueUnfinishedWork.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueLongestRunning.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return workqueueRetries.WithLabelValues(name)
+}
@@ -1,24 +1,65 @@
 package tools
 
 import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+
 	"github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TemplateHashAnnotation records on a Deployment the hash of the pod template
+// written by the controller, so that changes to any part of it are noticed.
+const TemplateHashAnnotation = "serverless.peizhong.io/template-hash"
+
+// ManagedByLabel is set to ManagedBy on every object created for a function,
+// so that the controller only watches its own objects.
+const (
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	ManagedBy      = "serverless-controller"
 )
 
+// ManagedSelector selects the objects created by the controller.
+func ManagedSelector() string {
+	return ManagedByLabel + "=" + ManagedBy
+}
+
+// ComputeHash returns a stable hash of obj.
+func ComputeHash(obj interface{}) string {
+	// json sorts map keys, which keeps the hash stable
+	data, _ := json.Marshal(obj)
+	hasher := fnv.New32a()
+	hasher.Write(data)
+	return fmt.Sprintf("%08x", hasher.Sum32())
+}
+
+// DiffResult is a field of an object whose current value, Right, differs
+// from the desired one, Left.
 type DiffResult struct {
-	Field string
-	Left  interface{}
-	Right interface{}
+	Field string      `json:"field"`
+	Left  interface{} `json:"desired,omitempty"`
+	Right interface{} `json:"current,omitempty"`
 }
 
 func DiffServerlessFuncAndDeployment(foo *v1alpha1.ServerlessFunc, deployment *appsv1.Deployment) []DiffResult {
 	var result []DiffResult
-	if foo.Spec.Replicas != nil && *foo.Spec.Replicas != *deployment.Spec.Replicas {
+	// the replicas of a deployment are only unset before the API server
+	// defaults them
+	if foo.Spec.Replicas != nil && (deployment.Spec.Replicas == nil || *foo.Spec.Replicas != *deployment.Spec.Replicas) {
+		var current interface{}
+		if deployment.Spec.Replicas != nil {
+			current = *deployment.Spec.Replicas
+		}
 		result = append(result, DiffResult{
 			Field: "Replicas",
 			Left:  *foo.Spec.Replicas,
-			Right: *deployment.Spec.Replicas,
+			Right: current,
 		})
 	}
 	if foo.Spec.Image != deployment.Labels["serverlessfunc-images"] {
@@ -35,9 +76,81 @@ func DiffServerlessFuncAndDeployment(foo *v1alpha1.ServerlessFunc, deployment *a
 			Right: deployment.Labels["serverlessfunc-version"],
 		})
 	}
+	// deployments created before the label was introduced are adopted
+	if deployment.Labels[ManagedByLabel] != ManagedBy {
+		result = append(result, DiffResult{
+			Field: "Metadata.Labels[" + ManagedByLabel + "]",
+			Left:  ManagedBy,
+			Right: deployment.Labels[ManagedByLabel],
+		})
+	}
+	return result
+}
+
+// DiffDeploymentTemplate compares the pod template hash of the desired and current Deployment.
+func DiffDeploymentTemplate(desired, current *appsv1.Deployment) []DiffResult {
+	if desired.Annotations[TemplateHashAnnotation] == current.Annotations[TemplateHashAnnotation] {
+		return nil
+	}
+	return []DiffResult{{
+		Field: "Metadata.Annotations[" + TemplateHashAnnotation + "]",
+		Left:  desired.Annotations[TemplateHashAnnotation],
+		Right: current.Annotations[TemplateHashAnnotation],
+	}}
+}
+
+// DiffIngress compares an ingress owned by a function with the desired one.
+func DiffIngress(desired, current *networkingv1.Ingress) []DiffResult {
+	var result []DiffResult
+	if !equality.Semantic.DeepEqual(desired.Annotations, current.Annotations) {
+		result = append(result, DiffResult{
+			Field: "Metadata.Annotations",
+			Left:  desired.Annotations,
+			Right: current.Annotations,
+		})
+	}
+	if !equality.Semantic.DeepEqual(desired.Spec, current.Spec) {
+		result = append(result, DiffResult{
+			Field: "Spec",
+			Left:  desired.Spec,
+			Right: current.Spec,
+		})
+	}
 	return result
 }
 
+// DiffService compares the ports and selector of the Service owned by a function with the desired one.
+func DiffService(desired, current *corev1.Service) []DiffResult {
+	var result []DiffResult
+	if !equality.Semantic.DeepEqual(desired.Spec.Ports, current.Spec.Ports) {
+		result = append(result, DiffResult{
+			Field: "Spec.Ports",
+			Left:  desired.Spec.Ports,
+			Right: current.Spec.Ports,
+		})
+	}
+	if !equality.Semantic.DeepEqual(desired.Spec.Selector, current.Spec.Selector) {
+		result = append(result, DiffResult{
+			Field: "Spec.Selector",
+			Left:  desired.Spec.Selector,
+			Right: current.Spec.Selector,
+		})
+	}
+	return result
+}
+
+// DiffNetworkPolicy compares the NetworkPolicy owned by a function with the desired one.
+func DiffNetworkPolicy(desired, current *networkingv1.NetworkPolicy) []DiffResult {
+	if equality.Semantic.DeepEqual(desired.Spec, current.Spec) {
+		return nil
+	}
+	return []DiffResult{{
+		Field: "Spec",
+		Left:  desired.Spec,
+		Right: current.Spec,
+	}}
+}
+
 func DiffServerlessFuncAndIngress(foo *v1alpha1.ServerlessFunc, ingress *networkingv1.Ingress) []DiffResult {
 	var result []DiffResult
 	if ruleLength := len(ingress.Spec.Rules); ruleLength != 1 {
@@ -49,16 +162,201 @@ func DiffServerlessFuncAndIngress(foo *v1alpha1.ServerlessFunc, ingress *network
 		return result
 	}
 	rule := ingress.Spec.Rules[0]
+	ingressPath := GetIngressPath(foo)
 	serviceName := GetServiceName(foo)
-	for _, path := range rule.HTTP.Paths {
-		if path.Backend.Service.Name == serviceName {
-			return nil
+	if owned := GetIngressRoutes(ingress)[foo.Name]; owned != ingressPath {
+		result = append(result, DiffResult{
+			Field: "Metadata.Annotations[" + IngressRoutesAnnotation + "]",
+			Left:  ingressPath,
+			Right: owned,
+		})
+	}
+	if rule.HTTP != nil {
+		for _, path := range rule.HTTP.Paths {
+			if path.Path != ingressPath {
+				continue
+			}
+			var backend string
+			if path.Backend.Service != nil && path.Backend.Service.Port.Number == 80 {
+				backend = path.Backend.Service.Name
+			}
+			if backend != serviceName {
+				result = append(result, DiffResult{
+					Field: "Spec.Rules[0].Http.Paths.Backend.ServiceName",
+					Left:  serviceName,
+					Right: backend,
+				})
+			}
+			return result
 		}
 	}
 	result = append(result, DiffResult{
-		Field: "Spec.Rules[0].Http.Paths.Backend.ServiceName",
-		Left:  serviceName,
+		Field: "Spec.Rules[0].Http.Paths.Path",
+		Left:  ingressPath,
 		Right: "",
 	})
 	return result
 }
+
+// DiffMetadata compares the labels, annotations and owner references of two
+// objects.
+func DiffMetadata(desired, current metav1.Object) []DiffResult {
+	var result []DiffResult
+	if !equality.Semantic.DeepEqual(desired.GetLabels(), current.GetLabels()) {
+		result = append(result, DiffResult{
+			Field: "Metadata.Labels",
+			Left:  desired.GetLabels(),
+			Right: current.GetLabels(),
+		})
+	}
+	if !equality.Semantic.DeepEqual(desired.GetAnnotations(), current.GetAnnotations()) {
+		result = append(result, DiffResult{
+			Field: "Metadata.Annotations",
+			Left:  desired.GetAnnotations(),
+			Right: current.GetAnnotations(),
+		})
+	}
+	if !equality.Semantic.DeepEqual(desired.GetOwnerReferences(), current.GetOwnerReferences()) {
+		result = append(result, DiffResult{
+			Field: "Metadata.OwnerReferences",
+			Left:  desired.GetOwnerReferences(),
+			Right: current.GetOwnerReferences(),
+		})
+	}
+	return result
+}
+
+// DiffDeployment compares the fields of a Deployment the controller sets,
+// leaving out the ones defaulted by the API server.
+func DiffDeployment(desired, current *appsv1.Deployment) []DiffResult {
+	result := DiffMetadata(desired, current)
+	if !equality.Semantic.DeepEqual(desired.Spec.Replicas, current.Spec.Replicas) {
+		result = append(result, DiffResult{
+			Field: "Spec.Replicas",
+			Left:  desired.Spec.Replicas,
+			Right: current.Spec.Replicas,
+		})
+	}
+	if !equality.Semantic.DeepEqual(desired.Spec.Selector, current.Spec.Selector) {
+		result = append(result, DiffResult{
+			Field: "Spec.Selector",
+			Left:  desired.Spec.Selector,
+			Right: current.Spec.Selector,
+		})
+	}
+	return append(result, DiffPodTemplate(&desired.Spec.Template, &current.Spec.Template)...)
+}
+
+// DiffPodTemplate compares the fields of a pod template the controller sets,
+// container by container.
+func DiffPodTemplate(desired, current *corev1.PodTemplateSpec) []DiffResult {
+	var result []DiffResult
+	add := func(field string, left, right interface{}) {
+		if !equality.Semantic.DeepEqual(left, right) {
+			result = append(result, DiffResult{Field: field, Left: left, Right: right})
+		}
+	}
+	add("Spec.Template.Metadata.Labels", desired.Labels, current.Labels)
+	add("Spec.Template.Spec.SecurityContext", desired.Spec.SecurityContext, current.Spec.SecurityContext)
+	add("Spec.Template.Spec.Volumes", volumeNames(desired.Spec.Volumes), volumeNames(current.Spec.Volumes))
+
+	currentContainers := map[string]*corev1.Container{}
+	for i := range current.Spec.Containers {
+		currentContainers[current.Spec.Containers[i].Name] = &current.Spec.Containers[i]
+	}
+	for i := range desired.Spec.Containers {
+		want := &desired.Spec.Containers[i]
+		field := "Spec.Template.Spec.Containers[" + want.Name + "]"
+		got, ok := currentContainers[want.Name]
+		if !ok {
+			result = append(result, DiffResult{Field: field, Left: want.Name})
+			continue
+		}
+		delete(currentContainers, want.Name)
+		add(field+".Image", want.Image, got.Image)
+		add(field+".Command", want.Command, got.Command)
+		add(field+".Args", want.Args, got.Args)
+		add(field+".Env", want.Env, got.Env)
+		add(field+".Ports", containerPorts(want.Ports), containerPorts(got.Ports))
+		add(field+".Resources.Limits", want.Resources.Limits, got.Resources.Limits)
+		add(field+".VolumeMounts", want.VolumeMounts, got.VolumeMounts)
+	}
+	for i := range current.Spec.Containers {
+		if name := current.Spec.Containers[i].Name; currentContainers[name] != nil {
+			result = append(result, DiffResult{Field: "Spec.Template.Spec.Containers[" + name + "]", Right: name})
+		}
+	}
+	return result
+}
+
+// DiffIngressPaths compares the backends of the paths of two ingresses.
+func DiffIngressPaths(desired, current *networkingv1.Ingress) []DiffResult {
+	want, got := ingressBackends(desired), ingressBackends(current)
+	paths := make([]string, 0, len(want)+len(got))
+	for path := range want {
+		paths = append(paths, path)
+	}
+	for path := range got {
+		if _, ok := want[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	var result []DiffResult
+	for _, path := range paths {
+		if want[path] == got[path] {
+			continue
+		}
+		diff := DiffResult{Field: "Spec.Rules.Http.Paths[" + path + "]"}
+		if backend, ok := want[path]; ok {
+			diff.Left = backend
+		}
+		if backend, ok := got[path]; ok {
+			diff.Right = backend
+		}
+		result = append(result, diff)
+	}
+	return result
+}
+
+// volumeNames returns the names of volumes, whose sources are defaulted by
+// the API server.
+func volumeNames(volumes []corev1.Volume) []string {
+	var names []string
+	for _, volume := range volumes {
+		names = append(names, volume.Name)
+	}
+	return names
+}
+
+// containerPorts returns ports as name:port, leaving out the protocol
+// defaulted by the API server.
+func containerPorts(ports []corev1.ContainerPort) []string {
+	var result []string
+	for _, port := range ports {
+		result = append(result, fmt.Sprintf("%s:%d", port.Name, port.ContainerPort))
+	}
+	return result
+}
+
+// ingressBackends maps the paths of ingress to their backend, as
+// service:port.
+func ingressBackends(ingress *networkingv1.Ingress) map[string]string {
+	backends := map[string]string{}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			var backend string
+			if service := path.Backend.Service; service != nil {
+				backend = fmt.Sprintf("%s:%d", service.Name, service.Port.Number)
+				if service.Port.Name != "" {
+					backend = service.Name + ":" + service.Port.Name
+				}
+			}
+			backends[rule.Host+path.Path] = backend
+		}
+	}
+	return backends
+}
@@ -1,27 +1,118 @@
 package tools
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"strings"
 
 	"github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
-func GetIngressName() string {
-	return "serverlessfunc-ingress"
+// hashLength is the number of hex digits of the hash ending truncated names
+const hashLength = 8
+
+// GenerateName returns prefix+name+suffix. When that is longer than a
+// DNS-1035 label, which bounds Service names and label values, name is
+// truncated and followed by a hash of the whole of it, so that functions
+// whose names only differ past the truncation still get different names.
+func GenerateName(prefix, name, suffix string) string {
+	generated := prefix + name + suffix
+	if len(generated) <= validation.DNS1035LabelMaxLength {
+		return generated
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:hashLength]
+	keep := validation.DNS1035LabelMaxLength - len(prefix) - len(suffix) - len(hash) - 1
+	if keep < 0 {
+		keep = 0
+	}
+	// a label cannot end with a dash
+	truncated := strings.TrimRight(name[:keep], "-")
+	return prefix + truncated + "-" + hash + suffix
 }
 
 func GetIngressPath(foo *v1alpha1.ServerlessFunc) string {
 	return fmt.Sprintf("/serverlessfunc/%s(/|$)(.*)", foo.Name)
 }
 
+// GetFunctionURL returns the path foo is served at, which GetIngressPath
+// matches along with the paths below it.
+func GetFunctionURL(foo *v1alpha1.ServerlessFunc) string {
+	return fmt.Sprintf("/serverlessfunc/%s/", foo.Name)
+}
+
+// GetAppName returns the value of the serverlessfunc label of the objects of
+// foo. Unlike the names of the objects, it is never recorded, since the
+// network policies of other functions select pods by the name of foo alone.
 func GetAppName(foo *v1alpha1.ServerlessFunc) string {
-	return fmt.Sprintf("func-%s", foo.Name)
+	return GenerateName("func-", foo.Name, "")
 }
 
+const (
+	deploymentSuffix    = "-deployment"
+	serviceSuffix       = "-service"
+	ingressSuffix       = "-ingress"
+	networkPolicySuffix = "-networkpolicy"
+)
+
+// GetDeploymentName returns the name of the Deployment of foo. Like the other
+// object names, it is the one recorded in the status of foo, so that it is
+// never recomputed, or a new one for a function that was not synced yet.
 func GetDeploymentName(foo *v1alpha1.ServerlessFunc) string {
-	return fmt.Sprintf("func-%s-deployment", foo.Name)
+	if names := foo.Status.ObjectNames; names != nil && names.Deployment != "" {
+		return names.Deployment
+	}
+	return GenerateName("func-", foo.Name, deploymentSuffix)
 }
 
+// GetServiceName returns the name of the Service of foo, recorded in its
+// status like the Deployment name.
 func GetServiceName(foo *v1alpha1.ServerlessFunc) string {
-	return fmt.Sprintf("func-%s-service", foo.Name)
+	if names := foo.Status.ObjectNames; names != nil && names.Service != "" {
+		return names.Service
+	}
+	return GenerateName("func-", foo.Name, serviceSuffix)
+}
+
+// GetFunctionIngressName returns the name of the ingress of foo, recorded in
+// its status like the Deployment name.
+func GetFunctionIngressName(foo *v1alpha1.ServerlessFunc) string {
+	if names := foo.Status.ObjectNames; names != nil && names.Ingress != "" {
+		return names.Ingress
+	}
+	return GenerateName("func-", foo.Name, ingressSuffix)
+}
+
+// GetNetworkPolicyName returns the name of the NetworkPolicy of foo, recorded
+// in its status like the Deployment name.
+func GetNetworkPolicyName(foo *v1alpha1.ServerlessFunc) string {
+	if names := foo.Status.ObjectNames; names != nil && names.NetworkPolicy != "" {
+		return names.NetworkPolicy
+	}
+	return GenerateName("func-", foo.Name, networkPolicySuffix)
+}
+
+// GetObjectNames returns the names of every object of foo.
+func GetObjectNames(foo *v1alpha1.ServerlessFunc) *v1alpha1.ObjectNames {
+	return &v1alpha1.ObjectNames{
+		Deployment:    GetDeploymentName(foo),
+		Service:       GetServiceName(foo),
+		Ingress:       GetFunctionIngressName(foo),
+		NetworkPolicy: GetNetworkPolicyName(foo),
+	}
+}
+
+// GetLegacyNames returns the names the objects of foo were given before names
+// were generated, which are longer than the generated ones for long function
+// names. Deployments, ingresses and network policies may have such names,
+// Services never did since theirs would have been invalid.
+func GetLegacyNames(foo *v1alpha1.ServerlessFunc) *v1alpha1.ObjectNames {
+	return &v1alpha1.ObjectNames{
+		Deployment:    "func-" + foo.Name + deploymentSuffix,
+		Service:       "func-" + foo.Name + serviceSuffix,
+		Ingress:       "func-" + foo.Name + ingressSuffix,
+		NetworkPolicy: "func-" + foo.Name + networkPolicySuffix,
+	}
 }
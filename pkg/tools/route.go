@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"encoding/json"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// IngressRoutesAnnotation records the paths of the shared ingress that are
+// managed by the controller, as a JSON object of function name to path.
+// Paths that are not recorded here belong to someone else and are never touched.
+const IngressRoutesAnnotation = "serverless.peizhong.io/routes"
+
+// GetIngressRoutes returns the function name to path mapping recorded on the ingress.
+// A missing or malformed annotation yields an empty mapping.
+func GetIngressRoutes(ingress *networkingv1.Ingress) map[string]string {
+	routes := map[string]string{}
+	value, ok := ingress.Annotations[IngressRoutesAnnotation]
+	if !ok || value == "" {
+		return routes
+	}
+	if err := json.Unmarshal([]byte(value), &routes); err != nil {
+		return map[string]string{}
+	}
+	return routes
+}
+
+// SetIngressRoutes records the function name to path mapping on the ingress.
+func SetIngressRoutes(ingress *networkingv1.Ingress, routes map[string]string) {
+	if ingress.Annotations == nil {
+		ingress.Annotations = map[string]string{}
+	}
+	if len(routes) == 0 {
+		delete(ingress.Annotations, IngressRoutesAnnotation)
+		return
+	}
+	// json.Marshal sorts map keys, so the annotation is stable across updates
+	value, _ := json.Marshal(routes)
+	ingress.Annotations[IngressRoutesAnnotation] = string(value)
+}
+
+// GetRouteOwner returns the name of the function owning path, or "" if the path is not managed.
+func GetRouteOwner(routes map[string]string, path string) string {
+	for name, owned := range routes {
+		if owned == path {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,213 @@
+// Package validation checks ServerlessFuncs before they are admitted, so that
+// the controller never derives invalid objects from them.
+package validation
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/tools"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// MaxReplicas is the most replicas a function may ask for.
+const MaxReplicas = 100
+
+// versionFormat is a semantic version with an optional v prefix. Build
+// metadata is left out, since the version is also a label value.
+var versionFormat = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+){0,2}(-[0-9A-Za-z.-]+)?$`)
+
+// generatedNames are the names the controller derives from the name of a
+// ServerlessFunc, all of which must be DNS-1035 labels since one is the name
+// of a Service. They are truncated to the length of a label, so only the
+// characters of the name matter.
+var generatedNames = []struct {
+	kind string
+	name func(*serverlessv1alpha1.ServerlessFunc) string
+}{
+	{"Deployment", tools.GetDeploymentName},
+	{"Service", tools.GetServiceName},
+	{"Ingress", tools.GetFunctionIngressName},
+	{"NetworkPolicy", tools.GetNetworkPolicyName},
+}
+
+var supportedProtocols = []string{
+	string(serverlessv1alpha1.ProtocolHTTP),
+	string(serverlessv1alpha1.ProtocolH2C),
+	string(serverlessv1alpha1.ProtocolGRPC),
+	string(serverlessv1alpha1.ProtocolWebSocket),
+}
+
+var supportedDeletionPolicies = []string{
+	string(serverlessv1alpha1.DeletionPolicyDelete),
+	string(serverlessv1alpha1.DeletionPolicyOrphan),
+}
+
+var supportedAuthTypes = []string{
+	string(serverlessv1alpha1.AuthTypeAPIKey),
+	string(serverlessv1alpha1.AuthTypeBasic),
+	string(serverlessv1alpha1.AuthTypeJWT),
+}
+
+// ValidateServerlessFunc checks a new ServerlessFunc.
+func ValidateServerlessFunc(foo *serverlessv1alpha1.ServerlessFunc) field.ErrorList {
+	errs := validateName(foo)
+	spec := field.NewPath("spec")
+
+	// the image is the executable run from /app, and a label value of the
+	// deployment
+	if foo.Spec.Image == "" {
+		errs = append(errs, field.Required(spec.Child("image"), "the executable of the function"))
+	} else if foo.Spec.Image == "." || foo.Spec.Image == ".." {
+		errs = append(errs, field.Invalid(spec.Child("image"), foo.Spec.Image, "must be the name of an executable"))
+	} else {
+		for _, msg := range validation.IsValidLabelValue(foo.Spec.Image) {
+			errs = append(errs, field.Invalid(spec.Child("image"), foo.Spec.Image, msg))
+		}
+	}
+	if foo.Spec.Version != "" && !versionFormat.MatchString(foo.Spec.Version) {
+		errs = append(errs, field.Invalid(spec.Child("version"), foo.Spec.Version, "must be a version such as 1.2.3 or v1.2.3-rc.1"))
+	} else {
+		for _, msg := range validation.IsValidLabelValue(foo.Spec.Version) {
+			errs = append(errs, field.Invalid(spec.Child("version"), foo.Spec.Version, msg))
+		}
+	}
+	if foo.Spec.Replicas != nil {
+		for _, msg := range validation.IsInRange(int(*foo.Spec.Replicas), 0, MaxReplicas) {
+			errs = append(errs, field.Invalid(spec.Child("replicas"), *foo.Spec.Replicas, msg))
+		}
+	}
+	if foo.Spec.Protocol != "" && !contains(supportedProtocols, string(foo.Spec.Protocol)) {
+		errs = append(errs, field.NotSupported(spec.Child("protocol"), foo.Spec.Protocol, supportedProtocols))
+	}
+	if foo.Spec.DeletionPolicy != "" && !contains(supportedDeletionPolicies, string(foo.Spec.DeletionPolicy)) {
+		errs = append(errs, field.NotSupported(spec.Child("deletionPolicy"), foo.Spec.DeletionPolicy, supportedDeletionPolicies))
+	}
+	if resources := foo.Spec.Resources; resources != nil {
+		errs = append(errs, validateLimits(resources.Pilot, spec.Child("resources", "pilot"))...)
+		errs = append(errs, validateLimits(resources.RPCServer, spec.Child("resources", "rpcServer"))...)
+	}
+	if foo.Spec.Auth != nil {
+		errs = append(errs, validateAuth(foo.Spec.Auth, spec.Child("auth"))...)
+	}
+	if foo.Spec.Network != nil {
+		errs = append(errs, validateNetwork(foo.Spec.Network, spec.Child("network"))...)
+	}
+	return errs
+}
+
+// ValidateServerlessFuncUpdate checks a change from old to foo. Functions
+// admitted before a rule was introduced can still be updated as long as
+// their spec is left alone, e.g. by the status updates of the controller.
+func ValidateServerlessFuncUpdate(foo, old *serverlessv1alpha1.ServerlessFunc) field.ErrorList {
+	if equality.Semantic.DeepEqual(foo.Spec, old.Spec) {
+		return nil
+	}
+	errs := ValidateServerlessFunc(foo)
+	// a new executable is a new function, new code of the same one is rolled
+	// out by changing the version
+	if foo.Spec.Image != old.Spec.Image {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "image"), "field is immutable, change spec.version to roll out a new build"))
+	}
+	return errs
+}
+
+// validateName checks that the names derived from the name of foo are valid.
+func validateName(foo *serverlessv1alpha1.ServerlessFunc) field.ErrorList {
+	name := field.NewPath("metadata", "name")
+	var errs field.ErrorList
+	for _, generated := range generatedNames {
+		for _, msg := range validation.IsDNS1035Label(generated.name(foo)) {
+			errs = append(errs, field.Invalid(name, foo.Name, fmt.Sprintf("%s name %q: %s", generated.kind, generated.name(foo), msg)))
+		}
+		if len(errs) > 0 {
+			// the other names only differ by their suffix
+			break
+		}
+	}
+	return errs
+}
+
+// validateLimits checks the limits of a container, which may only bound its
+// cpu and memory.
+func validateLimits(limits corev1.ResourceList, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for name, quantity := range limits {
+		switch name {
+		case corev1.ResourceCPU, corev1.ResourceMemory:
+		default:
+			errs = append(errs, field.NotSupported(path, string(name),
+				[]string{string(corev1.ResourceCPU), string(corev1.ResourceMemory)}))
+			continue
+		}
+		if quantity.Sign() <= 0 {
+			errs = append(errs, field.Invalid(path.Key(string(name)), quantity.String(), "must be greater than zero"))
+		}
+	}
+	return errs
+}
+
+func validateAuth(auth *serverlessv1alpha1.AuthSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	switch auth.Type {
+	case serverlessv1alpha1.AuthTypeAPIKey, serverlessv1alpha1.AuthTypeBasic:
+		if auth.SecretName == "" {
+			errs = append(errs, field.Required(path.Child("secretName"), fmt.Sprintf("required by the %s type", auth.Type)))
+		}
+	case serverlessv1alpha1.AuthTypeJWT:
+		if auth.JWT == nil {
+			errs = append(errs, field.Required(path.Child("jwt"), "required by the jwt type"))
+		} else if auth.JWT.JWKSConfigMap == "" {
+			errs = append(errs, field.Required(path.Child("jwt", "jwksConfigMap"), ""))
+		}
+	default:
+		errs = append(errs, field.NotSupported(path.Child("type"), auth.Type, supportedAuthTypes))
+	}
+	if auth.RateLimit != nil {
+		if auth.RateLimit.RequestsPerSecond <= 0 {
+			errs = append(errs, field.Invalid(path.Child("rateLimit", "requestsPerSecond"), auth.RateLimit.RequestsPerSecond, "must be greater than zero"))
+		}
+		if auth.RateLimit.Burst < 0 {
+			errs = append(errs, field.Invalid(path.Child("rateLimit", "burst"), auth.RateLimit.Burst, "must not be negative"))
+		}
+	}
+	return errs
+}
+
+func validateNetwork(network *serverlessv1alpha1.NetworkSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, name := range network.AllowFrom {
+		for _, msg := range validation.IsDNS1035Label(string(name)) {
+			errs = append(errs, field.Invalid(path.Child("allowFrom").Index(i), name, msg))
+		}
+	}
+	if network.Egress == nil {
+		return errs
+	}
+	egress := path.Child("egress")
+	for i, cidr := range network.Egress.CIDRs {
+		if _, _, err := net.ParseCIDR(string(cidr)); err != nil {
+			errs = append(errs, field.Invalid(egress.Child("cidrs").Index(i), cidr, "must be a CIDR such as 10.0.0.0/8"))
+		}
+	}
+	for i, name := range network.Egress.Functions {
+		for _, msg := range validation.IsDNS1035Label(string(name)) {
+			errs = append(errs, field.Invalid(egress.Child("functions").Index(i), name, msg))
+		}
+	}
+	return errs
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func newFoo() *serverlessv1alpha1.ServerlessFunc {
+	return &serverlessv1alpha1.ServerlessFunc{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: metav1.NamespaceDefault},
+		Spec: serverlessv1alpha1.FooSpec{
+			Image:    "hello",
+			Version:  "v1.0.0",
+			Replicas: int32Ptr(1),
+		},
+	}
+}
+
+func TestValidateServerlessFunc(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(foo *serverlessv1alpha1.ServerlessFunc)
+		// expected are the fields in error, none when empty
+		expected []string
+	}{
+		{
+			name:   "valid",
+			mutate: func(foo *serverlessv1alpha1.ServerlessFunc) {},
+		},
+		{
+			name:   "long name",
+			mutate: func(foo *serverlessv1alpha1.ServerlessFunc) { foo.Name = strings.Repeat("a", 100) },
+		},
+		{
+			name:     "long name with dots",
+			mutate:   func(foo *serverlessv1alpha1.ServerlessFunc) { foo.Name = "a.b" + strings.Repeat("a", 100) },
+			expected: []string{"metadata.name"},
+		},
+		{
+			name:     "name with dots",
+			mutate:   func(foo *serverlessv1alpha1.ServerlessFunc) { foo.Name = "hello.world" },
+			expected: []string{"metadata.name"},
+		},
+		{
+			name:     "missing image",
+			mutate:   func(foo *serverlessv1alpha1.ServerlessFunc) { foo.Spec.Image = "" },
+			expected: []string{"spec.image"},
+		},
+		{
+			name:     "image out of /app",
+			mutate:   func(foo *serverlessv1alpha1.ServerlessFunc) { foo.Spec.Image = ".." },
+			expected: []string{"spec.image"},
+		},
+		{
+			name:     "image with a path",
+			mutate:   func(foo *serverlessv1alpha1.ServerlessFunc) { foo.Spec.Image = "bin/hello" },
+			expected: []string{"spec.image"},
+		},
+		{
+			name:   "no version",
+			mutate: func(foo *serverlessv1alpha1.ServerlessFunc) { foo.Spec.Version = "" },
+		},
+		{
+			name:   "prerelease version",
+			mutate: func(foo *serverlessv1alpha1.ServerlessFunc) { foo.Spec.Version = "2.1-rc.1" },
+		},
+		{
+			name:     "invalid version",
+			mutate:   func(foo *serverlessv1alpha1.ServerlessFunc) { foo.Spec.Version = "latest" },
+			expected: []string{"spec.version"},
+		},
+		{
+			name:     "negative replicas",
+			mutate:   func(foo *serverlessv1alpha1.ServerlessFunc) { foo.Spec.Replicas = int32Ptr(-1) },
+			expected: []string{"spec.replicas"},
+		},
+		{
+			name:     "too many replicas",
+			mutate:   func(foo *serverlessv1alpha1.ServerlessFunc) { foo.Spec.Replicas = int32Ptr(MaxReplicas + 1) },
+			expected: []string{"spec.replicas"},
+		},
+		{
+			name:     "unknown protocol",
+			mutate:   func(foo *serverlessv1alpha1.ServerlessFunc) { foo.Spec.Protocol = "udp" },
+			expected: []string{"spec.protocol"},
+		},
+		{
+			name:     "unknown deletion policy",
+			mutate:   func(foo *serverlessv1alpha1.ServerlessFunc) { foo.Spec.DeletionPolicy = "Retain" },
+			expected: []string{"spec.deletionPolicy"},
+		},
+		{
+			name: "api keys without secret",
+			mutate: func(foo *serverlessv1alpha1.ServerlessFunc) {
+				foo.Spec.Auth = &serverlessv1alpha1.AuthSpec{Type: serverlessv1alpha1.AuthTypeAPIKey}
+			},
+			expected: []string{"spec.auth.secretName"},
+		},
+		{
+			name: "jwt without jwks",
+			mutate: func(foo *serverlessv1alpha1.ServerlessFunc) {
+				foo.Spec.Auth = &serverlessv1alpha1.AuthSpec{Type: serverlessv1alpha1.AuthTypeJWT, JWT: &serverlessv1alpha1.JWTAuth{}}
+			},
+			expected: []string{"spec.auth.jwt.jwksConfigMap"},
+		},
+		{
+			name: "invalid egress",
+			mutate: func(foo *serverlessv1alpha1.ServerlessFunc) {
+				foo.Spec.Network = &serverlessv1alpha1.NetworkSpec{
+					AllowFrom: []serverlessv1alpha1.FunctionName{"caller"},
+					Egress:    &serverlessv1alpha1.EgressSpec{CIDRs: []serverlessv1alpha1.CIDR{"10.0.0.0/8", "10.0.0.1"}},
+				}
+			},
+			expected: []string{"spec.network.egress.cidrs[1]"},
+		},
+		{
+			name: "invalid resources",
+			mutate: func(foo *serverlessv1alpha1.ServerlessFunc) {
+				foo.Spec.Resources = &serverlessv1alpha1.ResourcesSpec{
+					Pilot:     corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")},
+					RPCServer: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("0")},
+				}
+			},
+			expected: []string{"spec.resources.pilot", "spec.resources.rpcServer[memory]"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			foo := newFoo()
+			test.mutate(foo)
+			var fields []string
+			for _, err := range ValidateServerlessFunc(foo) {
+				fields = append(fields, err.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("expected errors on %v, got %v", test.expected, ValidateServerlessFunc(foo))
+			}
+		})
+	}
+}
+
+func TestValidateServerlessFuncUpdate(t *testing.T) {
+	old := newFoo()
+
+	foo := old.DeepCopy()
+	foo.Spec.Version = "v1.1.0"
+	if errs := ValidateServerlessFuncUpdate(foo, old); len(errs) > 0 {
+		t.Errorf("expected a new version to be valid, got %v", errs)
+	}
+
+	foo = old.DeepCopy()
+	foo.Spec.Image = "world"
+	if errs := ValidateServerlessFuncUpdate(foo, old); len(errs) != 1 || errs[0].Field != "spec.image" {
+		t.Errorf("expected spec.image to be immutable, got %v", errs)
+	}
+
+	// a function admitted before its version was checked keeps its status
+	// updated
+	old.Spec.Version = "latest"
+	foo = old.DeepCopy()
+	foo.Status.AvailableReplicas = 1
+	if errs := ValidateServerlessFuncUpdate(foo, old); len(errs) > 0 {
+		t.Errorf("expected a status update to be valid, got %v", errs)
+	}
+}
@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	serverlessv1beta1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1beta1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+// spoke is a version of ServerlessFunc converted through the hub version,
+// v1alpha1.
+type spoke interface {
+	runtime.Object
+	ConvertTo(hub *serverlessv1alpha1.ServerlessFunc)
+	ConvertFrom(hub *serverlessv1alpha1.ServerlessFunc)
+}
+
+// spokes returns a new ServerlessFunc of every version but the hub.
+var spokes = map[string]func() spoke{
+	serverlessv1beta1.SchemeGroupVersion.String(): func() spoke { return &serverlessv1beta1.ServerlessFunc{} },
+}
+
+// convert converts the ServerlessFuncs of a ConversionReview to the version
+// it asks for.
+func convert(w http.ResponseWriter, r *http.Request) {
+	var review apiextensionsv1.ConversionReview
+	if !decodeReview(w, r, &review) {
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "ConversionReview without request", http.StatusBadRequest)
+		return
+	}
+
+	response := &apiextensionsv1.ConversionResponse{
+		UID:    review.Request.UID,
+		Result: metav1.Status{Status: metav1.StatusSuccess},
+	}
+	for _, object := range review.Request.Objects {
+		converted, err := convertObject(object.Raw, review.Request.DesiredAPIVersion)
+		if err != nil {
+			// the request fails as a whole
+			response.ConvertedObjects = nil
+			response.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+			break
+		}
+		response.ConvertedObjects = append(response.ConvertedObjects, runtime.RawExtension{Raw: converted})
+	}
+	review.Response = response
+	review.Request = nil
+	klog.V(4).InfoS("Conversion reviewed", "uid", response.UID, "objects", len(response.ConvertedObjects), "status", response.Result.Status)
+	writeReview(w, &review, response.UID)
+}
+
+// convertObject converts the ServerlessFunc in raw to version.
+func convertObject(raw []byte, version string) ([]byte, error) {
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, fmt.Errorf("invalid object: %w", err)
+	}
+	if typeMeta.Kind != serverlessFuncKind.Kind {
+		return nil, fmt.Errorf("unsupported kind %q", typeMeta.Kind)
+	}
+	if typeMeta.APIVersion == version {
+		return raw, nil
+	}
+
+	hub := &serverlessv1alpha1.ServerlessFunc{}
+	if typeMeta.APIVersion == serverlessv1alpha1.SchemeGroupVersion.String() {
+		if err := json.Unmarshal(raw, hub); err != nil {
+			return nil, fmt.Errorf("invalid ServerlessFunc: %w", err)
+		}
+	} else if newSpoke, ok := spokes[typeMeta.APIVersion]; ok {
+		from := newSpoke()
+		if err := json.Unmarshal(raw, from); err != nil {
+			return nil, fmt.Errorf("invalid ServerlessFunc: %w", err)
+		}
+		from.ConvertTo(hub)
+	} else {
+		return nil, fmt.Errorf("unsupported version %q", typeMeta.APIVersion)
+	}
+
+	var to runtime.Object = hub
+	if version != serverlessv1alpha1.SchemeGroupVersion.String() {
+		newSpoke, ok := spokes[version]
+		if !ok {
+			return nil, fmt.Errorf("unsupported version %q", version)
+		}
+		spoke := newSpoke()
+		spoke.ConvertFrom(hub)
+		to = spoke
+	}
+	to.GetObjectKind().SetGroupVersionKind(schema.FromAPIVersionAndKind(version, typeMeta.Kind))
+	return json.Marshal(to)
+}
@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	serverlessv1beta1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1beta1"
+	"github.com/peizhong/serverless-controller/pkg/config"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestConvert(t *testing.T) {
+	server := httptest.NewServer(Handler(config.Default))
+	defer server.Close()
+
+	post := func(version string, objects ...interface{}) *apiextensionsv1.ConversionResponse {
+		review := apiextensionsv1.ConversionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: apiextensionsv1.SchemeGroupVersion.String(), Kind: "ConversionReview"},
+			Request:  &apiextensionsv1.ConversionRequest{UID: "uid", DesiredAPIVersion: version},
+		}
+		for _, object := range objects {
+			review.Request.Objects = append(review.Request.Objects, rawObject(t, object))
+		}
+		body, err := json.Marshal(review)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.Post(server.URL+ConvertPath, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+		var got apiextensionsv1.ConversionReview
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Response == nil || got.Response.UID != "uid" {
+			t.Fatalf("expected a response to uid, got %+v", got.Response)
+		}
+		return got.Response
+	}
+	decode := func(raw runtime.RawExtension, obj interface{}) {
+		if err := json.Unmarshal(raw.Raw, obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alpha := newFoo("hello")
+	alpha.Annotations = map[string]string{serverlessv1beta1.SourceAnnotation: "git@example.com:hello@abc123"}
+	response := post(serverlessv1beta1.SchemeGroupVersion.String(), alpha)
+	if response.Result.Status != metav1.StatusSuccess || len(response.ConvertedObjects) != 1 {
+		t.Fatalf("expected one converted object, got %+v", response)
+	}
+	beta := &serverlessv1beta1.ServerlessFunc{}
+	decode(response.ConvertedObjects[0], beta)
+	if beta.APIVersion != serverlessv1beta1.SchemeGroupVersion.String() || beta.Kind != "ServerlessFunc" {
+		t.Errorf("expected a v1beta1 ServerlessFunc, got %v", beta.TypeMeta)
+	}
+	if beta.Spec.Artifact.Image != "hello" || beta.Spec.Artifact.Source != "git@example.com:hello@abc123" || len(beta.Annotations) > 0 {
+		t.Errorf("expected the image and source in the artifact, got %+v", beta)
+	}
+
+	// and back, along with an object already in the desired version
+	response = post(serverlessv1alpha1.SchemeGroupVersion.String(), beta, alpha)
+	if response.Result.Status != metav1.StatusSuccess || len(response.ConvertedObjects) != 2 {
+		t.Fatalf("expected two converted objects, got %+v", response)
+	}
+	for _, raw := range response.ConvertedObjects {
+		got := &serverlessv1alpha1.ServerlessFunc{}
+		decode(raw, got)
+		if !equality.Semantic.DeepEqual(got, alpha) {
+			t.Errorf("expected %+v, got %+v", alpha, got)
+		}
+	}
+
+	// an unknown version fails the whole request
+	response = post("serverlesscontroller.peizhong.io/v2", alpha)
+	if response.Result.Status != metav1.StatusFailure || len(response.ConvertedObjects) != 0 {
+		t.Errorf("expected the conversion to fail, got %+v", response)
+	}
+}
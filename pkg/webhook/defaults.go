@@ -0,0 +1,98 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/config"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// SetDefaults fills the fields foo leaves out with the defaults of cfg, so
+// that the stored function is explicit. The resources of a function are the
+// limits of the deployment section of cfg at the time it was admitted, a
+// change of the config only applies to the functions admitted after it.
+func SetDefaults(foo *serverlessv1alpha1.ServerlessFunc, cfg *config.ControllerConfig) {
+	defaults := cfg.FunctionDefaults
+	spec := &foo.Spec
+	if spec.Replicas == nil {
+		replicas := defaults.Replicas
+		spec.Replicas = &replicas
+	}
+	if spec.Protocol == "" {
+		spec.Protocol = defaults.Protocol
+	}
+	if spec.DeletionPolicy == "" {
+		spec.DeletionPolicy = serverlessv1alpha1.DeletionPolicyDelete
+	}
+	if pilot, rpcServer := cfg.Deployment.Pilot.Limits, cfg.Deployment.RPCServer.Limits; len(pilot) > 0 || len(rpcServer) > 0 {
+		if spec.Resources == nil {
+			spec.Resources = &serverlessv1alpha1.ResourcesSpec{}
+		}
+		if spec.Resources.Pilot == nil && len(pilot) > 0 {
+			spec.Resources.Pilot = pilot.DeepCopy()
+		}
+		if spec.Resources.RPCServer == nil && len(rpcServer) > 0 {
+			spec.Resources.RPCServer = rpcServer.DeepCopy()
+		}
+	}
+	if http := defaults.HTTP; http.RequestTimeout != nil || http.IdleTimeout != nil || http.MaxRequestSize != nil {
+		if spec.HTTP == nil {
+			spec.HTTP = &serverlessv1alpha1.HTTPSpec{}
+		}
+		if spec.HTTP.RequestTimeout == nil && http.RequestTimeout != nil {
+			timeout := *http.RequestTimeout
+			spec.HTTP.RequestTimeout = &timeout
+		}
+		if spec.HTTP.IdleTimeout == nil && http.IdleTimeout != nil {
+			timeout := *http.IdleTimeout
+			spec.HTTP.IdleTimeout = &timeout
+		}
+		if spec.HTTP.MaxRequestSize == nil && http.MaxRequestSize != nil {
+			size := http.MaxRequestSize.DeepCopy()
+			spec.HTTP.MaxRequestSize = &size
+		}
+	}
+	if auth := spec.Auth; auth != nil {
+		if auth.JWT != nil && auth.JWT.JWKSKey == "" {
+			auth.JWT.JWKSKey = serverlessv1alpha1.DefaultJWKSKey
+		}
+		// a bucket holds a second of requests unless told otherwise
+		if auth.RateLimit != nil && auth.RateLimit.Burst == 0 {
+			auth.RateLimit.Burst = auth.RateLimit.RequestsPerSecond
+		}
+	}
+}
+
+// patchOperation is an operation of a JSON patch, RFC 6902.
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// mutate returns an admission function setting the defaults of the config
+// returned by cfg on ServerlessFuncs.
+func mutate(cfg func() *config.ControllerConfig) admitFunc {
+	return func(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+		foo := &serverlessv1alpha1.ServerlessFunc{}
+		if err := json.Unmarshal(request.Object.Raw, foo); err != nil {
+			return deny(apierrors.NewBadRequest(fmt.Sprintf("invalid ServerlessFunc: %v", err)))
+		}
+		defaulted := foo.DeepCopy()
+		SetDefaults(defaulted, cfg())
+		if equality.Semantic.DeepEqual(foo.Spec, defaulted.Spec) {
+			return &admissionv1.AdmissionResponse{Allowed: true}
+		}
+		// the spec is replaced as a whole, add replaces an existing member
+		patch, err := json.Marshal([]patchOperation{{Op: "add", Path: "/spec", Value: defaulted.Spec}})
+		if err != nil {
+			return deny(apierrors.NewInternalError(err))
+		}
+		patchType := admissionv1.PatchTypeJSONPatch
+		return &admissionv1.AdmissionResponse{Allowed: true, Patch: patch, PatchType: &patchType}
+	}
+}
@@ -0,0 +1,184 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/config"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func TestSetDefaults(t *testing.T) {
+	cfg := config.Default()
+	cfg.FunctionDefaults.Replicas = 2
+	cfg.FunctionDefaults.HTTP.RequestTimeout = &metav1.Duration{Duration: 30 * time.Second}
+	maxRequestSize := resource.MustParse("1Mi")
+	cfg.FunctionDefaults.HTTP.MaxRequestSize = &maxRequestSize
+	resources := &serverlessv1alpha1.ResourcesSpec{
+		Pilot:     cfg.Deployment.Pilot.Limits,
+		RPCServer: cfg.Deployment.RPCServer.Limits,
+	}
+	largeRPCServer := corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}
+
+	tests := []struct {
+		name     string
+		spec     serverlessv1alpha1.FooSpec
+		expected serverlessv1alpha1.FooSpec
+	}{
+		{
+			name: "empty",
+			spec: serverlessv1alpha1.FooSpec{Image: "hello"},
+			expected: serverlessv1alpha1.FooSpec{
+				Image:          "hello",
+				Replicas:       int32Ptr(2),
+				Protocol:       serverlessv1alpha1.ProtocolHTTP,
+				DeletionPolicy: serverlessv1alpha1.DeletionPolicyDelete,
+				Resources:      resources,
+				HTTP: &serverlessv1alpha1.HTTPSpec{
+					RequestTimeout: &metav1.Duration{Duration: 30 * time.Second},
+					MaxRequestSize: &maxRequestSize,
+				},
+			},
+		},
+		{
+			name: "explicit",
+			spec: serverlessv1alpha1.FooSpec{
+				Image:          "hello",
+				Replicas:       int32Ptr(0),
+				Protocol:       serverlessv1alpha1.ProtocolGRPC,
+				DeletionPolicy: serverlessv1alpha1.DeletionPolicyOrphan,
+				Resources:      &serverlessv1alpha1.ResourcesSpec{RPCServer: largeRPCServer},
+				HTTP: &serverlessv1alpha1.HTTPSpec{
+					RequestTimeout: &metav1.Duration{Duration: time.Minute},
+				},
+			},
+			expected: serverlessv1alpha1.FooSpec{
+				Image:          "hello",
+				Replicas:       int32Ptr(0),
+				Protocol:       serverlessv1alpha1.ProtocolGRPC,
+				DeletionPolicy: serverlessv1alpha1.DeletionPolicyOrphan,
+				Resources: &serverlessv1alpha1.ResourcesSpec{
+					Pilot:     cfg.Deployment.Pilot.Limits,
+					RPCServer: largeRPCServer,
+				},
+				HTTP: &serverlessv1alpha1.HTTPSpec{
+					RequestTimeout: &metav1.Duration{Duration: time.Minute},
+					MaxRequestSize: &maxRequestSize,
+				},
+			},
+		},
+		{
+			name: "auth",
+			spec: serverlessv1alpha1.FooSpec{
+				Image: "hello",
+				Auth: &serverlessv1alpha1.AuthSpec{
+					Type:      serverlessv1alpha1.AuthTypeJWT,
+					JWT:       &serverlessv1alpha1.JWTAuth{JWKSConfigMap: "keys"},
+					RateLimit: &serverlessv1alpha1.RateLimit{RequestsPerSecond: 5},
+				},
+			},
+			expected: serverlessv1alpha1.FooSpec{
+				Image:          "hello",
+				Replicas:       int32Ptr(2),
+				Protocol:       serverlessv1alpha1.ProtocolHTTP,
+				DeletionPolicy: serverlessv1alpha1.DeletionPolicyDelete,
+				Resources:      resources,
+				HTTP: &serverlessv1alpha1.HTTPSpec{
+					RequestTimeout: &metav1.Duration{Duration: 30 * time.Second},
+					MaxRequestSize: &maxRequestSize,
+				},
+				Auth: &serverlessv1alpha1.AuthSpec{
+					Type:      serverlessv1alpha1.AuthTypeJWT,
+					JWT:       &serverlessv1alpha1.JWTAuth{JWKSConfigMap: "keys", JWKSKey: serverlessv1alpha1.DefaultJWKSKey},
+					RateLimit: &serverlessv1alpha1.RateLimit{RequestsPerSecond: 5, Burst: 5},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			foo := &serverlessv1alpha1.ServerlessFunc{Spec: test.spec}
+			SetDefaults(foo, cfg)
+			if !reflect.DeepEqual(foo.Spec, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, foo.Spec)
+			}
+		})
+	}
+}
+
+func TestMutate(t *testing.T) {
+	server := httptest.NewServer(Handler(config.Default))
+	defer server.Close()
+
+	post := func(foo *serverlessv1alpha1.ServerlessFunc) *admissionv1.AdmissionResponse {
+		review := admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: admissionv1.SchemeGroupVersion.String(), Kind: "AdmissionReview"},
+			Request: &admissionv1.AdmissionRequest{
+				UID:       "uid",
+				Operation: admissionv1.Create,
+				Object:    rawObject(t, foo),
+			},
+		}
+		body, err := json.Marshal(review)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.Post(server.URL+MutatePath, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var got admissionv1.AdmissionReview
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Response == nil || !got.Response.Allowed {
+			t.Fatalf("expected the request to be allowed, got %+v", got.Response)
+		}
+		return got.Response
+	}
+
+	response := post(newFoo("hello"))
+	if response.PatchType == nil || *response.PatchType != admissionv1.PatchTypeJSONPatch {
+		t.Fatalf("expected a JSON patch, got %+v", response)
+	}
+	var patch []struct {
+		Op    string                     `json:"op"`
+		Path  string                     `json:"path"`
+		Value serverlessv1alpha1.FooSpec `json:"value"`
+	}
+	if err := json.Unmarshal(response.Patch, &patch); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	expected := newFoo("hello").Spec
+	expected.Replicas = int32Ptr(1)
+	expected.Protocol = serverlessv1alpha1.ProtocolHTTP
+	expected.DeletionPolicy = serverlessv1alpha1.DeletionPolicyDelete
+	expected.Resources = &serverlessv1alpha1.ResourcesSpec{
+		Pilot:     cfg.Deployment.Pilot.Limits,
+		RPCServer: cfg.Deployment.RPCServer.Limits,
+	}
+	if len(patch) != 1 || patch[0].Op != "add" || patch[0].Path != "/spec" || !equality.Semantic.DeepEqual(patch[0].Value, expected) {
+		t.Errorf("expected the spec to be replaced by %+v, got %+v", expected, patch)
+	}
+
+	// a function with every default set is left alone
+	foo := newFoo("hello")
+	foo.Spec = expected
+	if response := post(foo); response.Patch != nil {
+		t.Errorf("expected no patch, got %s", response.Patch)
+	}
+}
@@ -0,0 +1,148 @@
+// Package webhook serves the admission webhooks of ServerlessFuncs, and the
+// conversion webhook of their CRD.
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/config"
+	"github.com/peizhong/serverless-controller/pkg/validation"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// ValidatePath is the path of the validating webhook of ServerlessFuncs
+	ValidatePath = "/validate-serverlessfunc"
+	// MutatePath is the path of the defaulting webhook of ServerlessFuncs
+	MutatePath = "/mutate-serverlessfunc"
+	// ConvertPath is the path of the conversion webhook of the CRD
+	ConvertPath = "/convert-serverlessfunc"
+
+	// DefaultCertDir holds tls.crt and tls.key, the serving certificate of
+	// the webhooks
+	DefaultCertDir = "/tmp/k8s-webhook-server/serving-certs"
+)
+
+// maxRequestSize bounds the size of a review, the API server sends objects of
+// at most 3MiB.
+const maxRequestSize = 3 << 20
+
+var serverlessFuncKind = schema.GroupKind{Group: serverlessv1alpha1.SchemeGroupVersion.Group, Kind: "ServerlessFunc"}
+
+// Handler returns the handler of every webhook. cfg returns the current
+// config of the controller, whose defaults are set on ServerlessFuncs.
+func Handler(cfg func() *config.ControllerConfig) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(ValidatePath, admit(validate))
+	mux.Handle(MutatePath, admit(mutate(cfg)))
+	mux.HandleFunc(ConvertPath, convert)
+	return mux
+}
+
+// Serve serves the webhooks over TLS on addr, with the certificate in
+// certDir, until ctx is done.
+func Serve(ctx context.Context, addr, certDir string, cfg func() *config.ControllerConfig) error {
+	server := &http.Server{Addr: addr, Handler: Handler(cfg)}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	klog.InfoS("Serving webhooks", "address", addr, "certDir", certDir)
+	err := server.ListenAndServeTLS(filepath.Join(certDir, "tls.crt"), filepath.Join(certDir, "tls.key"))
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// admitFunc decides on an admission request.
+type admitFunc func(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
+
+// admit decodes the AdmissionReview of a request, and replies with the
+// response of f.
+func admit(f admitFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var review admissionv1.AdmissionReview
+		if !decodeReview(w, r, &review) {
+			return
+		}
+		if review.Request == nil {
+			http.Error(w, "AdmissionReview without request", http.StatusBadRequest)
+			return
+		}
+
+		response := f(review.Request)
+		response.UID = review.Request.UID
+		review.Response = response
+		review.Request = nil
+		klog.V(4).InfoS("Admission reviewed", "path", r.URL.Path, "uid", response.UID, "allowed", response.Allowed)
+		writeReview(w, &review, response.UID)
+	})
+}
+
+// decodeReview decodes the review in the body of r, or replies with an error
+// and returns false.
+func decodeReview(w http.ResponseWriter, r *http.Request, review interface{}) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "application/json" {
+		http.Error(w, fmt.Sprintf("unsupported content type %q", contentType), http.StatusUnsupportedMediaType)
+		return false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(review); err != nil {
+		http.Error(w, fmt.Sprintf("invalid review: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writeReview replies with review, which answers the request uid.
+func writeReview(w http.ResponseWriter, review interface{}, uid types.UID) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		klog.ErrorS(err, "Failed to write review response", "uid", uid)
+	}
+}
+
+// validate admits ServerlessFuncs passing validation.
+func validate(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	foo := &serverlessv1alpha1.ServerlessFunc{}
+	if err := json.Unmarshal(request.Object.Raw, foo); err != nil {
+		return deny(apierrors.NewBadRequest(fmt.Sprintf("invalid ServerlessFunc: %v", err)))
+	}
+	var errs field.ErrorList
+	if request.Operation == admissionv1.Update {
+		old := &serverlessv1alpha1.ServerlessFunc{}
+		if err := json.Unmarshal(request.OldObject.Raw, old); err != nil {
+			return deny(apierrors.NewBadRequest(fmt.Sprintf("invalid ServerlessFunc: %v", err)))
+		}
+		errs = validation.ValidateServerlessFuncUpdate(foo, old)
+	} else {
+		errs = validation.ValidateServerlessFunc(foo)
+	}
+	if len(errs) > 0 {
+		return deny(apierrors.NewInvalid(serverlessFuncKind, foo.Name, errs))
+	}
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
+
+// deny returns a response refusing a request with err.
+func deny(err *apierrors.StatusError) *admissionv1.AdmissionResponse {
+	status := err.Status()
+	return &admissionv1.AdmissionResponse{Allowed: false, Result: &status}
+}
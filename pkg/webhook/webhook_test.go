@@ -0,0 +1,140 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	serverlessv1alpha1 "github.com/peizhong/serverless-controller/pkg/apis/serverlesscontroller/v1alpha1"
+	"github.com/peizhong/serverless-controller/pkg/config"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newFoo(image string) *serverlessv1alpha1.ServerlessFunc {
+	return &serverlessv1alpha1.ServerlessFunc{
+		TypeMeta:   metav1.TypeMeta{APIVersion: serverlessv1alpha1.SchemeGroupVersion.String(), Kind: "ServerlessFunc"},
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: metav1.NamespaceDefault},
+		Spec:       serverlessv1alpha1.FooSpec{Image: image, Version: "v1"},
+	}
+}
+
+func rawObject(t *testing.T, obj interface{}) runtime.RawExtension {
+	if obj == nil {
+		return runtime.RawExtension{}
+	}
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runtime.RawExtension{Raw: raw}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		object    *serverlessv1alpha1.ServerlessFunc
+		oldObject *serverlessv1alpha1.ServerlessFunc
+		allowed   bool
+		// code is the HTTP status of a denied request
+		code int32
+	}{
+		{
+			name:      "valid create",
+			operation: admissionv1.Create,
+			object:    newFoo("hello"),
+			allowed:   true,
+		},
+		{
+			name:      "create without image",
+			operation: admissionv1.Create,
+			object:    newFoo(""),
+			code:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:      "valid update",
+			operation: admissionv1.Update,
+			object:    newFoo("hello"),
+			oldObject: newFoo("hello"),
+			allowed:   true,
+		},
+		{
+			name:      "image changed",
+			operation: admissionv1.Update,
+			object:    newFoo("world"),
+			oldObject: newFoo("hello"),
+			code:      http.StatusUnprocessableEntity,
+		},
+	}
+	server := httptest.NewServer(Handler(config.Default))
+	defer server.Close()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			review := admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: admissionv1.SchemeGroupVersion.String(), Kind: "AdmissionReview"},
+				Request: &admissionv1.AdmissionRequest{
+					UID:       types.UID("uid-" + test.name),
+					Operation: test.operation,
+					Object:    rawObject(t, test.object),
+					OldObject: rawObject(t, test.oldObject),
+				},
+			}
+			body, err := json.Marshal(review)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.Post(server.URL+ValidatePath, "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", resp.StatusCode)
+			}
+			var got admissionv1.AdmissionReview
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Response == nil || got.Response.UID != review.Request.UID {
+				t.Fatalf("expected a response to %s, got %+v", review.Request.UID, got.Response)
+			}
+			if got.Response.Allowed != test.allowed {
+				t.Errorf("expected allowed %v, got %+v", test.allowed, got.Response)
+			}
+			if !test.allowed && (got.Response.Result == nil || got.Response.Result.Code != test.code) {
+				t.Errorf("expected code %d, got %+v", test.code, got.Response.Result)
+			}
+		})
+	}
+}
+
+func TestAdmitRejectsInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		expected    int
+	}{
+		{"get", http.MethodGet, "application/json", "", http.StatusMethodNotAllowed},
+		{"yaml", http.MethodPost, "application/yaml", "{}", http.StatusUnsupportedMediaType},
+		{"malformed", http.MethodPost, "application/json", "{", http.StatusBadRequest},
+		{"without request", http.MethodPost, "application/json", "{}", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, ValidatePath, bytes.NewBufferString(test.body))
+			r.Header.Set("Content-Type", test.contentType)
+			w := httptest.NewRecorder()
+			Handler(config.Default).ServeHTTP(w, r)
+			if w.Code != test.expected {
+				t.Errorf("expected status %d, got %d", test.expected, w.Code)
+			}
+		})
+	}
+}